@@ -0,0 +1,349 @@
+package dto
+
+import (
+	"cars/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCSVHeader is returned when the header row of a CSV file is missing,
+// names an unknown or duplicate column, or lacks a required column.
+var ErrCSVHeader = errors.New("invalid CSV header")
+
+// CSV column names, matched case-insensitively against the header row.
+const (
+	csvMake     = "make"
+	csvModel    = "model"
+	csvColor    = "color"
+	csvCategory = "category"
+	csvYear     = "year"
+	csvPackage  = "package"
+	csvMileage  = "mileage"
+	csvPrice    = "price"
+)
+
+// csvColumns lists the supported CSV columns, in the order they are
+// written; the first five are required.
+var csvColumns = []string{csvMake, csvModel, csvColor, csvCategory, csvYear, csvPackage, csvMileage, csvPrice}
+
+// CarImportError reports why a row of an imported file was rejected.
+// Rows are numbered from 1, the header being row 1.
+type CarImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// CarImport holds the decoded rows of an import file: the cars of the valid
+// rows, in file order, with their row numbers, and the errors of the
+// invalid rows.
+type CarImport struct {
+	Cars   models.Cars
+	Rows   []int
+	Errors []CarImportError
+}
+
+// DecodeCarsCSV reads cars from a CSV file whose header row names the
+// columns make, model, color, category, year and, optionally, package,
+// mileage and price, in any order and case.
+//
+// Every data row is validated for creation. Empty optional cells are left
+// unset, mileage may use thousands separators and price is a dollar amount
+// such as "28,990.00" or "$28990", converted to cents. Rows that cannot be
+// parsed or fail validation are reported in the Errors of the result; an
+// error is only returned when the file itself is unreadable or its header
+// is invalid.
+func DecodeCarsCSV(r io.Reader) (CarImport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return CarImport{}, fmt.Errorf("%w: missing header row", ErrCSVHeader)
+	}
+	if err != nil {
+		return CarImport{}, err
+	}
+
+	columns, err := parseCSVHeader(header)
+	if err != nil {
+		return CarImport{}, err
+	}
+
+	var out CarImport
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return CarImport{}, err
+		}
+
+		row, _ := reader.FieldPos(0)
+		if err != nil {
+			out.Errors = append(out.Errors, CarImportError{
+				Row:     row,
+				Message: fmt.Sprintf("expected %d fields, got %d", len(columns), len(record)),
+			})
+			continue
+		}
+
+		car, rowErr := parseCSVRecord(columns, record)
+		if rowErr == nil {
+			if err := car.ValidateForCreate(); err != nil {
+				rowErr = &CarImportError{Message: err.Error()}
+			}
+		}
+
+		if rowErr != nil {
+			rowErr.Row = row
+			out.Errors = append(out.Errors, *rowErr)
+			continue
+		}
+
+		out.Cars = append(out.Cars, car)
+		out.Rows = append(out.Rows, row)
+	}
+}
+
+// parseCSVHeader returns the column name of each field of the header row.
+func parseCSVHeader(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff") // spreadsheet byte order mark
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		if !slices.Contains(csvColumns, name) {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrCSVHeader, name)
+		}
+		if slices.Contains(columns[:i], name) {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrCSVHeader, name)
+		}
+		columns[i] = name
+	}
+
+	for _, name := range csvColumns[:5] {
+		if !slices.Contains(columns, name) {
+			return nil, fmt.Errorf("%w: missing column %q", ErrCSVHeader, name)
+		}
+	}
+	return columns, nil
+}
+
+// parseCSVRecord maps the fields of a data row to a car.
+func parseCSVRecord(columns, record []string) (models.Car, *CarImportError) {
+	var car models.Car
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch columns[i] {
+		case csvMake:
+			car.Make = value
+		case csvModel:
+			car.Model = value
+		case csvColor:
+			car.Color = value
+		case csvCategory:
+			car.Category = value
+		case csvYear:
+			car.Year, err = strconv.Atoi(value)
+		case csvPackage:
+			if value != "" {
+				car.Package = &value
+			}
+		case csvMileage:
+			car.Mileage, err = parseOptional(value, parseGrouped)
+		case csvPrice:
+			car.Price, err = parseOptional(value, parseDollars)
+		}
+
+		if err != nil {
+			return models.Car{}, &CarImportError{
+				Column:  columns[i],
+				Message: fmt.Sprintf("invalid %s: %q", columns[i], value),
+			}
+		}
+	}
+	return car, nil
+}
+
+// parseOptional parses a non-empty value with parse, and returns nil for
+// an empty one.
+func parseOptional(value string, parse func(string) (int64, error)) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	n, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// parseGrouped parses a base-10 integer that may use commas as thousands
+// separators, such as "120,123".
+func parseGrouped(s string) (int64, error) {
+	if strings.Contains(s, ",") {
+		groups := strings.Split(strings.TrimPrefix(s, "-"), ",")
+		for i, group := range groups {
+			if len(group) != 3 && (i > 0 || len(group) == 0 || len(group) > 3) {
+				return 0, strconv.ErrSyntax
+			}
+		}
+		s = strings.ReplaceAll(s, ",", "")
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// parseDollars parses a dollar amount such as "28,990.00", "$28990" or
+// "28990.5" and returns it in cents. At most two decimals are accepted.
+func parseDollars(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "$")
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || whole[0] < '0' || whole[0] > '9' ||
+		hasFrac && (frac == "" || len(frac) > 2 || strings.Trim(frac, "0123456789") != "") {
+		return 0, strconv.ErrSyntax
+	}
+
+	dollars, err := parseGrouped(whole)
+	if err != nil {
+		return 0, err
+	}
+
+	var cents int64
+	if hasFrac {
+		cents, _ = strconv.ParseInt((frac + "0")[:2], 10, 64)
+	}
+
+	total := dollars*100 + cents
+	if total/100 != dollars {
+		return 0, strconv.ErrRange
+	}
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// CarCSVEncoder writes cars as CSV rows, one per car, under a header row
+// naming the columns. Columns are the CarResponse fields, by JSON name and
+// in the same order, restricted to a sparse fieldset. Prices are written
+// as dollar amounts such as "28990.00", like DecodeCarsCSV reads them;
+// optional fields without a value are left empty.
+type CarCSVEncoder struct {
+	w       *csv.Writer
+	columns []string
+	header  bool
+}
+
+// NewCarCSVEncoder returns an encoder writing to w the fields selected by
+// fields, or every field when fields is nil.
+func NewCarCSVEncoder(w io.Writer, fields CarFields) *CarCSVEncoder {
+	columns := carResponseFields
+	if fields != nil {
+		columns = slices.DeleteFunc(slices.Clone(columns), func(name string) bool {
+			return !fields[name]
+		})
+	}
+	return &CarCSVEncoder{w: csv.NewWriter(w), columns: columns}
+}
+
+// Encode writes the row of a car, preceded by the header row if it has not
+// been written yet. Rows are buffered until Flush.
+func (enc *CarCSVEncoder) Encode(resp CarResponse) error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(enc.columns))
+	for i, column := range enc.columns {
+		record[i] = formatCSVField(resp, column)
+	}
+	return enc.w.Write(record)
+}
+
+// Flush writes any buffered rows, and the header row if no car was
+// encoded, to the underlying writer.
+func (enc *CarCSVEncoder) Flush() error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+
+	enc.w.Flush()
+	return enc.w.Error()
+}
+
+func (enc *CarCSVEncoder) writeHeader() error {
+	if enc.header {
+		return nil
+	}
+
+	enc.header = true
+	return enc.w.Write(enc.columns)
+}
+
+// formatCSVField formats the value of a CarResponse field for CSV.
+func formatCSVField(resp CarResponse, column string) string {
+	switch column {
+	case "id":
+		return resp.ID
+	case csvMake:
+		return resp.Make
+	case csvModel:
+		return resp.Model
+	case csvColor:
+		return resp.Color
+	case csvCategory:
+		return resp.Category
+	case csvYear:
+		return strconv.Itoa(resp.Year)
+	case csvPackage:
+		if resp.Package != nil {
+			return *resp.Package
+		}
+	case csvMileage:
+		if resp.Mileage != nil {
+			return strconv.FormatInt(*resp.Mileage, 10)
+		}
+	case csvPrice:
+		if resp.Price != nil {
+			return formatDollars(*resp.Price)
+		}
+	case "revision":
+		return strconv.FormatInt(resp.Revision, 10)
+	case "deleted_at":
+		if resp.DeletedAt != nil {
+			return resp.DeletedAt.Format(time.RFC3339Nano)
+		}
+	}
+	return ""
+}
+
+// formatDollars formats an amount in cents as dollars with two decimals,
+// such as "28990.00". It is the inverse of parseDollars.
+func formatDollars(cents int64) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+	}
+
+	abs := uint64(cents)
+	if cents < 0 {
+		abs = -abs
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, abs/100, abs%100)
+}
@@ -0,0 +1,74 @@
+package dto
+
+import (
+	"cars/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorPayload is the serialized form of a CarCursor.
+//
+// Only the sort key values recorded in the cursor are set; a missing price
+// or mileage is encoded by omitting it, and so is the score of a listing
+// that is not ranked.
+type cursorPayload struct {
+	ID      string  `json:"id"`
+	Sort    string  `json:"sort,omitempty"`
+	Score   float64 `json:"score,omitempty"`
+	Make    string  `json:"make,omitempty"`
+	Model   string  `json:"model,omitempty"`
+	Year    int     `json:"year,omitempty"`
+	Price   *int64  `json:"price,omitempty"`
+	Mileage *int64  `json:"mileage,omitempty"`
+}
+
+// EncodeCursor serializes a CarCursor into an opaque, URL-safe token.
+//
+// Clients must treat the token as opaque and only pass it back
+// unchanged in the cursor query parameter.
+func EncodeCursor(c models.CarCursor) string {
+	data, _ := json.Marshal(cursorPayload{
+		ID:      c.ID,
+		Sort:    models.FormatCarSort(c.Sort),
+		Score:   c.Score,
+		Make:    c.Make,
+		Model:   c.Model,
+		Year:    c.Year,
+		Price:   c.Price,
+		Mileage: c.Mileage,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor.
+func DecodeCursor(token string) (models.CarCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.CarCursor{}, ErrInvalidCursor
+	}
+
+	var p cursorPayload
+	if err := json.Unmarshal(data, &p); err != nil || p.ID == "" {
+		return models.CarCursor{}, ErrInvalidCursor
+	}
+
+	sort, err := models.ParseCarSort(p.Sort)
+	if err != nil {
+		return models.CarCursor{}, ErrInvalidCursor
+	}
+
+	return models.CarCursor{
+		ID:      p.ID,
+		Sort:    sort,
+		Score:   p.Score,
+		Make:    p.Make,
+		Model:   p.Model,
+		Year:    p.Year,
+		Price:   p.Price,
+		Mileage: p.Mileage,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrUnknownField is returned when a sparse fieldset names a field that
+// CarResponse does not have.
+var ErrUnknownField = errors.New("unknown field")
+
+// carResponseFields lists the JSON names of the CarResponse fields,
+// in the order they are encoded.
+var carResponseFields = jsonFieldNames(reflect.TypeFor[CarResponse]())
+
+// CarFields is a sparse fieldset: the CarResponse fields a client asked
+// for, by JSON name. A nil CarFields selects every field.
+type CarFields map[string]bool
+
+// ParseCarFields builds a sparse fieldset from a list of JSON field names
+// such as ["make", "price"]. The id field is always selected. An empty list
+// yields nil, which selects every field.
+func ParseCarFields(names []string) (CarFields, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	fields := CarFields{"id": true}
+	for _, name := range names {
+		if !slices.Contains(carResponseFields, name) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownField, name)
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// Project returns the representation of resp restricted to the selected
+// fields. Optional fields without a value stay omitted even when selected.
+// With no selection, resp is returned unchanged.
+func (f CarFields) Project(resp CarResponse) any {
+	if f == nil {
+		return resp
+	}
+	return carProjection{resp: resp, fields: f}
+}
+
+// ProjectList applies Project to every response of a list.
+func (f CarFields) ProjectList(resp []CarResponse) any {
+	if f == nil {
+		return resp
+	}
+
+	out := make([]any, len(resp))
+	for i := range resp {
+		out[i] = f.Project(resp[i])
+	}
+	return out
+}
+
+// carProjection encodes the selected fields of a CarResponse, keeping the
+// order in which CarResponse encodes them.
+type carProjection struct {
+	resp   CarResponse
+	fields CarFields
+}
+
+func (p carProjection) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(p.resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, name := range carResponseFields {
+		value, ok := members[name]
+		if !ok || !p.fields[name] {
+			continue
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonFieldNames returns the JSON names of the fields of a struct type.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
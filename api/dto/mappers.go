@@ -2,6 +2,10 @@ package dto
 
 import (
 	"cars/models"
+	"cars/pkg/httpx"
+	"cmp"
+	"net/http"
+	"slices"
 )
 
 // ToModelCreate maps a CreateCarRequest to a Car model.
@@ -39,16 +43,12 @@ func toModel(req CarUpsertRequest) *models.Car {
 	}
 }
 
-// ToResponse maps a Car model to a CarResponse.
+// toRequest maps a Car model back to a CarUpsertRequest.
 //
-// If the provided car is nil, it returns an empty response.
-func ToResponse(car *models.Car) CarResponse {
-	if car == nil {
-		return CarResponse{}
-	}
-
-	return CarResponse{
-		ID:       car.ID,
+// It is the inverse of toModel and is used to apply patch documents
+// expressed in request field names.
+func toRequest(car *models.Car) CarUpsertRequest {
+	return CarUpsertRequest{
 		Make:     car.Make,
 		Model:    car.Model,
 		Color:    car.Color,
@@ -60,6 +60,43 @@ func ToResponse(car *models.Car) CarResponse {
 	}
 }
 
+// ToSelector maps a CarSelectorRequest to a CarSelector, parsing its
+// filter expression.
+func ToSelector(req CarSelectorRequest) (models.CarSelector, error) {
+	sel := models.CarSelector{IDs: req.IDs}
+	if req.Filter != "" {
+		expr, err := models.ParseCarExpr(req.Filter)
+		if err != nil {
+			return models.CarSelector{}, err
+		}
+		sel.Filters = &models.CarFilters{Expr: expr}
+	}
+	return sel, nil
+}
+
+// ToResponse maps a Car model to a CarResponse.
+//
+// If the provided car is nil, it returns an empty response.
+func ToResponse(car *models.Car) CarResponse {
+	if car == nil {
+		return CarResponse{}
+	}
+
+	return CarResponse{
+		ID:        car.ID,
+		Make:      car.Make,
+		Model:     car.Model,
+		Color:     car.Color,
+		Category:  car.Category,
+		Year:      car.Year,
+		Package:   car.Package,
+		Mileage:   car.Mileage,
+		Price:     car.Price,
+		Revision:  car.Revision,
+		DeletedAt: car.DeletedAt,
+	}
+}
+
 // ToResponseList maps a collection of Car models to a slice of CarResponse.
 func ToResponseList(cars models.Cars) []CarResponse {
 	out := make([]CarResponse, len(cars))
@@ -68,3 +105,129 @@ func ToResponseList(cars models.Cars) []CarResponse {
 	}
 	return out
 }
+
+// ToBatchItemResponseList maps the outcome of a partial bulk create to one
+// CarBatchItemResponse per car, in request order. errs holds the error of
+// each car, or nil for the cars that were created.
+func ToBatchItemResponseList(cars models.Cars, errs []error) []CarBatchItemResponse {
+	out := make([]CarBatchItemResponse, len(cars))
+	for i := range cars {
+		out[i].Index = i
+
+		if errs[i] != nil {
+			status, resp := httpx.NewErrorResponse(errs[i])
+			out[i].Status = status
+			out[i].Error = &resp
+			continue
+		}
+
+		car := ToResponse(&cars[i])
+		out[i].Status = http.StatusCreated
+		out[i].Car = &car
+	}
+	return out
+}
+
+// ToImportResponse maps the decoded rows of an import file to a
+// CarImportResponse. Only the cars that were assigned an ID are reported
+// as created. errs holds the error, if any, with which each car of
+// imp.Cars was rejected when stored; it may be nil if none was stored.
+// The errors are reported by row.
+func ToImportResponse(imp CarImport, errs []error) CarImportResponse {
+	out := CarImportResponse{
+		Created: make([]CarImportedRow, 0, len(imp.Cars)),
+		Errors:  make([]CarImportError, 0, len(imp.Errors)),
+	}
+
+	for i, car := range imp.Cars {
+		if car.ID != "" {
+			out.Created = append(out.Created, CarImportedRow{Row: imp.Rows[i], ID: car.ID})
+		}
+	}
+	out.Imported = len(out.Created)
+	out.Errors = append(out.Errors, imp.Errors...)
+
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		_, resp := httpx.NewErrorResponse(err)
+		msg := resp.Details
+		if msg == "" {
+			msg = resp.Message
+		}
+		out.Errors = append(out.Errors, CarImportError{Row: imp.Rows[i], Message: msg})
+	}
+	slices.SortStableFunc(out.Errors, func(a, b CarImportError) int { return cmp.Compare(a.Row, b.Row) })
+	return out
+}
+
+// ToStatsResponse maps CarStats to a CarStatsResponse.
+func ToStatsResponse(stats models.CarStats) CarStatsResponse {
+	return CarStatsResponse{
+		Count: stats.Count,
+		Facets: CarFacetsResponse{
+			Make:     ToFacetResponseList(stats.Make),
+			Model:    ToFacetResponseList(stats.Model),
+			Category: ToFacetResponseList(stats.Category),
+			Color:    ToFacetResponseList(stats.Color),
+			Year:     ToFacetResponseList(stats.Year),
+		},
+		Price:   toValueStatsResponse(stats.Price),
+		Mileage: toValueStatsResponse(stats.Mileage),
+	}
+}
+
+// ToFacetResponseList maps facets to FacetResponses, keeping their order.
+func ToFacetResponseList[T comparable](facets []models.Facet[T]) []FacetResponse[T] {
+	out := make([]FacetResponse[T], len(facets))
+	for i, f := range facets {
+		out[i] = FacetResponse[T]{Value: f.Value, Count: f.Count}
+	}
+	return out
+}
+
+func toValueStatsResponse(stats *models.CarValueStats) *CarValueStatsResponse {
+	if stats == nil {
+		return nil
+	}
+
+	return &CarValueStatsResponse{
+		Count: stats.Count,
+		Min:   stats.Min,
+		Max:   stats.Max,
+		Avg:   stats.Avg,
+	}
+}
+
+// ToHistoryResponse maps the audit trail of a car to one
+// CarAuditEntryResponse per entry, keeping their order.
+func ToHistoryResponse(history []models.CarAuditEntry) []CarAuditEntryResponse {
+	out := make([]CarAuditEntryResponse, len(history))
+	for i, entry := range history {
+		out[i] = CarAuditEntryResponse{
+			Revision:  entry.Revision,
+			Action:    string(entry.Action),
+			Actor:     entry.Actor,
+			RequestID: entry.RequestID,
+			Time:      entry.Time,
+			After:     ToResponse(&entry.After),
+			Changes:   []CarFieldChangeResponse{},
+		}
+
+		if entry.Before != nil {
+			before := ToResponse(entry.Before)
+			out[i].Before = &before
+		}
+
+		for _, change := range entry.Changes() {
+			out[i].Changes = append(out[i].Changes, CarFieldChangeResponse{
+				Field: change.Field,
+				From:  change.From,
+				To:    change.To,
+			})
+		}
+	}
+	return out
+}
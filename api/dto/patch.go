@@ -0,0 +1,98 @@
+package dto
+
+import (
+	"bytes"
+	"cars/models"
+	"cars/pkg/jsonpatch"
+	"encoding/json"
+)
+
+// MergePatchCarRequest represents a JSON Merge Patch (RFC 7396) document
+// for a car.
+//
+// Members that are omitted keep their current value, and members set to
+// null are cleared. Only the optional fields (package, mileage, price) can
+// be cleared without failing validation.
+type MergePatchCarRequest map[string]any
+
+// ToMergePatch returns a CarPatchFunc that applies the merge patch document
+// to a car using the same JSON field names as CarUpsertRequest.
+//
+// The car ID is never modified; unknown members or values of the wrong
+// type cause the patch to fail.
+func ToMergePatch(req MergePatchCarRequest) models.CarPatchFunc {
+	return func(car *models.Car) error {
+		target, err := toDocument(car)
+		if err != nil {
+			return err
+		}
+
+		merged := jsonpatch.MergePatch(target, map[string]any(req))
+
+		return fromDocument(merged, car)
+	}
+}
+
+// JSONPatchCarRequest represents a JSON Patch (RFC 6902) document for a car:
+// an ordered list of add, remove, replace, move, copy and test operations.
+type JSONPatchCarRequest []jsonpatch.Operation
+
+// ToJSONPatch returns a CarPatchFunc that applies the JSON Patch operations
+// to a car using the same JSON field names as CarUpsertRequest, for example
+// "/price" or "/package".
+//
+// Operations are applied in order and either all succeed or the car is left
+// unchanged. A failed "test" operation yields an error wrapping
+// jsonpatch.ErrTestFailed.
+func ToJSONPatch(req JSONPatchCarRequest) models.CarPatchFunc {
+	return func(car *models.Car) error {
+		target, err := toDocument(car)
+		if err != nil {
+			return err
+		}
+
+		patched, err := jsonpatch.Apply(target, req)
+		if err != nil {
+			return err
+		}
+
+		return fromDocument(patched, car)
+	}
+}
+
+// toDocument converts a car into its generic JSON representation,
+// using the CarUpsertRequest field names.
+func toDocument(car *models.Car) (any, error) {
+	data, err := json.Marshal(toRequest(car))
+	if err != nil {
+		return nil, err
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// fromDocument decodes a generic JSON representation back into car,
+// rejecting unknown fields. The car ID is preserved.
+func fromDocument(doc any, car *models.Car) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var req CarUpsertRequest
+	if err := dec.Decode(&req); err != nil {
+		return err
+	}
+
+	patched := toModel(req)
+	patched.ID = car.ID
+	*car = *patched
+	return nil
+}
@@ -2,7 +2,7 @@ package dto
 
 // CarUpsertRequest represents the payload for creating or fully updating a car.
 // When used for updates, ALL fields must be provided.
-// Partial updates are not supported.
+// Partial updates are expressed with MergePatchCarRequest instead.
 type CarUpsertRequest struct {
 	Make     string `json:"make"`
 	Model    string `json:"model"`
@@ -17,3 +17,22 @@ type CarUpsertRequest struct {
 
 type CreateCarRequest = CarUpsertRequest
 type UpdateCarRequest = CarUpsertRequest
+
+// CarSelectorRequest selects the cars a bulk operation applies to: either
+// the cars with the given IDs, or the cars matching a filter expression
+// (see package filterexpr). With DryRun set, the operation only reports
+// how many cars it would affect.
+type CarSelectorRequest struct {
+	IDs    []string `json:"ids,omitempty"`
+	Filter string   `json:"filter,omitempty"`
+	DryRun bool     `json:"dry_run,omitempty"`
+}
+
+// BulkUpdateCarsRequest applies a JSON Merge Patch document to every
+// selected car.
+type BulkUpdateCarsRequest struct {
+	CarSelectorRequest
+	Patch MergePatchCarRequest `json:"patch"`
+}
+
+type BulkDeleteCarsRequest = CarSelectorRequest
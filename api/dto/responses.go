@@ -1,5 +1,10 @@
 package dto
 
+import (
+	"cars/pkg/httpx"
+	"time"
+)
+
 // CarResponse represents a car returned to the client.
 type CarResponse struct {
 	ID string `json:"id"`
@@ -13,4 +18,94 @@ type CarResponse struct {
 	Package *string `json:"package,omitempty"`
 	Mileage *int64  `json:"mileage,omitempty"`
 	Price   *int64  `json:"price,omitempty"`
+
+	Revision  int64      `json:"revision"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// CarBatchItemResponse reports the outcome of one item of a partial bulk
+// create: the created car, or the error that kept it from being created.
+type CarBatchItemResponse struct {
+	Index  int `json:"index"`
+	Status int `json:"status"`
+
+	Car   *CarResponse         `json:"car,omitempty"`
+	Error *httpx.ErrorResponse `json:"error,omitempty"`
+}
+
+// BulkResultResponse reports how many cars a bulk update or delete
+// affected, or would affect when it was a dry run.
+type BulkResultResponse struct {
+	Affected int  `json:"affected"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// CarImportResponse reports the outcome of a CSV import: the cars created,
+// identified by the row they were read from, and the rows rejected.
+type CarImportResponse struct {
+	Imported int              `json:"imported"`
+	Created  []CarImportedRow `json:"created"`
+	Errors   []CarImportError `json:"errors"`
+}
+
+// CarImportedRow identifies the car created from a row of an imported file.
+type CarImportedRow struct {
+	Row int    `json:"row"`
+	ID  string `json:"id"`
+}
+
+// CarStatsResponse summarises the cars matching a listing's filters.
+type CarStatsResponse struct {
+	Count  int               `json:"count"`
+	Facets CarFacetsResponse `json:"facets"`
+
+	Price   *CarValueStatsResponse `json:"price"`
+	Mileage *CarValueStatsResponse `json:"mileage"`
+}
+
+// CarFacetsResponse holds the number of cars sharing each value of the
+// faceted attributes, most common first.
+type CarFacetsResponse struct {
+	Make     []FacetResponse[string] `json:"make"`
+	Model    []FacetResponse[string] `json:"model"`
+	Category []FacetResponse[string] `json:"category"`
+	Color    []FacetResponse[string] `json:"color"`
+	Year     []FacetResponse[int]    `json:"year"`
+}
+
+// FacetResponse is the number of cars sharing a value.
+type FacetResponse[T comparable] struct {
+	Value T   `json:"value"`
+	Count int `json:"count"`
+}
+
+// CarValueStatsResponse summarises a price or mileage over the cars that
+// have one. Avg is rounded to the nearest integer.
+type CarValueStatsResponse struct {
+	Count int   `json:"count"`
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	Avg   int64 `json:"avg"`
+}
+
+// CarAuditEntryResponse is one change in the history of a car. Before is
+// null for the change that created the car.
+type CarAuditEntryResponse struct {
+	Revision  int64     `json:"revision"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	Time      time.Time `json:"time"`
+
+	Before  *CarResponse             `json:"before"`
+	After   CarResponse              `json:"after"`
+	Changes []CarFieldChangeResponse `json:"changes"`
+}
+
+// CarFieldChangeResponse is a field changed between two revisions of a
+// car. A null from or to stands for an unset field.
+type CarFieldChangeResponse struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
 }
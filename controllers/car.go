@@ -3,33 +3,81 @@ package controllers
 import (
 	"cars/api/dto"
 	"cars/models"
+	"cars/pkg/contextkeys"
 	"cars/pkg/httpx"
+	"cars/pkg/jsonpatch"
 	"cars/pkg/logger"
 	"cars/services"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	e "cars/pkg/errors"
 
 	"github.com/go-chi/chi/v5"
 )
 
+const (
+	// DefaultPageLimit is the number of cars returned by List when a
+	// cursor but no limit query parameter is given. Without either, every
+	// matching car is returned, as before pagination was introduced.
+	DefaultPageLimit = 100
+
+	// MaxPageLimit is the largest accepted value for the limit query parameter.
+	MaxPageLimit = 1000
+
+	// MaxImportSize is the largest accepted CSV upload, in bytes.
+	MaxImportSize = 10 << 20
+
+	// exportFlushInterval is the number of cars an export writes between
+	// flushes to the client.
+	exportFlushInterval = 100
+)
+
 // CarController manages HTTP requests related to cars.
 type CarController struct {
 	service services.CarService
+	upsert  bool
+}
+
+// CarControllerOption configures optional behavior of a CarController.
+type CarControllerOption func(*CarController)
+
+// WithUpsert sets whether PUT /cars/{id} creates the car when no car has
+// that ID, instead of returning 404. It is disabled by default.
+func WithUpsert(enabled bool) CarControllerOption {
+	return func(c *CarController) {
+		c.upsert = enabled
+	}
 }
 
 // NewCarController creates a new instance of CarController.
-func NewCarController(service services.CarService) *CarController {
-	return &CarController{service: service}
+func NewCarController(service services.CarService, opts ...CarControllerOption) *CarController {
+	c := &CarController{service: service}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Get handles retrieving a car by its ID.
 //
 // Returns the car with the given ID, or a 404 error if the car is not found.
+// The response carries an ETag derived from the car revision; if it matches
+// the request's If-None-Match header, 304 Not Modified is returned instead.
+// The fields query parameter limits the response to the listed fields.
+// Deleted cars are only returned when include_deleted=true, which requires
+// admin access.
+//
+// With as_of set to an RFC 3339 time, the car is reconstructed from its
+// history as it was at that time instead, and a 404 error is returned if it
+// did not exist then.
 //
 // Method: GET
 // Path: /cars/{id}
@@ -42,14 +90,47 @@ func (c *CarController) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	car, err := c.service.Find(id)
+	fields, err := parseCarFields(r.URL.Query())
+	if err != nil {
+		log.Printf("error parsing car fields: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	includeDeleted, err := getIncludeDeletedParam(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	asOf, err := getTimeQueryParam(r.URL.Query(), "as_of")
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	var car models.Car
+	if asOf != nil {
+		car, err = c.service.FindAsOf(id, *asOf, includeDeleted)
+	} else {
+		car, err = c.service.Find(id, includeDeleted)
+	}
 	if err != nil {
 		log.Printf("error retrieving car id=%s: %v", id, err)
 		httpx.HandleServiceError(w, err)
 		return
 	}
 
-	resp := dto.ToResponse(&car)
+	etag := httpx.ETag(car.Revision)
+	w.Header().Set("ETag", etag)
+
+	if httpx.IfNoneMatch(r, etag) {
+		_ = httpx.JSON(w, http.StatusNotModified, nil)
+		log.Printf("car not modified id=%s", id)
+		return
+	}
+
+	resp := fields.Project(dto.ToResponse(&car))
 
 	if err := httpx.JSON(w, http.StatusOK, resp); err != nil {
 		log.Printf("error encoding car response: %v", err)
@@ -60,42 +141,217 @@ func (c *CarController) Get(w http.ResponseWriter, r *http.Request) {
 	log.Printf("car retrieved id=%s", id)
 }
 
-// List handles retrieving all available cars.
+// List handles retrieving available cars, one page at a time.
+//
+// Cars are ordered by the comma-separated sort keys in the sort query
+// parameter (e.g. "-price,year"; a leading "-" means descending) and
+// then by ID, so the order is always stable. When the q free-text search
+// parameter is given without sort, cars are ranked by relevance instead.
+// Pages are only used when the limit or cursor query parameter is given;
+// otherwise every matching car is returned. When more cars are available,
+// the response carries the opaque cursor for the next page in the
+// X-Next-Cursor header and a Link header with rel="next". The fields query
+// parameter limits each car to the listed fields.
+//
+// The response format is negotiated from the Accept header: JSON by
+// default, or a CSV or NDJSON export of every matching car (see export).
+// A JSON page is read before anything is written, since its cursor headers
+// depend on its last car, then encoded one car at a time, stopping early if
+// the client goes away.
 //
 // Method: GET
 // Path: /cars
 func (c *CarController) List(w http.ResponseWriter, r *http.Request) {
 	log := logger.FromContext(r.Context())
 
-	// Supported query params:
-	// - make
-	// - model
-	// - year
-	filters, err := parseCarFilters(r.URL.Query())
+	w.Header().Add("Vary", "Accept")
+	mediaType, err := httpx.Negotiate(r, httpx.MediaTypeJSON, httpx.MediaTypeCSV, httpx.MediaTypeNDJSON)
+	if err != nil {
+		log.Printf("error negotiating cars response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	// Supported query params (list params accept repeated keys or
+	// comma-separated values, matching any of them):
+	// - make, model, category, color, package, year (lists)
+	// - min_year, max_year
+	// - min_price, max_price (in cents)
+	// - min_mileage, max_mileage
+	// - q (free-text search)
+	// - filter (filter expression, see package filterexpr)
+	// - sort
+	// - limit
+	// - cursor
+	// - fields (list)
+	filters, err := parseCarFilters(r)
 	if err != nil {
 		log.Printf("error parsing car filters: %v", err)
 		httpx.HandleServiceError(w, err)
 		return
 	}
 
-	cars, err := c.service.List(filters)
+	fields, err := parseCarFields(r.URL.Query())
+	if err != nil {
+		log.Printf("error parsing car fields: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if mediaType != httpx.MediaTypeJSON {
+		c.export(w, r, mediaType, filters, fields)
+		return
+	}
+
+	page, err := c.service.List(filters)
 	if err != nil {
 		log.Printf("error retrieving cars: %v", err)
 		httpx.HandleServiceError(w, err)
 		return
 	}
 
-	resp := dto.ToResponseList(cars)
+	if page.Next != nil {
+		cursor := dto.EncodeCursor(*page.Next)
+		w.Header().Set("X-Next-Cursor", cursor)
+		w.Header().Set("Link", nextPageLink(r.URL, cursor))
+	}
+
+	cars := page.Cars
 
-	if err := httpx.JSON(w, http.StatusOK, resp); err != nil {
+	w.Header().Set("Content-Type", httpx.MediaTypeJSON)
+	w.WriteHeader(http.StatusOK)
+
+	enc := httpx.NewJSONArrayEncoder(w)
+	for i := range cars {
+		if err := r.Context().Err(); err != nil {
+			log.Printf("cars response stopped after %d cars: %v", i, err)
+			return
+		}
+
+		if err := enc.Encode(fields.Project(dto.ToResponse(&cars[i]))); err != nil {
+			log.Printf("error encoding cars response: %v", err)
+			return
+		}
+	}
+
+	if err := enc.Close(); err != nil {
 		log.Printf("error encoding cars response: %v", err)
-		httpx.HandleServiceError(w, err)
 		return
 	}
 
 	log.Printf("%d cars retrieved", len(cars))
 }
 
+// export streams every car matching f, in the requested order, as CSV
+// (see dto.CarCSVEncoder) or as NDJSON, one CarResponse per line. The limit
+// and cursor query parameters do not apply. Cars are written as the service
+// visits them and flushed every exportFlushInterval cars, so the download
+// starts at once and the inventory is never held in memory; the export stops
+// as soon as the client goes away.
+//
+// Errors are reported as usual until the first car has been written. After
+// that the status line has been sent, so a failure can only be logged and
+// the response cut short.
+func (c *CarController) export(w http.ResponseWriter, r *http.Request, mediaType string, f models.CarFilters, fields dto.CarFields) {
+	log := logger.FromContext(r.Context())
+
+	var encode func(dto.CarResponse) error
+	flush := func() error { return nil }
+
+	switch mediaType {
+	case httpx.MediaTypeCSV:
+		enc := dto.NewCarCSVEncoder(w, fields)
+		encode, flush = enc.Encode, enc.Flush
+	default:
+		enc := json.NewEncoder(w)
+		encode = func(resp dto.CarResponse) error { return enc.Encode(fields.Project(resp)) }
+	}
+
+	f.Limit = 0
+	f.After = nil
+
+	count, started := 0, false
+	start := func() {
+		w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+
+	var writeErr error
+	err := c.service.Each(f, func(car models.Car) bool {
+		if writeErr = r.Context().Err(); writeErr != nil {
+			return false
+		}
+		if !started {
+			start()
+		}
+
+		if writeErr = encode(dto.ToResponse(&car)); writeErr != nil {
+			return false
+		}
+
+		if count++; count%exportFlushInterval == 0 {
+			writeErr = flushResponse(w, flush)
+		}
+		return writeErr == nil
+	})
+	if err != nil {
+		log.Printf("error retrieving cars: %v", err)
+		if !started {
+			httpx.HandleServiceError(w, err)
+		}
+		return
+	}
+
+	if writeErr == nil {
+		if !started {
+			start()
+		}
+		writeErr = flushResponse(w, flush)
+	}
+	if writeErr != nil {
+		log.Printf("cars export stopped after %d cars: %v", count, writeErr)
+		return
+	}
+
+	log.Printf("%d cars exported", count)
+}
+
+// Stats handles summarising the cars matching the listing filters: their
+// count, the number of cars per make, model, category, color and year, and
+// the minimum, maximum and average price and mileage.
+//
+// It accepts the same filter query parameters as List, so the facets
+// reflect the current search; sort, limit and cursor do not apply.
+//
+// Method: GET
+// Path: /cars/stats
+func (c *CarController) Stats(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	filters, err := parseCarFilters(r)
+	if err != nil {
+		log.Printf("error parsing car filters: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	stats, err := c.service.Stats(filters)
+	if err != nil {
+		log.Printf("error computing car stats: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if err := httpx.JSON(w, http.StatusOK, dto.ToStatsResponse(stats)); err != nil {
+		log.Printf("error encoding car stats response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("car stats computed over %d cars", stats.Count)
+}
+
 // Create handles creating a new car.
 //
 // The request body must contain all required car fields.
@@ -115,7 +371,7 @@ func (c *CarController) Create(w http.ResponseWriter, r *http.Request) {
 
 	car := dto.ToModelCreate(*req)
 
-	if err := c.service.Create(car); err != nil {
+	if err := c.service.Create(r.Context(), car); err != nil {
 		log.Printf("error creating car: %v", err)
 		httpx.HandleServiceError(w, err)
 		return
@@ -124,6 +380,7 @@ func (c *CarController) Create(w http.ResponseWriter, r *http.Request) {
 	resp := dto.ToResponse(car)
 
 	w.Header().Set("Location", fmt.Sprintf("/cars/%s", car.ID))
+	w.Header().Set("ETag", httpx.ETag(car.Revision))
 	if err := httpx.JSON(w, http.StatusCreated, resp); err != nil {
 		log.Printf("error encoding created car response: %v", err)
 		httpx.HandleServiceError(w, err)
@@ -133,13 +390,257 @@ func (c *CarController) Create(w http.ResponseWriter, r *http.Request) {
 	log.Printf("car created id=%s", car.ID)
 }
 
+// CreateBatch handles creating several cars in one request.
+//
+// The request body must be a JSON array of cars, each containing all
+// required fields. The mode query parameter selects how invalid cars are
+// handled:
+//
+//   - atomic (default): either every car is created and 201 Created is
+//     returned with the created cars, or none is and the error is returned.
+//   - partial: the valid cars are created and 200 OK is returned with one
+//     result per car, in request order, carrying either the created car or
+//     the error that kept it from being created.
+//
+// Method: POST
+// Path: /cars/bulk
+func (c *CarController) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	partial, err := parseBatchMode(r.URL.Query())
+	if err != nil {
+		log.Printf("error parsing batch mode: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	reqs, err := httpx.DecodeList[dto.CreateCarRequest](r)
+	if err != nil {
+		log.Printf("error decoding car batch payload: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	cars := make(models.Cars, len(reqs))
+	for i, req := range reqs {
+		cars[i] = *dto.ToModelCreate(req)
+	}
+
+	errs, err := c.service.CreateBatch(r.Context(), cars, partial)
+	if err != nil {
+		log.Printf("error creating car batch: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	status, resp := http.StatusCreated, any(dto.ToResponseList(cars))
+	if partial {
+		status, resp = http.StatusOK, dto.ToBatchItemResponseList(cars, errs)
+	}
+
+	if err := httpx.JSON(w, status, resp); err != nil {
+		log.Printf("error encoding car batch response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("car batch processed: %d cars, %d failed", len(cars), countErrors(errs))
+}
+
+// UpdateBatch handles applying a JSON Merge Patch to several cars at once.
+//
+// The request body selects the cars either by ID or with a filter
+// expression and carries the patch to apply. The update is atomic: if any
+// patched car fails validation, no car is changed. The response reports
+// how many cars were updated; with dry_run set, nothing is stored and the
+// response reports how many cars would have been updated.
+//
+// Method: POST
+// Path: /cars/bulk/update
+func (c *CarController) UpdateBatch(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	req, err := httpx.Decode[dto.BulkUpdateCarsRequest](r)
+	if err != nil {
+		log.Printf("error decoding bulk update payload: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	sel, err := parseCarSelector(req.CarSelectorRequest)
+	if err != nil {
+		log.Printf("error parsing car selector: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if len(req.Patch) == 0 {
+		err := e.NewValidationError(errors.New("patch is required"))
+		log.Printf("error validating bulk update payload: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	n, err := c.service.UpdateBatch(r.Context(), sel, dto.ToMergePatch(req.Patch), req.DryRun)
+	if err != nil {
+		log.Printf("error updating cars: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	resp := dto.BulkResultResponse{Affected: n, DryRun: req.DryRun}
+
+	if err := httpx.JSON(w, http.StatusOK, resp); err != nil {
+		log.Printf("error encoding bulk update response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("%d cars updated (dry run: %t)", n, req.DryRun)
+}
+
+// DeleteBatch handles deleting several cars at once.
+//
+// The request body selects the cars either by ID or with a filter
+// expression. The deletion is atomic, and the response reports how many
+// cars were deleted; with dry_run set, nothing is deleted and the response
+// reports how many cars would have been.
+//
+// Method: POST
+// Path: /cars/bulk/delete
+func (c *CarController) DeleteBatch(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	req, err := httpx.Decode[dto.BulkDeleteCarsRequest](r)
+	if err != nil {
+		log.Printf("error decoding bulk delete payload: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	sel, err := parseCarSelector(*req)
+	if err != nil {
+		log.Printf("error parsing car selector: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	n, err := c.service.DeleteBatch(r.Context(), sel, req.DryRun)
+	if err != nil {
+		log.Printf("error deleting cars: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	resp := dto.BulkResultResponse{Affected: n, DryRun: req.DryRun}
+
+	if err := httpx.JSON(w, http.StatusOK, resp); err != nil {
+		log.Printf("error encoding bulk delete response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("%d cars deleted (dry run: %t)", n, req.DryRun)
+}
+
+// Import handles creating cars from an uploaded CSV file.
+//
+// The file is sent either as the request body (text/csv) or as the "file"
+// field of a multipart/form-data upload. Its header row names the columns
+// (see dto.DecodeCarsCSV), and every data row is validated like a created
+// car. The mode query parameter selects how rejected rows are handled:
+//
+//   - atomic (default): if any row is rejected, no car is created and
+//     422 Unprocessable Entity is returned with the row-numbered errors;
+//     otherwise every car is created and 201 Created is returned.
+//   - partial: the cars of the valid rows are created and 200 OK is
+//     returned, reporting the rejected rows alongside.
+//
+// Unlike POST /cars/bulk, the number of rows is not limited to
+// services.MaxCarBatchSize.
+//
+// Method: POST
+// Path: /cars/import
+func (c *CarController) Import(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	partial, err := parseBatchMode(r.URL.Query())
+	if err != nil {
+		log.Printf("error parsing import mode: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	body, err := csvUpload(w, r)
+	if err != nil {
+		log.Printf("error reading import upload: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+	defer body.Close()
+
+	imp, err := dto.DecodeCarsCSV(body)
+	if err != nil {
+		log.Printf("error decoding import file: %v", err)
+		httpx.HandleServiceError(w, e.NewInvalidRequestBodyError(err))
+		return
+	}
+
+	if len(imp.Cars) == 0 && len(imp.Errors) == 0 {
+		err := e.NewValidationError(errors.New("file has no rows to import"))
+		log.Printf("error validating import file: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if len(imp.Errors) > 0 && !partial {
+		_ = httpx.JSON(w, http.StatusUnprocessableEntity, dto.ToImportResponse(imp, nil))
+		log.Printf("car import rejected: %d invalid rows", len(imp.Errors))
+		return
+	}
+
+	var errs []error
+	if len(imp.Cars) > 0 {
+		errs, err = c.service.Import(r.Context(), imp.Cars, partial)
+		if err != nil {
+			log.Printf("error importing cars: %v", err)
+			httpx.HandleServiceError(w, err)
+			return
+		}
+	}
+
+	status := http.StatusCreated
+	if partial {
+		status = http.StatusOK
+	}
+
+	resp := dto.ToImportResponse(imp, errs)
+
+	if err := httpx.JSON(w, status, resp); err != nil {
+		log.Printf("error encoding import response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("%d cars imported, %d rows rejected", resp.Imported, len(resp.Errors))
+}
+
 // Update handles updating an existing car.
 //
 // This endpoint performs a FULL replacement of the car resource.
 // All fields must be provided in the request body.
 //
 // Any field omitted from the request will be reset to its zero value.
-// Partial updates are NOT supported.
+// Partial updates are NOT supported here; use Patch instead.
+//
+// If the If-Match header is set, the car is only replaced when it matches
+// the current ETag; otherwise 412 Precondition Failed is returned.
+//
+// When upsert is enabled (see WithUpsert), a car that does not exist is
+// created under the ID from the path, which must match
+// models.CarIDPattern, and 201 Created is returned with its Location. With
+// If-None-Match: * the car is only created, never replaced: 412
+// Precondition Failed is returned if the ID is taken.
 //
 // Method: PUT
 // Path: /cars/{id}
@@ -152,6 +653,12 @@ func (c *CarController) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	revision, err := httpx.IfMatchRevision(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
 	req, err := httpx.Decode[dto.UpdateCarRequest](r)
 	if err != nil {
 		log.Printf("error decoding car payload: %v", err)
@@ -160,8 +667,15 @@ func (c *CarController) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	car := dto.ToModelUpdate(id, *req)
+	car.Revision = revision
 
-	if err := c.service.Update(car); err != nil {
+	created := false
+	if c.upsert {
+		created, err = c.service.Upsert(r.Context(), car, httpx.IfNoneMatchAny(r))
+	} else {
+		err = c.service.Update(r.Context(), car)
+	}
+	if err != nil {
 		log.Printf("error updating car id=%s: %v", car.ID, err)
 		httpx.HandleServiceError(w, err)
 		return
@@ -169,22 +683,96 @@ func (c *CarController) Update(w http.ResponseWriter, r *http.Request) {
 
 	resp := dto.ToResponse(car)
 
-	if err := httpx.JSON(w, http.StatusOK, resp); err != nil {
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		w.Header().Set("Location", fmt.Sprintf("/cars/%s", car.ID))
+	}
+	w.Header().Set("ETag", httpx.ETag(car.Revision))
+
+	if err := httpx.JSON(w, status, resp); err != nil {
 		log.Printf("error encoding updated car response: %v", err)
 		httpx.HandleServiceError(w, err)
 		return
 	}
 
+	if created {
+		log.Printf("car created id=%s", id)
+		return
+	}
 	log.Printf("car updated id=%s", id)
 }
 
+// Patch handles partially updating an existing car.
+//
+// Two patch formats are accepted, selected by the Content-Type header:
+//
+//   - application/merge-patch+json: a JSON Merge Patch (RFC 7396) document.
+//     Omitted fields keep their current value and null clears optional fields.
+//   - application/json-patch+json: a JSON Patch (RFC 6902) operation list.
+//     "test" operations guard the change; if any fails, nothing is modified
+//     and 409 Conflict is returned.
+//
+// The patch is applied atomically and the patched car must still pass
+// update validation. If the If-Match header is set, the patch is only
+// applied when it matches the current ETag; otherwise 412 Precondition
+// Failed is returned.
+//
+// Method: PATCH
+// Path: /cars/{id}
+func (c *CarController) Patch(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	id, err := getIDParam(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	revision, err := httpx.IfMatchRevision(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	patch, err := decodeCarPatch(r)
+	if err != nil {
+		log.Printf("error decoding car patch: %v", err)
+		w.Header().Set("Accept-Patch", httpx.MediaTypeMergePatch+", "+httpx.MediaTypeJSONPatch)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	car, err := c.service.Patch(r.Context(), id, revision, patch)
+	if err != nil {
+		log.Printf("error patching car id=%s: %v", id, err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	resp := dto.ToResponse(&car)
+
+	w.Header().Set("ETag", httpx.ETag(car.Revision))
+
+	if err := httpx.JSON(w, http.StatusOK, resp); err != nil {
+		log.Printf("error encoding patched car response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("car patched id=%s", id)
+}
+
 // Delete handles removing an existing car.
 //
-// This endpoint permanently deletes the car resource identified by its ID.
-// The operation is irreversible once completed.
+// The car identified by its ID is soft-deleted: it is hidden from reads
+// and can no longer be modified, but it can be brought back with Restore
+// until it is purged.
 //
 // A valid non-empty ID must be provided in the path parameter.
 // If the ID is missing or the car does not exist, an error is returned.
+// If the If-Match header is set, the car is only deleted when it matches
+// the current ETag; otherwise 412 Precondition Failed is returned.
 //
 // Method: DELETE
 // Path: /cars/{id}
@@ -197,7 +785,13 @@ func (c *CarController) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := c.service.Delete(id); err != nil {
+	revision, err := httpx.IfMatchRevision(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if err := c.service.Delete(r.Context(), id, revision); err != nil {
 		log.Printf("error deleting car id=%s: %v", id, err)
 		httpx.HandleServiceError(w, err)
 		return
@@ -207,6 +801,137 @@ func (c *CarController) Delete(w http.ResponseWriter, r *http.Request) {
 	log.Printf("car deleted id=%s", id)
 }
 
+// Restore handles undoing the deletion of a car.
+//
+// Returns the restored car with its new ETag, a 404 error if no car has
+// the given ID, or a 409 error if the car has not been deleted.
+//
+// Method: POST
+// Path: /cars/{id}/restore
+func (c *CarController) Restore(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	id, err := getIDParam(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	car, err := c.service.Restore(r.Context(), id)
+	if err != nil {
+		log.Printf("error restoring car id=%s: %v", id, err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", httpx.ETag(car.Revision))
+
+	if err := httpx.JSON(w, http.StatusOK, dto.ToResponse(&car)); err != nil {
+		log.Printf("error encoding restored car response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("car restored id=%s", id)
+}
+
+// Revision handles retrieving a car as it was at a given revision,
+// reconstructed from its history.
+//
+// Returns a 400 error if the revision is not a positive integer, or a 404
+// error if no recorded change of the car produced that revision. The
+// response carries the revision as its ETag, and the fields query parameter
+// limits it to the listed fields.
+//
+// Method: GET
+// Path: /cars/{id}/revisions/{rev}
+func (c *CarController) Revision(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	id, err := getIDParam(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	revision, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 64)
+	if err != nil || revision < 1 {
+		httpx.HandleServiceError(w, e.NewValidationError(fmt.Errorf("invalid revision: %q", chi.URLParam(r, "rev"))))
+		return
+	}
+
+	fields, err := parseCarFields(r.URL.Query())
+	if err != nil {
+		log.Printf("error parsing car fields: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	car, err := c.service.FindRevision(id, revision)
+	if err != nil {
+		log.Printf("error retrieving car id=%s revision=%d: %v", id, revision, err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", httpx.ETag(car.Revision))
+
+	if err := httpx.JSON(w, http.StatusOK, fields.Project(dto.ToResponse(&car))); err != nil {
+		log.Printf("error encoding car revision response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("car revision retrieved id=%s revision=%d", id, revision)
+}
+
+// History handles retrieving the audit trail of a car: every change made
+// to it through the API, oldest first, with who made it, when, and the car
+// before and after.
+//
+// The history of deleted and purged cars is still returned. Returns a 404
+// error if the car has no history and does not exist.
+//
+// Method: GET
+// Path: /cars/{id}/history
+func (c *CarController) History(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	id, err := getIDParam(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	history, err := c.service.History(id)
+	if err != nil {
+		log.Printf("error retrieving car history id=%s: %v", id, err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if err := httpx.JSON(w, http.StatusOK, dto.ToHistoryResponse(history)); err != nil {
+		log.Printf("error encoding car history response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("car history retrieved id=%s: %d entries", id, len(history))
+}
+
+// flushResponse flushes the encoder buffer with flush, then the response
+// itself when w supports it.
+func flushResponse(w http.ResponseWriter, flush func() error) error {
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
 // getIDParam extracts id param from the request.
 func getIDParam(r *http.Request) (string, error) {
 	id := strings.TrimSpace(chi.URLParam(r, "id"))
@@ -216,34 +941,297 @@ func getIDParam(r *http.Request) (string, error) {
 	return id, nil
 }
 
-// parseCarFilters converts URL query parameters into a CarFilters struct.
-func parseCarFilters(q url.Values) (models.CarFilters, error) {
+// decodeCarPatch decodes the request body into a CarPatchFunc based on
+// the request Content-Type.
+func decodeCarPatch(r *http.Request) (models.CarPatchFunc, error) {
+	switch contentType := httpx.ContentType(r); contentType {
+	case httpx.MediaTypeMergePatch:
+		req, err := httpx.Decode[dto.MergePatchCarRequest](r)
+		if err != nil {
+			return nil, err
+		}
+		return dto.ToMergePatch(*req), nil
+	case httpx.MediaTypeJSONPatch:
+		ops, err := httpx.DecodeList[jsonpatch.Operation](r)
+		if err != nil {
+			return nil, err
+		}
+		return dto.ToJSONPatch(ops), nil
+	default:
+		return nil, e.NewUnsupportedMediaTypeError(
+			fmt.Errorf("%w: %q", e.ErrUnsupportedMedia, contentType),
+		)
+	}
+}
+
+// parseCarFilters converts the URL query parameters of r into a CarFilters
+// struct.
+func parseCarFilters(r *http.Request) (models.CarFilters, error) {
+	q := r.URL.Query()
+
 	var f models.CarFilters
 	var err error
 
-	if f.Make, err = getQueryParam(q, "make"); err != nil {
+	f.Make = getQueryList(q, "make")
+	f.Model = getQueryList(q, "model")
+	f.Category = getQueryList(q, "category")
+	f.Color = getQueryList(q, "color")
+	f.Package = getQueryList(q, "package")
+
+	for _, yearStr := range getQueryList(q, "year") {
+		year, errParser := strconv.Atoi(yearStr)
+		if errParser != nil {
+			return f, e.NewValidationError(fmt.Errorf("invalid year: %q", yearStr))
+		}
+		f.Year = append(f.Year, year)
+	}
+
+	if f.MinYear, err = getIntQueryParam[int](q, "min_year"); err != nil {
 		return f, err
 	}
 
-	if f.Model, err = getQueryParam(q, "model"); err != nil {
+	if f.MaxYear, err = getIntQueryParam[int](q, "max_year"); err != nil {
 		return f, err
 	}
 
-	yearStr, err := getQueryParam(q, "year")
+	if f.MinPrice, err = getIntQueryParam[int64](q, "min_price"); err != nil {
+		return f, err
+	}
+
+	if f.MaxPrice, err = getIntQueryParam[int64](q, "max_price"); err != nil {
+		return f, err
+	}
+
+	if f.MinMileage, err = getIntQueryParam[int64](q, "min_mileage"); err != nil {
+		return f, err
+	}
+
+	if f.MaxMileage, err = getIntQueryParam[int64](q, "max_mileage"); err != nil {
+		return f, err
+	}
+
+	if f.IncludeDeleted, err = getIncludeDeletedParam(r); err != nil {
+		return f, err
+	}
+
+	limitStr, err := getQueryParam(q, "limit")
 	if err != nil {
 		return f, err
 	}
 
-	if yearStr != "" {
-		year, errParser := strconv.Atoi(yearStr)
+	if limitStr != "" {
+		limit, errParser := strconv.Atoi(limitStr)
+		if errParser != nil || limit < 1 || limit > MaxPageLimit {
+			return f, e.NewValidationError(
+				fmt.Errorf("invalid limit: %q (must be between 1 and %d)", limitStr, MaxPageLimit),
+			)
+		}
+		f.Limit = limit
+	}
+
+	if f.Query, err = getQueryParam(q, "q"); err != nil {
+		return f, err
+	}
+
+	filterStr, err := getQueryParam(q, "filter")
+	if err != nil {
+		return f, err
+	}
+
+	if filterStr != "" {
+		if f.Expr, err = models.ParseCarExpr(filterStr); err != nil {
+			return f, e.NewValidationError(fmt.Errorf("invalid filter: %w", err))
+		}
+	}
+
+	sortStr, err := getQueryParam(q, "sort")
+	if err != nil {
+		return f, err
+	}
+
+	if f.Sort, err = models.ParseCarSort(sortStr); err != nil {
+		return f, e.NewValidationError(fmt.Errorf("invalid sort: %w", err))
+	}
+
+	cursorStr, err := getQueryParam(q, "cursor")
+	if err != nil {
+		return f, err
+	}
+
+	if cursorStr != "" {
+		cursor, errParser := dto.DecodeCursor(cursorStr)
 		if errParser != nil {
-			return f, e.NewValidationError(fmt.Errorf("invalid year: %q", yearStr))
+			return f, e.NewValidationError(fmt.Errorf("%w: %q", errParser, cursorStr))
+		}
+		if !cursor.IssuedFor(f) {
+			return f, e.NewValidationError(
+				fmt.Errorf("%w: cursor was issued for a different ordering", dto.ErrInvalidCursor),
+			)
+		}
+		f.After = &cursor
+		if f.Limit == 0 {
+			f.Limit = DefaultPageLimit
 		}
-		f.Year = &year
 	}
 	return f, nil
 }
 
+// csvUpload returns the CSV file of an import request, sent either as
+// the body or as the "file" field of a multipart form.
+func csvUpload(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+
+	switch contentType := httpx.ContentType(r); contentType {
+	case httpx.MediaTypeCSV:
+		return r.Body, nil
+	case httpx.MediaTypeFormData:
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, e.NewInvalidRequestBodyError(err)
+		}
+		return file, nil
+	default:
+		return nil, e.NewUnsupportedMediaTypeError(
+			fmt.Errorf("%w: %q", e.ErrUnsupportedMedia, contentType),
+		)
+	}
+}
+
+// parseBatchMode parses the mode query parameter of a bulk request and
+// reports whether partial mode was requested.
+func parseBatchMode(q url.Values) (bool, error) {
+	mode, err := getQueryParam(q, "mode")
+	if err != nil {
+		return false, err
+	}
+
+	switch mode {
+	case "", "atomic":
+		return false, nil
+	case "partial":
+		return true, nil
+	default:
+		return false, e.NewValidationError(fmt.Errorf("invalid mode: %q", mode))
+	}
+}
+
+// countErrors returns the number of non-nil errors in errs.
+func countErrors(errs []error) int {
+	var n int
+	for _, err := range errs {
+		if err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// parseCarSelector maps the selector of a bulk request, reporting an
+// invalid filter expression as a validation error.
+func parseCarSelector(req dto.CarSelectorRequest) (models.CarSelector, error) {
+	sel, err := dto.ToSelector(req)
+	if err != nil {
+		return sel, e.NewValidationError(fmt.Errorf("invalid filter: %w", err))
+	}
+	return sel, nil
+}
+
+// parseCarFields parses the fields query parameter into a sparse fieldset.
+func parseCarFields(q url.Values) (dto.CarFields, error) {
+	fields, err := dto.ParseCarFields(getQueryList(q, "fields"))
+	if err != nil {
+		return nil, e.NewValidationError(fmt.Errorf("invalid fields: %w", err))
+	}
+	return fields, nil
+}
+
+// nextPageLink builds a Link header value pointing to the page that
+// starts at cursor, preserving the other query parameters of u.
+func nextPageLink(u *url.URL, cursor string) string {
+	q := u.Query()
+	q.Set("cursor", cursor)
+
+	next := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return fmt.Sprintf("<%s>; rel=\"next\"", next.String())
+}
+
+// getQueryList returns every value given for key, accepting both repeated
+// keys (make=Ford&make=Toyota) and comma-separated values (make=Ford,Toyota).
+// Values are trimmed and empty values are dropped.
+func getQueryList(q url.Values, key string) []string {
+	var list []string
+	for _, value := range q[key] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
+// getIntQueryParam parses the value of key as a base-10 integer.
+// It returns nil when the parameter is absent.
+func getIntQueryParam[T int | int64](q url.Values, key string) (*T, error) {
+	str, err := getQueryParam(q, key)
+	if err != nil || str == "" {
+		return nil, err
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || int64(T(n)) != n {
+		return nil, e.NewValidationError(fmt.Errorf("invalid %s: %q", key, str))
+	}
+
+	v := T(n)
+	return &v, nil
+}
+
+// getTimeQueryParam parses the value of key as an RFC 3339 time.
+// It returns nil when the parameter is absent.
+func getTimeQueryParam(q url.Values, key string) (*time.Time, error) {
+	str, err := getQueryParam(q, key)
+	if err != nil || str == "" {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return nil, e.NewValidationError(fmt.Errorf("invalid %s: %q", key, str))
+	}
+	return &t, nil
+}
+
+// getBoolQueryParam parses the value of key as a boolean such as "true"
+// or "0". It returns false when the parameter is absent.
+func getBoolQueryParam(q url.Values, key string) (bool, error) {
+	str, err := getQueryParam(q, key)
+	if err != nil || str == "" {
+		return false, err
+	}
+
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, e.NewValidationError(fmt.Errorf("invalid %s: %q", key, str))
+	}
+	return b, nil
+}
+
+// getIncludeDeletedParam parses the include_deleted query parameter of r.
+// Deleted cars are only shown to admins (see middleware.Admin), so asking
+// for them without admin access is an error.
+func getIncludeDeletedParam(r *http.Request) (bool, error) {
+	includeDeleted, err := getBoolQueryParam(r.URL.Query(), "include_deleted")
+	if err != nil || !includeDeleted {
+		return false, err
+	}
+
+	if admin, _ := r.Context().Value(contextkeys.AdminKey).(bool); !admin {
+		return false, e.NewAdminRequiredError(fmt.Errorf("include_deleted: %w", e.ErrAdminRequired))
+	}
+	return true, nil
+}
+
 // getQueryParam returns the first value given a key.
 func getQueryParam(q url.Values, key string) (string, error) {
 	values, ok := q[key]
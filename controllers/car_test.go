@@ -1,60 +1,122 @@
 package controllers
 
 import (
+	"bytes"
 	"cars/api/dto"
+	"cars/data"
 	"cars/models"
 	e "cars/pkg/errors"
 	"cars/pkg/httpx"
+	"cars/pkg/middleware"
 	u "cars/pkg/utils"
+	"cars/repositories"
 	"cars/services"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// testAdminToken is the admin token of the routers under test.
+const testAdminToken = "s3cret"
+
 type MockCarRepository struct {
-	FindFn   func(id string) (models.Car, error)
-	ListFn   func(filters models.CarFilters) (models.Cars, error)
-	CreateFn func(car *models.Car) error
-	UpdateFn func(car *models.Car) error
-	DeleteFn func(id string) error
+	FindFn        func(id string, includeDeleted bool) (models.Car, error)
+	ListFn        func(filters models.CarFilters) (models.Cars, error)
+	EachFn        func(filters models.CarFilters, fn func(models.Car) bool) error
+	MakesFn       func() ([]models.Facet[string], error)
+	ModelsFn      func(make string) ([]models.Facet[string], error)
+	CreateFn      func(car *models.Car) error
+	InsertFn      func(car *models.Car) error
+	CreateBatchFn func(cars models.Cars) error
+	UpdateFn      func(car *models.Car) (models.Car, error)
+	PatchFn       func(id string, patch models.CarPatchFunc) (models.Car, error)
+	DeleteFn      func(id string, revision int64) (models.Car, error)
+	UpdateBatchFn func(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error)
+	DeleteBatchFn func(sel models.CarSelector, dryRun bool) (models.Cars, error)
+	RestoreFn     func(id string) (models.Car, error)
+	PurgeFn       func(before time.Time) (int, error)
 }
 
-func (m *MockCarRepository) Find(id string) (models.Car, error) {
-	return m.FindFn(id)
+func (m *MockCarRepository) Find(id string, includeDeleted bool) (models.Car, error) {
+	return m.FindFn(id, includeDeleted)
 }
 func (m *MockCarRepository) List(filters models.CarFilters) (models.Cars, error) {
 	return m.ListFn(filters)
 }
+func (m *MockCarRepository) Each(filters models.CarFilters, fn func(models.Car) bool) error {
+	return m.EachFn(filters, fn)
+}
+func (m *MockCarRepository) Makes() ([]models.Facet[string], error) {
+	return m.MakesFn()
+}
+func (m *MockCarRepository) Models(make string) ([]models.Facet[string], error) {
+	return m.ModelsFn(make)
+}
 func (m *MockCarRepository) Create(car *models.Car) error {
 	return m.CreateFn(car)
 }
-func (m *MockCarRepository) Update(car *models.Car) error {
+func (m *MockCarRepository) Insert(car *models.Car) error {
+	return m.InsertFn(car)
+}
+func (m *MockCarRepository) CreateBatch(cars models.Cars) error {
+	return m.CreateBatchFn(cars)
+}
+func (m *MockCarRepository) Update(car *models.Car) (models.Car, error) {
 	return m.UpdateFn(car)
 }
 
-func (m *MockCarRepository) Delete(id string) error {
-	return m.DeleteFn(id)
+func (m *MockCarRepository) Patch(id string, patch models.CarPatchFunc) (models.Car, error) {
+	return m.PatchFn(id, patch)
+}
+
+func (m *MockCarRepository) Delete(id string, revision int64) (models.Car, error) {
+	return m.DeleteFn(id, revision)
+}
+
+func (m *MockCarRepository) UpdateBatch(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+	return m.UpdateBatchFn(sel, patch, dryRun)
+}
+
+func (m *MockCarRepository) DeleteBatch(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+	return m.DeleteBatchFn(sel, dryRun)
+}
+
+func (m *MockCarRepository) Restore(id string) (models.Car, error) {
+	return m.RestoreFn(id)
+}
+
+func (m *MockCarRepository) Purge(before time.Time) (int, error) {
+	return m.PurgeFn(before)
 }
 
 func Test_Car_Get(t *testing.T) {
+	deletedAt := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
 	tCases := []struct {
 		name             string
 		idParam          string
-		findFn           func(id string) (models.Car, error)
+		query            string
+		admin            bool
+		findFn           func(id string, includeDeleted bool) (models.Car, error)
 		expectedStatus   int
 		expectedResponse any
 	}{
 		{
 			name:    "car not found",
 			idParam: "DEF456",
-			findFn: func(id string) (models.Car, error) {
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
 				return models.Car{}, e.ErrCarNotFound
 			},
 			expectedStatus:   http.StatusNotFound,
@@ -63,7 +125,7 @@ func Test_Car_Get(t *testing.T) {
 		{
 			name:    "repository error",
 			idParam: "ABC123",
-			findFn: func(id string) (models.Car, error) {
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
 				return models.Car{}, errors.New("repository error")
 			},
 			expectedStatus:   http.StatusInternalServerError,
@@ -72,7 +134,7 @@ func Test_Car_Get(t *testing.T) {
 		{
 			name:    "car found",
 			idParam: "ABC123",
-			findFn: func(id string) (models.Car, error) {
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
 				return models.Car{
 					ID:       "ABC123",
 					Make:     "Chevrolet",
@@ -92,6 +154,46 @@ func Test_Car_Get(t *testing.T) {
 				Year:     2025,
 			},
 		},
+		{
+			name:    "deleted car hidden by default",
+			idParam: "ABC123",
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
+				if includeDeleted {
+					t.Fatal("expected deleted cars to be excluded")
+				}
+				return models.Car{}, e.ErrCarNotFound
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: httpx.ErrorResponse{Message: "Car not found"},
+		},
+		{
+			name:    "deleted car included on request",
+			idParam: "ABC123",
+			query:   "?include_deleted=true",
+			admin:   true,
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
+				if !includeDeleted {
+					t.Fatal("expected deleted cars to be included")
+				}
+				return models.Car{ID: "ABC123", Make: "Chevrolet", Revision: 2, DeletedAt: &deletedAt}, nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: dto.CarResponse{ID: "ABC123", Make: "Chevrolet", Revision: 2, DeletedAt: &deletedAt},
+		},
+		{
+			name:             "deleted car requested without admin access",
+			idParam:          "ABC123",
+			query:            "?include_deleted=true",
+			expectedStatus:   http.StatusForbidden,
+			expectedResponse: httpx.ErrorResponse{Message: "Admin access required"},
+		},
+		{
+			name:             "invalid include_deleted",
+			idParam:          "ABC123",
+			query:            "?include_deleted=maybe",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
 	}
 
 	for _, tc := range tCases {
@@ -99,16 +201,21 @@ func Test_Car_Get(t *testing.T) {
 			controller := NewCarController(
 				services.NewCarService(
 					&MockCarRepository{FindFn: tc.findFn},
+					nil,
 				),
 			)
 
 			router := chi.NewRouter()
+			router.Use(middleware.Admin(testAdminToken))
 			router.Route("/cars", func(r chi.Router) {
 				r.Get("/{id:[A-Za-z0-9-]+}", controller.Get)
 			})
 
 			resp := httptest.NewRecorder()
-			req := httptest.NewRequest("GET", "/cars/"+tc.idParam, nil)
+			req := httptest.NewRequest("GET", "/cars/"+tc.idParam+tc.query, nil)
+			if tc.admin {
+				req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			}
 
 			router.ServeHTTP(resp, req)
 
@@ -145,6 +252,7 @@ func Test_Car_List(t *testing.T) {
 	tCases := []struct {
 		name             string
 		queryParams      string
+		admin            bool
 		listFn           func(f models.CarFilters) (models.Cars, error)
 		expectedStatus   int
 		expectedResponse any
@@ -169,8 +277,8 @@ func Test_Car_List(t *testing.T) {
 			name:        "list filtered by make",
 			queryParams: "?make=Toyota",
 			listFn: func(f models.CarFilters) (models.Cars, error) {
-				if f.Make != "Toyota" {
-					t.Errorf("expected make %q, got %q", "Toyota", f.Make)
+				if !reflect.DeepEqual(f.Make, []string{"Toyota"}) {
+					t.Errorf("expected make %q, got %q", []string{"Toyota"}, f.Make)
 				}
 				return models.Cars{
 					{ID: "DEF456", Make: "Toyota", Model: "Yaris", Package: u.Ptr("DEF"), Color: "Red", Category: "Sedan", Year: 2025},
@@ -185,8 +293,8 @@ func Test_Car_List(t *testing.T) {
 			name:        "list filtered by model",
 			queryParams: "?model=Arkana",
 			listFn: func(f models.CarFilters) (models.Cars, error) {
-				if f.Model != "Arkana" {
-					t.Errorf("expected model %q, got %q", "Arkana", f.Model)
+				if !reflect.DeepEqual(f.Model, []string{"Arkana"}) {
+					t.Errorf("expected model %q, got %q", []string{"Arkana"}, f.Model)
 				}
 				return models.Cars{
 					{ID: "GHI789", Make: "Renault", Model: "Arkana", Package: u.Ptr("GHI"), Color: "White", Category: "Sedan", Year: 2025},
@@ -201,11 +309,8 @@ func Test_Car_List(t *testing.T) {
 			name:        "list filtered by year",
 			queryParams: "?year=2025",
 			listFn: func(f models.CarFilters) (models.Cars, error) {
-				if f.Year == nil {
-					t.Fatal("expected year filter")
-				}
-				if *f.Year != 2025 {
-					t.Errorf("expected year %d, got %d", 2025, *f.Year)
+				if !reflect.DeepEqual(f.Year, []int{2025}) {
+					t.Errorf("expected year %v, got %v", []int{2025}, f.Year)
 				}
 				return models.Cars{
 					{ID: "ABC123", Make: "Chevrolet", Model: "Onix", Color: "Black", Category: "Sedan", Year: 2025},
@@ -222,6 +327,183 @@ func Test_Car_List(t *testing.T) {
 			expectedStatus:   http.StatusBadRequest,
 			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
 		},
+		{
+			name:        "list filtered by multiple values",
+			queryParams: "?make=Ford&make=Toyota&category=SUV,Truck&color=Red&package=XSE&year=2018,2022",
+			listFn: func(f models.CarFilters) (models.Cars, error) {
+				expected := models.CarFilters{
+					Make:     []string{"Ford", "Toyota"},
+					Category: []string{"SUV", "Truck"},
+					Color:    []string{"Red"},
+					Package:  []string{"XSE"},
+					Year:     []int{2018, 2022},
+				}
+				f.Limit, f.Sort = 0, nil
+				if !reflect.DeepEqual(f, expected) {
+					t.Errorf("expected filters %+v, got %+v", expected, f)
+				}
+				return models.Cars{}, nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []dto.CarResponse{},
+		},
+		{
+			name:             "invalid year in list",
+			queryParams:      "?year=2020,MMXXV",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: "MMXXV"},
+		},
+		{
+			name:        "list filtered by ranges",
+			queryParams: "?min_year=2018&max_year=2024&min_price=1000000&max_price=2500000&min_mileage=0&max_mileage=50000",
+			listFn: func(f models.CarFilters) (models.Cars, error) {
+				expected := models.CarFilters{
+					MinYear:    u.Ptr(2018),
+					MaxYear:    u.Ptr(2024),
+					MinPrice:   u.Ptr(int64(1000000)),
+					MaxPrice:   u.Ptr(int64(2500000)),
+					MinMileage: u.Ptr(int64(0)),
+					MaxMileage: u.Ptr(int64(50000)),
+				}
+				f.Limit, f.Sort = 0, nil
+				if !reflect.DeepEqual(f, expected) {
+					t.Errorf("expected filters %+v, got %+v", expected, f)
+				}
+				return models.Cars{}, nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []dto.CarResponse{},
+		},
+		{
+			name:        "list including deleted cars",
+			queryParams: "?include_deleted=true",
+			admin:       true,
+			listFn: func(f models.CarFilters) (models.Cars, error) {
+				if !f.IncludeDeleted {
+					t.Errorf("expected deleted cars to be included")
+				}
+				return models.Cars{{ID: "ABC123", Make: "Chevrolet", Revision: 2, DeletedAt: u.Ptr(time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC))}}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: []dto.CarResponse{
+				{ID: "ABC123", Make: "Chevrolet", Revision: 2, DeletedAt: u.Ptr(time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC))},
+			},
+		},
+		{
+			name:             "list including deleted cars without admin access",
+			queryParams:      "?include_deleted=true",
+			expectedStatus:   http.StatusForbidden,
+			expectedResponse: httpx.ErrorResponse{Message: "Admin access required", Details: "include_deleted"},
+		},
+		{
+			name:             "invalid include_deleted",
+			queryParams:      "?include_deleted=yes",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: "include_deleted"},
+		},
+		{
+			name:             "invalid min price",
+			queryParams:      "?min_price=12.50",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: "min_price"},
+		},
+		{
+			name:             "min year greater than max year",
+			queryParams:      "?min_year=2024&max_year=2020",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: "year"},
+		},
+		{
+			name:             "invalid limit",
+			queryParams:      "?limit=0",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "limit above maximum",
+			queryParams:      "?limit=100000",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:        "list sorted by multiple keys",
+			queryParams: "?sort=-price,year",
+			listFn: func(f models.CarFilters) (models.Cars, error) {
+				expected := []models.CarSort{{Field: models.SortByPrice, Desc: true}, {Field: models.SortByYear}}
+				if !reflect.DeepEqual(f.Sort, expected) {
+					t.Errorf("expected sort %+v, got %+v", expected, f.Sort)
+				}
+				return models.Cars{}, nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []dto.CarResponse{},
+		},
+		{
+			name:        "list matching a search query",
+			queryParams: "?q=red+toyota+rav4",
+			listFn: func(f models.CarFilters) (models.Cars, error) {
+				if f.Query != "red toyota rav4" {
+					t.Errorf("expected query %q, got %q", "red toyota rav4", f.Query)
+				}
+				return models.Cars{}, nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []dto.CarResponse{},
+		},
+		{
+			name:        "list matching a filter expression",
+			queryParams: "?filter=" + url.QueryEscape("(make = Toyota AND price < 2500000) OR category = Truck"),
+			listFn: func(f models.CarFilters) (models.Cars, error) {
+				if f.Expr == nil {
+					t.Error("expected filter expression")
+				}
+				return models.Cars{}, nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: []dto.CarResponse{},
+		},
+		{
+			name:             "filter expression with syntax error",
+			queryParams:      "?filter=" + url.QueryEscape("make = Toyota AND (year > 2020"),
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: "position 31"},
+		},
+		{
+			name:             "filter expression with unknown field",
+			queryParams:      "?filter=" + url.QueryEscape("colour = Red"),
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: `position 1: unknown field "colour"`},
+		},
+		{
+			name:             "search query without searchable terms",
+			queryParams:      "?q=%2B%2B",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: "searchable"},
+		},
+		{
+			name:             "cursor issued for an unranked listing",
+			queryParams:      "?q=toyota&cursor=" + dto.EncodeCursor(models.CarCursor{ID: "ABC123"}),
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "unknown sort field",
+			queryParams:      "?sort=-price,color",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed", Details: `"color"`},
+		},
+		{
+			name:             "cursor issued for a different sort",
+			queryParams:      "?sort=year&cursor=" + dto.EncodeCursor(models.CarCursor{ID: "ABC123"}),
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "invalid cursor",
+			queryParams:      "?cursor=not-a-cursor",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
 		{
 			name: "repository error",
 			listFn: func(f models.CarFilters) (models.Cars, error) {
@@ -237,15 +519,20 @@ func Test_Car_List(t *testing.T) {
 			controller := NewCarController(
 				services.NewCarService(
 					&MockCarRepository{ListFn: tc.listFn},
+					nil,
 				),
 			)
 
 			router := chi.NewRouter()
+			router.Use(middleware.Admin(testAdminToken))
 			router.Route("/cars", func(r chi.Router) {
 				r.Get("/", controller.List)
 			})
 
 			req := httptest.NewRequest(http.MethodGet, "/cars"+tc.queryParams, nil)
+			if tc.admin {
+				req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			}
 
 			resp := httptest.NewRecorder()
 
@@ -266,6 +553,10 @@ func Test_Car_List(t *testing.T) {
 				if got.Message != expected.Message {
 					t.Errorf("expected message %q, got %q", expected.Message, got.Message)
 				}
+
+				if !strings.Contains(got.Details, expected.Details) {
+					t.Errorf("expected details to contain %q, got %q", expected.Details, got.Details)
+				}
 			case []dto.CarResponse:
 				var got []dto.CarResponse
 				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
@@ -359,6 +650,7 @@ func Test_Car_Create(t *testing.T) {
 			controller := NewCarController(
 				services.NewCarService(
 					&MockCarRepository{CreateFn: tc.createFn},
+					nil,
 				),
 			)
 
@@ -409,117 +701,126 @@ func Test_Car_Create(t *testing.T) {
 	}
 }
 
-func Test_Car_Update(t *testing.T) {
+func Test_Car_CreateBatch(t *testing.T) {
+	const onix = `{"make":"Chevrolet","model":"Onix","color":"Gray","category":"Sedan","year":2025}`
+	const invalid = `{"make":"Chevrolet","color":"Gray","category":"Sedan","year":2025}`
+
+	createBatchFn := func(cars models.Cars) error {
+		for i := range cars {
+			cars[i].ID = fmt.Sprintf("A%d", i+1)
+			cars[i].Revision = 1
+		}
+		return nil
+	}
+
+	created := func(id string) *dto.CarResponse {
+		return &dto.CarResponse{
+			ID: id, Make: "Chevrolet", Model: "Onix", Color: "Gray", Category: "Sedan", Year: 2025, Revision: 1,
+		}
+	}
+
 	tCases := []struct {
 		name             string
-		idParam          string
+		target           string
 		body             string
-		updateFn         func(car *models.Car) error
+		createBatchFn    func(cars models.Cars) error
 		expectedStatus   int
 		expectedResponse any
 	}{
 		{
-			name:             "invalid request body: missing comma",
-			idParam:          "ABC123",
-			body:             `{"make": "Chevrolet" "model": "Onix"}`,
-			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
-		},
-		{
-			name:             "year is missing",
-			idParam:          "ABC123",
-			body:             `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan"}`,
+			name:             "should reject body that is not an array",
+			target:           "/cars/bulk",
+			body:             onix,
 			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInvalidRequestBody},
 		},
 		{
-			name:             "category is missing",
-			idParam:          "ABC123",
-			body:             `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "year":2025}`,
+			name:             "should reject empty array",
+			target:           "/cars/bulk",
+			body:             `[]`,
 			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInvalidRequestBody},
 		},
 		{
-			name:             "color is missing",
-			idParam:          "ABC123",
-			body:             `{"make":"Chevrolet", "model":"Onix", "category":"Sedan", "year":2025}`,
+			name:             "should reject unknown mode",
+			target:           "/cars/bulk?mode=best-effort",
+			body:             "[" + onix + "]",
 			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: `invalid mode: "best-effort"`},
 		},
 		{
-			name:             "model is missing",
-			idParam:          "ABC123",
-			body:             `{"make":"Chevrolet", "color":"Gray", "category":"Sedan", "year":2025}`,
-			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+			name:           "should create every car in atomic mode",
+			target:         "/cars/bulk",
+			body:           "[" + onix + "," + onix + "]",
+			createBatchFn:  createBatchFn,
+			expectedStatus: http.StatusCreated,
+			expectedResponse: []dto.CarResponse{
+				*created("A1"), *created("A2"),
+			},
 		},
 		{
-			name:             "make is missing",
-			idParam:          "ABC123",
-			body:             `{"model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
+			name:             "should create nothing when an item is invalid in atomic mode",
+			target:           "/cars/bulk?mode=atomic",
+			body:             "[" + onix + "," + invalid + "]",
 			expectedStatus:   http.StatusBadRequest,
-			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: "item 1: model is required"},
 		},
 		{
-			name:    "car not found",
-			idParam: "ABC123",
-			body:    `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
-			updateFn: func(car *models.Car) error {
-				return e.ErrCarNotFound
+			name:           "should report a result per item in partial mode",
+			target:         "/cars/bulk?mode=partial",
+			body:           "[" + invalid + "," + onix + "]",
+			createBatchFn:  createBatchFn,
+			expectedStatus: http.StatusOK,
+			expectedResponse: []dto.CarBatchItemResponse{
+				{
+					Index:  0,
+					Status: http.StatusBadRequest,
+					Error: &httpx.ErrorResponse{
+						Code:    e.CodeValidationFailed,
+						Message: e.MsgValidationFailed,
+						Details: "model is required",
+					},
+				},
+				{Index: 1, Status: http.StatusCreated, Car: created("A1")},
 			},
-			expectedStatus:   http.StatusNotFound,
-			expectedResponse: httpx.ErrorResponse{Message: "Car not found"},
 		},
 		{
-			name:    "repository error",
-			idParam: "ABC123",
-			body:    `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
-			updateFn: func(car *models.Car) error {
+			name:   "should fail the whole request when repository fails",
+			target: "/cars/bulk?mode=partial",
+			body:   "[" + onix + "]",
+			createBatchFn: func(cars models.Cars) error {
 				return errors.New("repository error")
 			},
 			expectedStatus:   http.StatusInternalServerError,
-			expectedResponse: httpx.ErrorResponse{Message: "Internal server error"},
-		},
-		{
-			name:    "car updated successfully",
-			idParam: "ABC123",
-			body:    `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
-			updateFn: func(car *models.Car) error {
-				return nil
-			},
-			expectedStatus: http.StatusOK,
-			expectedResponse: models.Car{
-				ID:       "ABC123",
-				Make:     "Chevrolet",
-				Model:    "Onix",
-				Color:    "Gray",
-				Category: "Sedan",
-				Year:     2025},
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInternalError},
 		},
 	}
 
 	for _, tc := range tCases {
 		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
 			controller := NewCarController(
 				services.NewCarService(
-					&MockCarRepository{UpdateFn: tc.updateFn},
+					&MockCarRepository{CreateBatchFn: tc.createBatchFn},
+					nil,
 				),
 			)
 
 			router := chi.NewRouter()
 			router.Route("/cars", func(r chi.Router) {
-				r.Put("/{id:[A-Za-z0-9-]+}", controller.Update)
+				r.Post("/bulk", controller.CreateBatch)
 			})
 
 			resp := httptest.NewRecorder()
-			req := httptest.NewRequest("PUT", "/cars/"+tc.idParam, strings.NewReader(tc.body))
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
 			req.Header.Set("Content-Type", "application/json")
 
+			// Act
 			router.ServeHTTP(resp, req)
 
-			// Check status code
+			// Assert
 			if resp.Code != tc.expectedStatus {
-				t.Fatalf("expected status %v, got %v", tc.expectedStatus, resp.Code)
+				t.Fatalf("expected status %v, got %v: %s", tc.expectedStatus, resp.Code, resp.Body.String())
 			}
 
 			switch expected := tc.expectedResponse.(type) {
@@ -529,19 +830,2089 @@ func Test_Car_Update(t *testing.T) {
 					t.Fatal(err)
 				}
 
-				if got.Message != expected.Message {
-					t.Fatalf("expected error %v, got %v", expected.Message, got.Message)
+				if got.Code != expected.Code || !strings.Contains(got.Details, expected.Details) {
+					t.Fatalf("expected error %+v, got %+v", expected, got)
 				}
-			case dto.CarResponse:
-				var gotCar dto.CarResponse
-				if err := json.Unmarshal(resp.Body.Bytes(), &gotCar); err != nil {
+			case []dto.CarResponse:
+				var got []dto.CarResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
 					t.Fatal(err)
 				}
 
-				if !reflect.DeepEqual(gotCar, expected) {
-					t.Fatalf("expected car %+v, got %+v", expected, gotCar)
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("expected cars %+v, got %+v", expected, got)
+				}
+			case []dto.CarBatchItemResponse:
+				var got []dto.CarBatchItemResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("expected results %+v, got %+v", expected, got)
 				}
 			}
 		})
 	}
 }
+
+func Test_Car_UpdateBatch(t *testing.T) {
+	stored := models.Cars{
+		{ID: "A1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010},
+		{ID: "B2", Make: "Toyota", Model: "Camry", Color: "White", Category: "Sedan", Year: 2019},
+	}
+
+	// updateBatchFn patches the stored cars matching the selector.
+	updateBatchFn := func(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+		var patched models.Cars
+		for _, car := range stored {
+			if sel.Filters != nil && !sel.Filters.Match(car) || sel.IDs != nil && !slices.Contains(sel.IDs, car.ID) {
+				continue
+			}
+			if err := patch(&car); err != nil {
+				return nil, err
+			}
+			patched = append(patched, car)
+		}
+		return patched, nil
+	}
+
+	tCases := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedResponse any
+	}{
+		{
+			name:             "should update the cars matching a filter",
+			body:             `{"filter":"year = 2010 AND category = Truck","patch":{"price":1500000}}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: dto.BulkResultResponse{Affected: 1},
+		},
+		{
+			name:             "should count the cars with the given IDs in a dry run",
+			body:             `{"ids":["A1","B2","C3"],"patch":{"color":"Black"},"dry_run":true}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: dto.BulkResultResponse{Affected: 2, DryRun: true},
+		},
+		{
+			name:             "should reject missing selector",
+			body:             `{"patch":{"color":"Black"}}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: models.ErrSelectorRequired.Error()},
+		},
+		{
+			name:             "should reject ids combined with filter",
+			body:             `{"ids":["A1"],"filter":"year = 2010","patch":{"color":"Black"}}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: models.ErrSelectorConflict.Error()},
+		},
+		{
+			name:             "should reject invalid filter",
+			body:             `{"filter":"vin = 1","patch":{"color":"Black"}}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: "invalid filter: at position 1"},
+		},
+		{
+			name:             "should reject missing patch",
+			body:             `{"ids":["A1"]}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: "patch is required"},
+		},
+		{
+			name:             "should reject patch leaving a car invalid",
+			body:             `{"filter":"year >= 2010","patch":{"make":""}}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: "car A1: make is required"},
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			controller := NewCarController(
+				services.NewCarService(
+					&MockCarRepository{UpdateBatchFn: updateBatchFn},
+					nil,
+				),
+			)
+
+			router := chi.NewRouter()
+			router.Post("/cars/bulk/update", controller.UpdateBatch)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/cars/bulk/update", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v, got %v: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			assertBulkResponse(t, resp, tc.expectedResponse)
+		})
+	}
+}
+
+func Test_Car_DeleteBatch(t *testing.T) {
+	tCases := []struct {
+		name             string
+		body             string
+		deleteBatchFn    func(sel models.CarSelector, dryRun bool) (models.Cars, error)
+		expectedStatus   int
+		expectedResponse any
+	}{
+		{
+			name: "should delete the cars with the given IDs",
+			body: `{"ids":["A1","B2"]}`,
+			deleteBatchFn: func(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+				if !reflect.DeepEqual(sel.IDs, []string{"A1", "B2"}) || dryRun {
+					return nil, fmt.Errorf("unexpected arguments %+v, %v", sel, dryRun)
+				}
+				return make(models.Cars, 2), nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: dto.BulkResultResponse{Affected: 2},
+		},
+		{
+			name: "should count the cars matching a filter in a dry run",
+			body: `{"filter":"make = Ford","dry_run":true}`,
+			deleteBatchFn: func(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+				if sel.Filters == nil || sel.Filters.Expr == nil || !dryRun {
+					return nil, fmt.Errorf("unexpected arguments %+v, %v", sel, dryRun)
+				}
+				return make(models.Cars, 7), nil
+			},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: dto.BulkResultResponse{Affected: 7, DryRun: true},
+		},
+		{
+			name:             "should reject empty body",
+			body:             `{}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInvalidRequestBody},
+		},
+		{
+			name:             "should reject missing selector",
+			body:             `{"dry_run":true}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: models.ErrSelectorRequired.Error()},
+		},
+		{
+			name: "should return internal error when repository fails",
+			body: `{"ids":["A1"]}`,
+			deleteBatchFn: func(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+				return nil, errors.New("repository error")
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInternalError},
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			controller := NewCarController(
+				services.NewCarService(
+					&MockCarRepository{DeleteBatchFn: tc.deleteBatchFn},
+					nil,
+				),
+			)
+
+			router := chi.NewRouter()
+			router.Post("/cars/bulk/delete", controller.DeleteBatch)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/cars/bulk/delete", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v, got %v: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			assertBulkResponse(t, resp, tc.expectedResponse)
+		})
+	}
+}
+
+// assertBulkResponse checks the body of a bulk update or delete response.
+func assertBulkResponse(t *testing.T, resp *httptest.ResponseRecorder, expected any) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case httpx.ErrorResponse:
+		var got httpx.ErrorResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Code != expected.Code || !strings.Contains(got.Details, expected.Details) {
+			t.Fatalf("expected error %+v, got %+v", expected, got)
+		}
+	case dto.BulkResultResponse:
+		var got dto.BulkResultResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if got != expected {
+			t.Fatalf("expected result %+v, got %+v", expected, got)
+		}
+	}
+}
+
+func Test_Car_Import(t *testing.T) {
+	const header = "Make,Model,Color,Category,Year,Package,Mileage,Price\n"
+
+	tCases := []struct {
+		name             string
+		target           string
+		contentType      string
+		body             string
+		expectedStatus   int
+		expectedCars     models.Cars
+		expectedResponse any
+	}{
+		{
+			name:        "should import every row and convert dollars to cents",
+			target:      "/cars/import",
+			contentType: "text/csv",
+			body: header +
+				`Toyota,Camry,White,Sedan,2019,SE,"3,999","28,990.00"` + "\n" +
+				`Kia,Rio,Blue,Sedan,2021,,,$15000.5` + "\n" +
+				`Ford,Bronco,Red,SUV,2022,,,28990` + "\n",
+			expectedStatus: http.StatusCreated,
+			expectedCars: models.Cars{
+				{
+					ID: "A1", Make: "Toyota", Model: "Camry", Color: "White", Category: "Sedan", Year: 2019,
+					Package: u.Ptr("SE"), Mileage: u.Ptr(int64(3999)), Price: u.Ptr(int64(2899000)),
+				},
+				{ID: "A2", Make: "Kia", Model: "Rio", Color: "Blue", Category: "Sedan", Year: 2021, Price: u.Ptr(int64(1500050))},
+				{ID: "A3", Make: "Ford", Model: "Bronco", Color: "Red", Category: "SUV", Year: 2022, Price: u.Ptr(int64(2899000))},
+			},
+			expectedResponse: dto.CarImportResponse{
+				Imported: 3,
+				Created:  []dto.CarImportedRow{{Row: 2, ID: "A1"}, {Row: 3, ID: "A2"}, {Row: 4, ID: "A3"}},
+				Errors:   []dto.CarImportError{},
+			},
+		},
+		{
+			name:        "should accept columns in any order and case",
+			target:      "/cars/import",
+			contentType: "text/csv; charset=utf-8",
+			body:        "\ufeffyear, CATEGORY ,color,model,make\n2020,SUV,Red,Bronco,Ford\n",
+			expectedCars: models.Cars{
+				{ID: "A1", Make: "Ford", Model: "Bronco", Color: "Red", Category: "SUV", Year: 2020},
+			},
+			expectedStatus: http.StatusCreated,
+			expectedResponse: dto.CarImportResponse{
+				Imported: 1,
+				Created:  []dto.CarImportedRow{{Row: 2, ID: "A1"}},
+				Errors:   []dto.CarImportError{},
+			},
+		},
+		{
+			name:        "should import nothing and report every rejected row in atomic mode",
+			target:      "/cars/import",
+			contentType: "text/csv",
+			body: header +
+				"Toyota,Camry,White,Sedan,2019,,,\n" +
+				"Ford,,Red,SUV,2020,,,\n" +
+				"Ford,Bronco,Red,SUV,2020,,,28.999\n" +
+				"Ford,Bronco,Red,SUV\n" +
+				"Ford,Bronco,Red,SUV,2020,,\"1,00\",\n",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedResponse: dto.CarImportResponse{
+				Created: []dto.CarImportedRow{},
+				Errors: []dto.CarImportError{
+					{Row: 3, Message: "model is required"},
+					{Row: 4, Column: "price", Message: `invalid price: "28.999"`},
+					{Row: 5, Message: "expected 8 fields, got 4"},
+					{Row: 6, Column: "mileage", Message: `invalid mileage: "1,00"`},
+				},
+			},
+		},
+		{
+			name:        "should import the valid rows in partial mode",
+			target:      "/cars/import?mode=partial",
+			contentType: "text/csv",
+			body:        header + "Ford,,Red,SUV,2020,,,\nKia,Rio,Blue,Sedan,2021,,-1,\nKia,Rio,Blue,Sedan,2021,,,\n",
+			expectedCars: models.Cars{
+				{ID: "A1", Make: "Kia", Model: "Rio", Color: "Blue", Category: "Sedan", Year: 2021},
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: dto.CarImportResponse{
+				Imported: 1,
+				Created:  []dto.CarImportedRow{{Row: 4, ID: "A1"}},
+				Errors: []dto.CarImportError{
+					{Row: 2, Message: "model is required"},
+					{Row: 3, Message: "mileage cannot be negative"},
+				},
+			},
+		},
+		{
+			name:             "should reject unknown column",
+			target:           "/cars/import",
+			contentType:      "text/csv",
+			body:             "make,model,color,category,year,vin\n",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInvalidRequestBody, Details: `invalid CSV header: unknown column "vin"`},
+		},
+		{
+			name:             "should reject missing required column",
+			target:           "/cars/import",
+			contentType:      "text/csv",
+			body:             "make,model,color,category\n",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeInvalidRequestBody, Details: `invalid CSV header: missing column "year"`},
+		},
+		{
+			name:             "should reject file without rows",
+			target:           "/cars/import",
+			contentType:      "text/csv",
+			body:             header,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeValidationFailed, Details: "file has no rows to import"},
+		},
+		{
+			name:             "should reject unsupported media type",
+			target:           "/cars/import",
+			contentType:      "application/json",
+			body:             `[]`,
+			expectedStatus:   http.StatusUnsupportedMediaType,
+			expectedResponse: httpx.ErrorResponse{Code: e.CodeUnsupportedMediaType},
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var created models.Cars
+			repo := &MockCarRepository{
+				CreateBatchFn: func(cars models.Cars) error {
+					for i := range cars {
+						cars[i].ID = fmt.Sprintf("A%d", i+1)
+					}
+					created = cars
+					return nil
+				},
+			}
+
+			controller := NewCarController(services.NewCarService(repo, nil))
+
+			router := chi.NewRouter()
+			router.Post("/cars/import", controller.Import)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v, got %v: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if !reflect.DeepEqual(created, tc.expectedCars) {
+				t.Fatalf("expected created cars %+v, got %+v", tc.expectedCars, created)
+			}
+
+			switch expected := tc.expectedResponse.(type) {
+			case httpx.ErrorResponse:
+				var got httpx.ErrorResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if got.Code != expected.Code || !strings.Contains(got.Details, expected.Details) {
+					t.Fatalf("expected error %+v, got %+v", expected, got)
+				}
+			case dto.CarImportResponse:
+				var got dto.CarImportResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("expected report %+v, got %+v", expected, got)
+				}
+			}
+		})
+	}
+
+	t.Run("should accept multipart upload", func(t *testing.T) {
+		// Arrange
+		var created models.Cars
+		repo := &MockCarRepository{
+			CreateBatchFn: func(cars models.Cars) error {
+				created = cars
+				return nil
+			},
+		}
+
+		controller := NewCarController(services.NewCarService(repo, nil))
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		file, err := form.CreateFormFile("file", "inventory.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = file.Write([]byte(header + "Kia,Rio,Blue,Sedan,2021,,,\n"))
+		_ = form.Close()
+
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/cars/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+
+		// Act
+		controller.Import(resp, req)
+
+		// Assert
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("expected status %v, got %v: %s", http.StatusCreated, resp.Code, resp.Body.String())
+		}
+
+		if len(created) != 1 || created[0].Model != "Rio" {
+			t.Fatalf("expected uploaded car to be created, got %+v", created)
+		}
+	})
+
+	t.Run("should import more rows than a bulk batch holds", func(t *testing.T) {
+		// Arrange
+		rows := services.MaxCarBatchSize + 500
+
+		var created models.Cars
+		repo := &MockCarRepository{
+			CreateBatchFn: func(cars models.Cars) error {
+				created = append(created, cars...)
+				return nil
+			},
+		}
+
+		controller := NewCarController(services.NewCarService(repo, nil))
+
+		body := header + strings.Repeat("Kia,Rio,Blue,Sedan,2021,,,\n", rows)
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/cars/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+
+		// Act
+		controller.Import(resp, req)
+
+		// Assert
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("expected status %v, got %v: %s", http.StatusCreated, resp.Code, resp.Body.String())
+		}
+
+		if len(created) != rows {
+			t.Fatalf("expected %d cars to be created, got %d", rows, len(created))
+		}
+	})
+
+	t.Run("should report rows rejected when stored in partial mode", func(t *testing.T) {
+		// Arrange
+		service := &batchRejectingService{
+			CarService: services.NewCarService(&MockCarRepository{}, nil),
+			reject:     1,
+			err:        e.NewValidationError(errors.New("make is not in the catalog")),
+		}
+		controller := NewCarController(service)
+
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/cars/import?mode=partial",
+			strings.NewReader(header+"Kia,Rio,Blue,Sedan,2021,,,\nFoo,Bar,Blue,Sedan,2021,,,\nFord,,Red,SUV,2020,,,\n"))
+		req.Header.Set("Content-Type", "text/csv")
+
+		// Act
+		controller.Import(resp, req)
+
+		// Assert
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v: %s", http.StatusOK, resp.Code, resp.Body.String())
+		}
+
+		var got dto.CarImportResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := dto.CarImportResponse{
+			Imported: 1,
+			Created:  []dto.CarImportedRow{{Row: 2, ID: "A1"}},
+			Errors: []dto.CarImportError{
+				{Row: 3, Message: "make is not in the catalog"},
+				{Row: 4, Message: "model is required"},
+			},
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected report %+v, got %+v", expected, got)
+		}
+	})
+}
+
+// batchRejectingService is a CarService whose Import rejects the car
+// at index reject with err and stores the others.
+type batchRejectingService struct {
+	services.CarService
+	reject int
+	err    error
+}
+
+func (s *batchRejectingService) Import(_ context.Context, cars models.Cars, _ bool) ([]error, error) {
+	errs := make([]error, len(cars))
+	for i := range cars {
+		if i == s.reject {
+			errs[i] = s.err
+			continue
+		}
+		cars[i].ID = fmt.Sprintf("A%d", i+1)
+	}
+	return errs, nil
+}
+
+func Test_Car_Update(t *testing.T) {
+	tCases := []struct {
+		name             string
+		idParam          string
+		body             string
+		updateFn         func(car *models.Car) (models.Car, error)
+		expectedStatus   int
+		expectedResponse any
+	}{
+		{
+			name:             "invalid request body: missing comma",
+			idParam:          "ABC123",
+			body:             `{"make": "Chevrolet" "model": "Onix"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+		{
+			name:             "year is missing",
+			idParam:          "ABC123",
+			body:             `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "category is missing",
+			idParam:          "ABC123",
+			body:             `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "year":2025}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "color is missing",
+			idParam:          "ABC123",
+			body:             `{"make":"Chevrolet", "model":"Onix", "category":"Sedan", "year":2025}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "model is missing",
+			idParam:          "ABC123",
+			body:             `{"make":"Chevrolet", "color":"Gray", "category":"Sedan", "year":2025}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:             "make is missing",
+			idParam:          "ABC123",
+			body:             `{"model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:    "car not found",
+			idParam: "ABC123",
+			body:    `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
+			updateFn: func(car *models.Car) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: httpx.ErrorResponse{Message: "Car not found"},
+		},
+		{
+			name:    "repository error",
+			idParam: "ABC123",
+			body:    `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
+			updateFn: func(car *models.Car) (models.Car, error) {
+				return models.Car{}, errors.New("repository error")
+			},
+			expectedStatus:   http.StatusInternalServerError,
+			expectedResponse: httpx.ErrorResponse{Message: "Internal server error"},
+		},
+		{
+			name:    "car updated successfully",
+			idParam: "ABC123",
+			body:    `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`,
+			updateFn: func(car *models.Car) (models.Car, error) {
+				return models.Car{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: models.Car{
+				ID:       "ABC123",
+				Make:     "Chevrolet",
+				Model:    "Onix",
+				Color:    "Gray",
+				Category: "Sedan",
+				Year:     2025},
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewCarController(
+				services.NewCarService(
+					&MockCarRepository{UpdateFn: tc.updateFn},
+					nil,
+				),
+			)
+
+			router := chi.NewRouter()
+			router.Route("/cars", func(r chi.Router) {
+				r.Put("/{id:[A-Za-z0-9-]+}", controller.Update)
+			})
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/cars/"+tc.idParam, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			router.ServeHTTP(resp, req)
+
+			// Check status code
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v, got %v", tc.expectedStatus, resp.Code)
+			}
+
+			switch expected := tc.expectedResponse.(type) {
+			case httpx.ErrorResponse:
+				var got httpx.ErrorResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if got.Message != expected.Message {
+					t.Fatalf("expected error %v, got %v", expected.Message, got.Message)
+				}
+			case dto.CarResponse:
+				var gotCar dto.CarResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &gotCar); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(gotCar, expected) {
+					t.Fatalf("expected car %+v, got %+v", expected, gotCar)
+				}
+			}
+		})
+	}
+}
+
+func Test_Car_Upsert(t *testing.T) {
+	body := `{"make":"Chevrolet", "model":"Onix", "color":"Gray", "category":"Sedan", "year":2025}`
+	deletedAt := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tCases := []struct {
+		name             string
+		upsert           bool
+		id               string
+		ifNoneMatch      string
+		expectedStatus   int
+		expectedLocation string
+		expectedETag     string
+		expectedBody     string
+	}{
+		{
+			name:             "should create a missing car under the given ID",
+			upsert:           true,
+			id:               "JHK290XJ",
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "/cars/JHK290XJ",
+			expectedETag:     `"1"`,
+			expectedBody:     `{"id":"JHK290XJ","make":"Chevrolet","model":"Onix","color":"Gray","category":"Sedan","year":2025,"revision":1}`,
+		},
+		{
+			name:           "should replace an existing car",
+			upsert:         true,
+			id:             "A1",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+			expectedBody:   `"id":"A1","make":"Chevrolet"`,
+		},
+		{
+			name:             "should create a missing car with If-None-Match",
+			upsert:           true,
+			id:               "JHK290XJ",
+			ifNoneMatch:      "*",
+			expectedStatus:   http.StatusCreated,
+			expectedLocation: "/cars/JHK290XJ",
+			expectedETag:     `"1"`,
+			expectedBody:     `"id":"JHK290XJ"`,
+		},
+		{
+			name:           "should not replace an existing car with If-None-Match",
+			upsert:         true,
+			id:             "A1",
+			ifNoneMatch:    "*",
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedBody:   `"code":"PRECONDITION_FAILED"`,
+		},
+		{
+			name:           "should not reuse the ID of a deleted car",
+			upsert:         true,
+			id:             "D1",
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"code":"CAR_EXISTS"`,
+		},
+		{
+			name:           "should not create a car under the ID of the stats route",
+			upsert:         true,
+			id:             "stats",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `"code":"CAR_ID_RESERVED"`,
+		},
+		{
+			name:           "should not create a car under the ID of the bulk route",
+			upsert:         true,
+			id:             "bulk",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `"code":"CAR_ID_RESERVED"`,
+		},
+		{
+			name:           "should not create a car under the ID of the import route",
+			upsert:         true,
+			id:             "import",
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody:   `"code":"CAR_ID_RESERVED"`,
+		},
+		{
+			name:           "should return not found for a missing car when upsert is disabled",
+			id:             "JHK290XJ",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"CAR_NOT_FOUND"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := repositories.NewCarRepository(map[string]models.Car{
+				"A1": {ID: "A1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010},
+				"D1": {ID: "D1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010, DeletedAt: &deletedAt},
+			}, nil)
+			controller := NewCarController(services.NewCarService(repo, nil), WithUpsert(tc.upsert))
+
+			router := chi.NewRouter()
+			router.Get("/cars/stats", controller.Stats)
+			router.Post("/cars/bulk", controller.CreateBatch)
+			router.Post("/cars/import", controller.Import)
+			router.Put("/cars/{id:"+models.CarIDPattern+"}", controller.Update)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/cars/"+tc.id, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := resp.Header().Get("Location"); got != tc.expectedLocation {
+				t.Fatalf("expected Location %q, got %q", tc.expectedLocation, got)
+			}
+
+			if got := resp.Header().Get("ETag"); got != tc.expectedETag {
+				t.Fatalf("expected ETag %q, got %q", tc.expectedETag, got)
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_Patch(t *testing.T) {
+	stored := models.Car{
+		ID:       "ABC123",
+		Make:     "Chevrolet",
+		Model:    "Onix",
+		Color:    "Gray",
+		Category: "Sedan",
+		Year:     2025,
+		Package:  u.Ptr("LT"),
+		Price:    u.Ptr(int64(1500000)),
+	}
+
+	tCases := []struct {
+		name             string
+		contentType      string
+		body             string
+		findFn           func(id string) (models.Car, error)
+		expectedStatus   int
+		expectedResponse any
+	}{
+		{
+			name:             "unsupported content type",
+			contentType:      "application/json",
+			body:             `{"color":"Red"}`,
+			expectedStatus:   http.StatusUnsupportedMediaType,
+			expectedResponse: httpx.ErrorResponse{Message: "Unsupported media type"},
+		},
+		{
+			name:             "invalid request body: missing comma",
+			contentType:      "application/merge-patch+json",
+			body:             `{"color": "Red" "year": 2020}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+		{
+			name:        "car not found",
+			contentType: "application/merge-patch+json",
+			body:        `{"color":"Red"}`,
+			findFn: func(id string) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: httpx.ErrorResponse{Message: "Car not found"},
+		},
+		{
+			name:        "unknown field",
+			contentType: "application/merge-patch+json",
+			body:        `{"colour":"Red"}`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+		{
+			name:        "wrong field type",
+			contentType: "application/merge-patch+json",
+			body:        `{"year":"2020"}`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+		{
+			name:        "clearing a required field fails validation",
+			contentType: "application/merge-patch+json",
+			body:        `{"make":null}`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Validation failed"},
+		},
+		{
+			name:        "car patched successfully",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			body:        `{"color":"Red","mileage":1200,"package":null}`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: dto.CarResponse{
+				ID:       "ABC123",
+				Make:     "Chevrolet",
+				Model:    "Onix",
+				Color:    "Red",
+				Category: "Sedan",
+				Year:     2025,
+				Mileage:  u.Ptr(int64(1200)),
+				Price:    u.Ptr(int64(1500000)),
+			},
+		},
+		{
+			name:        "json patch applied successfully",
+			contentType: "application/json-patch+json",
+			body: `[
+				{"op":"test","path":"/price","value":1500000},
+				{"op":"replace","path":"/price","value":1450000},
+				{"op":"remove","path":"/package"}
+			]`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedResponse: dto.CarResponse{
+				ID:       "ABC123",
+				Make:     "Chevrolet",
+				Model:    "Onix",
+				Color:    "Gray",
+				Category: "Sedan",
+				Year:     2025,
+				Price:    u.Ptr(int64(1450000)),
+			},
+		},
+		{
+			name:        "json patch test operation fails",
+			contentType: "application/json-patch+json",
+			body: `[
+				{"op":"test","path":"/price","value":2899000},
+				{"op":"replace","path":"/price","value":2799000}
+			]`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus:   http.StatusConflict,
+			expectedResponse: httpx.ErrorResponse{Message: "Patch test operation failed"},
+		},
+		{
+			name:        "json patch path does not exist",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"remove","path":"/mileage"}]`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+		{
+			name:             "json patch document is not an array",
+			contentType:      "application/json-patch+json",
+			body:             `{"op":"remove","path":"/mileage"}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+		{
+			name:        "json patch cannot change the id",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"add","path":"/id","value":"XYZ"}]`,
+			findFn: func(id string) (models.Car, error) {
+				return stored, nil
+			},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: httpx.ErrorResponse{Message: "Invalid request body"},
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewCarController(
+				services.NewCarService(
+					&MockCarRepository{
+						PatchFn: func(id string, patch models.CarPatchFunc) (models.Car, error) {
+							car, err := tc.findFn(id)
+							if err != nil {
+								return models.Car{}, err
+							}
+							if err := patch(&car); err != nil {
+								return models.Car{}, err
+							}
+							return car, nil
+						},
+					},
+					nil,
+				),
+			)
+
+			router := chi.NewRouter()
+			router.Route("/cars", func(r chi.Router) {
+				r.Patch("/{id:[A-Za-z0-9-]+}", controller.Patch)
+			})
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/cars/"+stored.ID, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			router.ServeHTTP(resp, req)
+
+			// Check status code
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v, got %v", tc.expectedStatus, resp.Code)
+			}
+
+			switch expected := tc.expectedResponse.(type) {
+			case httpx.ErrorResponse:
+				var got httpx.ErrorResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if got.Message != expected.Message {
+					t.Fatalf("expected error %v, got %v", expected.Message, got.Message)
+				}
+			case dto.CarResponse:
+				var got dto.CarResponse
+				if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("expected car %+v, got %+v", expected, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_Car_ConditionalRequests(t *testing.T) {
+	stored := models.Car{
+		ID:       "ABC123",
+		Make:     "Chevrolet",
+		Model:    "Onix",
+		Color:    "Gray",
+		Category: "Sedan",
+		Year:     2025,
+		Revision: 3,
+	}
+
+	// repo emulates a repository holding a single car at revision 3.
+	repo := &MockCarRepository{
+		FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+			return stored, nil
+		},
+		UpdateFn: func(car *models.Car) (models.Car, error) {
+			if car.Revision != 0 && car.Revision != stored.Revision {
+				return models.Car{}, e.ErrRevisionMismatch
+			}
+			car.Revision = stored.Revision + 1
+			return stored, nil
+		},
+		PatchFn: func(id string, patch models.CarPatchFunc) (models.Car, error) {
+			car := stored
+			if err := patch(&car); err != nil {
+				return models.Car{}, err
+			}
+			car.Revision = stored.Revision + 1
+			return car, nil
+		},
+		DeleteFn: func(id string, revision int64) (models.Car, error) {
+			if revision != 0 && revision != stored.Revision {
+				return models.Car{}, e.ErrRevisionMismatch
+			}
+			car := stored
+			car.Revision++
+			car.DeletedAt = u.Ptr(time.Now())
+			return car, nil
+		},
+	}
+
+	body := `{"make":"Chevrolet","model":"Onix","color":"Red","category":"Sedan","year":2025}`
+
+	tCases := []struct {
+		name           string
+		method         string
+		contentType    string
+		body           string
+		headers        map[string]string
+		expectedStatus int
+		expectedETag   string
+	}{
+		{
+			name:           "get returns etag",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:           "get with matching If-None-Match",
+			method:         http.MethodGet,
+			headers:        map[string]string{"If-None-Match": `"1", W/"3"`},
+			expectedStatus: http.StatusNotModified,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:           "get with stale If-None-Match",
+			method:         http.MethodGet,
+			headers:        map[string]string{"If-None-Match": `"2"`},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+		},
+		{
+			name:           "put with matching If-Match",
+			method:         http.MethodPut,
+			contentType:    "application/json",
+			body:           body,
+			headers:        map[string]string{"If-Match": `"3"`},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+		},
+		{
+			name:           "put with stale If-Match",
+			method:         http.MethodPut,
+			contentType:    "application/json",
+			body:           body,
+			headers:        map[string]string{"If-Match": `"2"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "put with weak If-Match",
+			method:         http.MethodPut,
+			contentType:    "application/json",
+			body:           body,
+			headers:        map[string]string{"If-Match": `W/"3"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "put with wildcard If-Match",
+			method:         http.MethodPut,
+			contentType:    "application/json",
+			body:           body,
+			headers:        map[string]string{"If-Match": `*`},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+		},
+		{
+			name:           "patch with stale If-Match",
+			method:         http.MethodPatch,
+			contentType:    "application/merge-patch+json",
+			body:           `{"color":"Red"}`,
+			headers:        map[string]string{"If-Match": `"2"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "patch with matching If-Match",
+			method:         http.MethodPatch,
+			contentType:    "application/merge-patch+json",
+			body:           `{"color":"Red"}`,
+			headers:        map[string]string{"If-Match": `"3"`},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"4"`,
+		},
+		{
+			name:           "delete with stale If-Match",
+			method:         http.MethodDelete,
+			headers:        map[string]string{"If-Match": `"2"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "delete with matching If-Match",
+			method:         http.MethodDelete,
+			headers:        map[string]string{"If-Match": `"3"`},
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := NewCarController(services.NewCarService(repo, nil))
+
+			router := chi.NewRouter()
+			router.Route("/cars/{id:[A-Za-z0-9-]+}", func(r chi.Router) {
+				r.Get("/", controller.Get)
+				r.Put("/", controller.Update)
+				r.Patch("/", controller.Patch)
+				r.Delete("/", controller.Delete)
+			})
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/cars/"+stored.ID, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+
+			router.ServeHTTP(resp, req)
+
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %v, got %v: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := resp.Header().Get("ETag"); got != tc.expectedETag {
+				t.Fatalf("expected ETag %q, got %q", tc.expectedETag, got)
+			}
+
+			if tc.expectedStatus == http.StatusNotModified && resp.Body.Len() != 0 {
+				t.Fatalf("expected empty body, got %q", resp.Body.String())
+			}
+		})
+	}
+}
+
+func Test_Car_List_Pagination(t *testing.T) {
+	cars := models.Cars{
+		{ID: "A1", Make: "Chevrolet", Model: "Onix", Color: "Black", Category: "Sedan", Year: 2025},
+		{ID: "B2", Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025},
+		{ID: "C3", Make: "Renault", Model: "Arkana", Color: "White", Category: "Sedan", Year: 2025},
+	}
+
+	var limits []int
+	repo := &MockCarRepository{
+		ListFn: func(f models.CarFilters) (models.Cars, error) {
+			limits = append(limits, f.Limit)
+			var out models.Cars
+			for _, car := range cars {
+				if f.After != nil && car.ID <= f.After.ID {
+					continue
+				}
+				if f.Limit > 0 && len(out) == f.Limit {
+					break
+				}
+				out = append(out, car)
+			}
+			return out, nil
+		},
+	}
+
+	controller := NewCarController(services.NewCarService(repo, nil))
+
+	router := chi.NewRouter()
+	router.Get("/cars", controller.List)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, target, nil))
+		if resp.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, resp.Code, resp.Body.String())
+		}
+		return resp
+	}
+
+	var ids []string
+	target := "/cars?limit=2&make=x"
+	for pages := 0; target != ""; pages++ {
+		if pages > len(cars) {
+			t.Fatal("pagination did not terminate")
+		}
+
+		resp := get(target)
+
+		var got []dto.CarResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		for _, car := range got {
+			ids = append(ids, car.ID)
+		}
+
+		target = ""
+		if cursor := resp.Header().Get("X-Next-Cursor"); cursor != "" {
+			link := resp.Header().Get("Link")
+			if !strings.HasSuffix(link, `>; rel="next"`) || !strings.Contains(link, "cursor="+cursor) {
+				t.Fatalf("unexpected Link header %q", link)
+			}
+			if !strings.Contains(link, "limit=2") || !strings.Contains(link, "make=x") {
+				t.Fatalf("expected Link header to keep query parameters, got %q", link)
+			}
+			target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+		}
+	}
+
+	if expected := []string{"A1", "B2", "C3"}; !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected IDs %v across pages, got %v", expected, ids)
+	}
+
+	t.Run("should list every car without limit or cursor", func(t *testing.T) {
+		// Arrange
+		limits = nil
+
+		// Act
+		resp := get("/cars")
+
+		// Assert
+		var got []dto.CarResponse
+		if err := json.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(got) != len(cars) || !reflect.DeepEqual(limits, []int{0}) {
+			t.Fatalf("expected %d cars with no limit, got %d with limits %v", len(cars), len(got), limits)
+		}
+		if cursor := resp.Header().Get("X-Next-Cursor"); cursor != "" {
+			t.Fatalf("expected no next cursor, got %q", cursor)
+		}
+	})
+
+	t.Run("should page by DefaultPageLimit with a cursor but no limit", func(t *testing.T) {
+		// Arrange
+		limits = nil
+
+		// Act
+		get("/cars?cursor=" + dto.EncodeCursor(models.CarCursor{ID: "A1"}))
+
+		// Assert
+		// The service asks for one more car to know whether a next page exists.
+		if expected := []int{DefaultPageLimit + 1}; !reflect.DeepEqual(limits, expected) {
+			t.Fatalf("expected limits %v, got %v", expected, limits)
+		}
+	})
+}
+
+func Test_Car_Fields(t *testing.T) {
+	car := models.Car{
+		ID:       "A1",
+		Make:     "Toyota",
+		Model:    "Yaris",
+		Color:    "Red",
+		Category: "Sedan",
+		Year:     2025,
+		Price:    u.Ptr(int64(2899000)),
+		Revision: 3,
+	}
+
+	repo := &MockCarRepository{
+		FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+			return car, nil
+		},
+		ListFn: func(f models.CarFilters) (models.Cars, error) {
+			return models.Cars{car}, nil
+		},
+	}
+
+	controller := NewCarController(services.NewCarService(repo, nil))
+
+	router := chi.NewRouter()
+	router.Route("/cars", func(r chi.Router) {
+		r.Get("/", controller.List)
+		r.Get("/{id:[A-Za-z0-9-]+}", controller.Get)
+	})
+
+	tCases := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "should return only the selected fields of a car",
+			target:         "/cars/A1?fields=price,make",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":"A1","make":"Toyota","price":2899000}`,
+		},
+		{
+			name:           "should return only the selected fields of each car",
+			target:         "/cars?fields=model&fields=revision",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"id":"A1","model":"Yaris","revision":3}]`,
+		},
+		{
+			name:           "should omit selected optional fields without value",
+			target:         "/cars/A1?fields=id,mileage",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":"A1"}`,
+		},
+		{
+			name:           "should return every field when fields is empty",
+			target:         "/cars/A1?fields=",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":"A1","make":"Toyota","model":"Yaris","color":"Red","category":"Sedan","year":2025,"price":2899000,"revision":3}`,
+		},
+		{
+			name:           "should reject unknown field on get",
+			target:         "/cars/A1?fields=make,vin",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"details":"invalid fields: unknown field: \"vin\""`,
+		},
+		{
+			name:           "should reject unknown field on list",
+			target:         "/cars?fields=Make",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"details":"invalid fields: unknown field: \"Make\""`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_Export(t *testing.T) {
+	cars := models.Cars{
+		{ID: "A1", Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025, Price: u.Ptr(int64(2899050)), Revision: 2},
+		{ID: "B2", Make: "Ford", Model: "Ranger, XL", Color: "Blue", Category: "Truck", Year: 2021, Mileage: u.Ptr(int64(35000)), Revision: 1},
+	}
+	for i := range 2500 {
+		cars = append(cars, models.Car{ID: fmt.Sprintf("Z%04d", i), Make: "Fiat", Model: "Uno", Color: "White", Category: "Hatch", Year: 2000})
+	}
+
+	var onEach func(i int)
+	repo := &MockCarRepository{
+		EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+			for i, car := range cars {
+				if len(f.Make) > 0 && !slices.Contains(f.Make, car.Make) {
+					continue
+				}
+				if onEach != nil {
+					onEach(i)
+				}
+				if !fn(car) {
+					break
+				}
+			}
+			return nil
+		},
+		ListFn: func(f models.CarFilters) (models.Cars, error) {
+			var out models.Cars
+			for _, car := range cars {
+				if len(f.Make) > 0 && !slices.Contains(f.Make, car.Make) {
+					continue
+				}
+				if f.Limit > 0 && len(out) == f.Limit {
+					break
+				}
+				out = append(out, car)
+			}
+			return out, nil
+		},
+	}
+
+	controller := NewCarController(services.NewCarService(repo, nil))
+
+	router := chi.NewRouter()
+	router.Get("/cars", controller.List)
+
+	tCases := []struct {
+		name                string
+		target              string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "should export cars as CSV",
+			target:              "/cars?make=Toyota,Ford",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody: "id,make,model,color,category,year,package,mileage,price,revision,deleted_at\n" +
+				"A1,Toyota,Yaris,Red,Sedan,2025,,,28990.50,2,\n" +
+				"B2,Ford,\"Ranger, XL\",Blue,Truck,2021,,35000,,1,\n",
+		},
+		{
+			name:                "should export only the selected fields as CSV",
+			target:              "/cars?make=Toyota&fields=price,make",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,make,price\nA1,Toyota,28990.50\n",
+		},
+		{
+			name:                "should write the CSV header when no car matches",
+			target:              "/cars?make=Honda&fields=model",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,model\n",
+		},
+		{
+			name:                "should export cars as NDJSON",
+			target:              "/cars?make=Toyota,Ford&fields=model",
+			accept:              "application/x-ndjson",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson; charset=utf-8",
+			expectedBody:        "{\"id\":\"A1\",\"model\":\"Yaris\"}\n{\"id\":\"B2\",\"model\":\"Ranger, XL\"}\n",
+		},
+		{
+			name:                "should ignore limit when exporting",
+			target:              "/cars?make=Toyota,Ford&fields=id&limit=1",
+			accept:              "application/x-ndjson",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/x-ndjson; charset=utf-8",
+			expectedBody:        "{\"id\":\"A1\"}\n{\"id\":\"B2\"}\n",
+		},
+		{
+			name:                "should prefer the most specific media range",
+			target:              "/cars?make=Toyota&fields=id",
+			accept:              "application/json;q=0.5, text/*;q=0.5, text/csv;q=0.9, */*;q=0.1",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id\nA1\n",
+		},
+		{
+			name:                "should respond with JSON to a wildcard",
+			target:              "/cars?make=Toyota&fields=id",
+			accept:              "*/*",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `[{"id":"A1"}]` + "\n",
+		},
+		{
+			name:                "should reject unsupported media types",
+			target:              "/cars",
+			accept:              "application/xml, text/csv;q=0",
+			expectedStatus:      http.StatusNotAcceptable,
+			expectedContentType: "application/json",
+			expectedBody:        `{"code":"NOT_ACCEPTABLE","message":"Not acceptable","details":"no acceptable content type: \"application/xml, text/csv;q=0\" (supported: application/json, text/csv, application/x-ndjson)"}` + "\n",
+		},
+		{
+			name:                "should report invalid filters before streaming",
+			target:              "/cars?min_year=2025&max_year=2020",
+			accept:              "text/csv",
+			expectedStatus:      http.StatusBadRequest,
+			expectedContentType: "application/json",
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			req.Header.Set("Accept", tc.accept)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := resp.Header().Get("Content-Type"); got != tc.expectedContentType {
+				t.Fatalf("expected Content-Type %q, got %q", tc.expectedContentType, got)
+			}
+
+			if got := resp.Header().Get("Vary"); got != "Accept" {
+				t.Fatalf("expected Vary: Accept, got %q", got)
+			}
+
+			if tc.expectedBody != "" && resp.Body.String() != tc.expectedBody {
+				t.Fatalf("expected body %q, got %q", tc.expectedBody, resp.Body.String())
+			}
+		})
+	}
+
+	t.Run("should export every car", func(t *testing.T) {
+		// Arrange
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/cars?fields=id", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		// Act
+		router.ServeHTTP(resp, req)
+
+		// Assert
+		lines := strings.Split(strings.TrimSuffix(resp.Body.String(), "\n"), "\n")
+		if len(lines) != len(cars) {
+			t.Fatalf("expected %d lines, got %d", len(cars), len(lines))
+		}
+
+		if expected := `{"id":"Z2499"}`; lines[len(lines)-1] != expected {
+			t.Fatalf("expected last line %s, got %s", expected, lines[len(lines)-1])
+		}
+	})
+	t.Run("should stop when the client goes away", func(t *testing.T) {
+		// Arrange
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		onEach = func(i int) {
+			if i == 1000 {
+				cancel()
+			}
+		}
+		defer func() { onEach = nil }()
+
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequestWithContext(ctx, http.MethodGet, "/cars?fields=id", nil)
+		req.Header.Set("Accept", "application/x-ndjson")
+
+		// Act
+		router.ServeHTTP(resp, req)
+
+		// Assert
+		if got := strings.Count(resp.Body.String(), "\n"); got != 1000 {
+			t.Fatalf("expected 1000 lines before the client went away, got %d", got)
+		}
+	})
+}
+
+func Test_Car_Stats(t *testing.T) {
+	cars := models.Cars{
+		{ID: "A1", Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025, Price: u.Ptr(int64(2899000))},
+		{ID: "B2", Make: "Ford", Model: "Ranger", Color: "Blue", Category: "Truck", Year: 2021, Price: u.Ptr(int64(3500000)), Mileage: u.Ptr(int64(35000))},
+		{ID: "C3", Make: "toyota", Model: "Hilux", Color: "Red", Category: "Truck", Year: 2021},
+	}
+
+	repo := &MockCarRepository{
+		EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+			if f.Limit != 0 || f.After != nil || f.Sort != nil {
+				t.Fatalf("expected no pagination or ordering, got %+v", f)
+			}
+
+			for _, car := range cars {
+				if f.Match(car) && !fn(car) {
+					break
+				}
+			}
+			return nil
+		},
+		FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+			t.Fatalf("unexpected Find(%q)", id)
+			return models.Car{}, nil
+		},
+	}
+
+	controller := NewCarController(services.NewCarService(repo, nil))
+
+	router := chi.NewRouter()
+	router.Route("/cars", func(r chi.Router) {
+		r.Get("/stats", controller.Stats)
+		r.Get("/{id:[A-Za-z0-9-]+}", controller.Get)
+	})
+
+	tCases := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "should summarise every car",
+			target:         "/cars/stats?sort=-price&limit=1",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"count":3,"facets":{` +
+				`"make":[{"value":"Toyota","count":2},{"value":"Ford","count":1}],` +
+				`"model":[{"value":"Hilux","count":1},{"value":"Ranger","count":1},{"value":"Yaris","count":1}],` +
+				`"category":[{"value":"Truck","count":2},{"value":"Sedan","count":1}],` +
+				`"color":[{"value":"Red","count":2},{"value":"Blue","count":1}],` +
+				`"year":[{"value":2021,"count":2},{"value":2025,"count":1}]},` +
+				`"price":{"count":2,"min":2899000,"max":3500000,"avg":3199500},` +
+				`"mileage":{"count":1,"min":35000,"max":35000,"avg":35000}}`,
+		},
+		{
+			name:           "should summarise only the cars matching the filters",
+			target:         "/cars/stats?make=toyota&category=Truck",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"count":1,"facets":{` +
+				`"make":[{"value":"toyota","count":1}],` +
+				`"model":[{"value":"Hilux","count":1}],` +
+				`"category":[{"value":"Truck","count":1}],` +
+				`"color":[{"value":"Red","count":1}],` +
+				`"year":[{"value":2021,"count":1}]},` +
+				`"price":null,"mileage":null}`,
+		},
+		{
+			name:           "should return empty facets when no car matches",
+			target:         "/cars/stats?filter=year%20%3E%202030",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"count":0,"facets":{"make":[],"model":[],"category":[],"color":[],"year":[]},"price":null,"mileage":null}`,
+		},
+		{
+			name:           "should reject invalid filters",
+			target:         "/cars/stats?min_price=10&max_price=5",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"VALIDATION_FAILED"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_Restore(t *testing.T) {
+	tCases := []struct {
+		name           string
+		restoreFn      func(id string) (models.Car, error)
+		expectedStatus int
+		expectedETag   string
+		expectedBody   string
+	}{
+		{
+			name: "should return the restored car",
+			restoreFn: func(id string) (models.Car, error) {
+				return models.Car{ID: id, Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025, Revision: 3}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"3"`,
+			expectedBody:   `{"id":"A1","make":"Toyota","model":"Yaris","color":"Red","category":"Sedan","year":2025,"revision":3}`,
+		},
+		{
+			name: "should return not found when car does not exist",
+			restoreFn: func(id string) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"CAR_NOT_FOUND"`,
+		},
+		{
+			name: "should return conflict when car is not deleted",
+			restoreFn: func(id string) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotDeleted
+			},
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `"code":"CAR_NOT_DELETED"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := &MockCarRepository{
+				FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+					return models.Car{ID: id, DeletedAt: u.Ptr(time.Now())}, nil
+				},
+				RestoreFn: tc.restoreFn,
+			}
+			controller := NewCarController(services.NewCarService(repo, nil))
+
+			router := chi.NewRouter()
+			router.Post("/cars/{id}/restore", controller.Restore)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/cars/A1/restore", nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := resp.Header().Get("ETag"); got != tc.expectedETag {
+				t.Fatalf("expected ETag %q, got %q", tc.expectedETag, got)
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_History(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	created := models.Car{ID: "A1", Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025, Revision: 1}
+	updated := created
+	updated.Color, updated.Revision = "Blue", 2
+
+	tCases := []struct {
+		name           string
+		history        []models.CarAuditEntry
+		findFn         func(id string, includeDeleted bool) (models.Car, error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "should return the changes made to the car",
+			history: []models.CarAuditEntry{
+				{CarID: "A1", Revision: 1, Action: models.CarCreated, Actor: "alice", RequestID: "req-1", Time: at, After: created},
+				{CarID: "A1", Revision: 2, Action: models.CarUpdated, Actor: "bob", Time: at.Add(time.Hour), Before: &created, After: updated},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `[` +
+				`{"revision":1,"action":"create","actor":"alice","request_id":"req-1","time":"2026-03-04T05:06:07Z","before":null,` +
+				`"after":{"id":"A1","make":"Toyota","model":"Yaris","color":"Red","category":"Sedan","year":2025,"revision":1},` +
+				`"changes":[{"field":"make","from":null,"to":"Toyota"},{"field":"model","from":null,"to":"Yaris"},` +
+				`{"field":"color","from":null,"to":"Red"},{"field":"category","from":null,"to":"Sedan"},{"field":"year","from":null,"to":2025}]},` +
+				`{"revision":2,"action":"update","actor":"bob","time":"2026-03-04T06:06:07Z",` +
+				`"before":{"id":"A1","make":"Toyota","model":"Yaris","color":"Red","category":"Sedan","year":2025,"revision":1},` +
+				`"after":{"id":"A1","make":"Toyota","model":"Yaris","color":"Blue","category":"Sedan","year":2025,"revision":2},` +
+				`"changes":[{"field":"color","from":"Red","to":"Blue"}]}` +
+				`]`,
+		},
+		{
+			name: "should return an empty history for a car without changes",
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
+				return created, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+		},
+		{
+			name: "should return not found when car does not exist",
+			findFn: func(id string, includeDeleted bool) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"CAR_NOT_FOUND"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			audit := repositories.NewAuditStore()
+			if err := audit.Append(tc.history...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			controller := NewCarController(services.NewCarService(&MockCarRepository{FindFn: tc.findFn}, audit))
+
+			router := chi.NewRouter()
+			router.Get("/cars/{id}/history", controller.History)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/cars/A1/history", nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_GetAsOf(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	created := models.Car{ID: "A1", Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025, Revision: 1}
+	updated := created
+	updated.Color, updated.Revision = "Blue", 2
+	history := []models.CarAuditEntry{
+		{CarID: "A1", Revision: 1, Action: models.CarCreated, Time: at, After: created},
+		{CarID: "A1", Revision: 2, Action: models.CarUpdated, Time: at.Add(time.Hour), Before: &created, After: updated},
+	}
+
+	tCases := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedETag   string
+		expectedBody   string
+	}{
+		{
+			name:           "should return the car as it was at the given time",
+			url:            "/cars/A1?as_of=2026-03-04T05:30:00Z",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"1"`,
+			expectedBody:   `{"id":"A1","make":"Toyota","model":"Yaris","color":"Red","category":"Sedan","year":2025,"revision":1}`,
+		},
+		{
+			name:           "should accept a time with an offset",
+			url:            "/cars/A1?as_of=2026-03-04T08:30:00%2B02:00",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+			expectedBody:   `"color":"Blue"`,
+		},
+		{
+			name:           "should return not found before the car was created",
+			url:            "/cars/A1?as_of=2026-03-04T05:00:00Z",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"CAR_NOT_FOUND"`,
+		},
+		{
+			name:           "should return the stored car after its last change",
+			url:            "/cars/A1?as_of=2026-03-05T00:00:00Z",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+			expectedBody:   `"color":"Blue"`,
+		},
+		{
+			name:           "should return a car never changed as stored",
+			url:            "/cars/JHK290XJ?as_of=" + url.QueryEscape(time.Now().UTC().Format(time.RFC3339)),
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"1"`,
+			expectedBody:   `"id":"JHK290XJ"`,
+		},
+		{
+			name:           "should return a seeded car as it was before its first change",
+			url:            "/cars/FWL37LA?as_of=" + url.QueryEscape(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)),
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"1"`,
+			expectedBody:   `"revision":1`,
+		},
+		{
+			name:           "should return validation error for a malformed time",
+			url:            "/cars/A1?as_of=yesterday",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"VALIDATION_FAILED"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			controller := NewCarController(historyService(t, updated, history))
+
+			router := chi.NewRouter()
+			router.Get("/cars/{id}", controller.Get)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := resp.Header().Get("ETag"); got != tc.expectedETag {
+				t.Fatalf("expected ETag %q, got %q", tc.expectedETag, got)
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_Revision(t *testing.T) {
+	created := models.Car{ID: "A1", Make: "Toyota", Model: "Yaris", Color: "Red", Category: "Sedan", Year: 2025, Revision: 1}
+	updated := created
+	updated.Color, updated.Revision = "Blue", 2
+	history := []models.CarAuditEntry{
+		{CarID: "A1", Revision: 1, Action: models.CarCreated, After: created},
+		{CarID: "A1", Revision: 2, Action: models.CarUpdated, Before: &created, After: updated},
+	}
+
+	tCases := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedETag   string
+		expectedBody   string
+	}{
+		{
+			name:           "should return the car as it was at the given revision",
+			url:            "/cars/A1/revisions/1",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"1"`,
+			expectedBody:   `{"id":"A1","make":"Toyota","model":"Yaris","color":"Red","category":"Sedan","year":2025,"revision":1}`,
+		},
+		{
+			name:           "should limit the response to the requested fields",
+			url:            "/cars/A1/revisions/2?fields=color",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+			expectedBody:   `{"id":"A1","color":"Blue"}`,
+		},
+		{
+			name:           "should return a car never changed at its stored revision",
+			url:            "/cars/JHK290XJ/revisions/1",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"1"`,
+			expectedBody:   `"id":"JHK290XJ"`,
+		},
+		{
+			name:           "should return a seeded car at the revision before its first change",
+			url:            "/cars/FWL37LA/revisions/1",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"1"`,
+			expectedBody:   `"revision":1`,
+		},
+		{
+			name:           "should return a seeded car at the revision of its first change",
+			url:            "/cars/FWL37LA/revisions/2?fields=color",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+			expectedBody:   `"color":"Repainted"`,
+		},
+		{
+			name:           "should return not found for an unrecorded revision",
+			url:            "/cars/A1/revisions/3",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"CAR_NOT_FOUND"`,
+		},
+		{
+			name:           "should return validation error for a non-positive revision",
+			url:            "/cars/A1/revisions/0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"VALIDATION_FAILED"`,
+		},
+		{
+			name:           "should return validation error for a malformed revision",
+			url:            "/cars/A1/revisions/latest",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"code":"VALIDATION_FAILED"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			controller := NewCarController(historyService(t, updated, history))
+
+			router := chi.NewRouter()
+			router.Get("/cars/{id}/revisions/{rev}", controller.Revision)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := resp.Header().Get("ETag"); got != tc.expectedETag {
+				t.Fatalf("expected ETag %q, got %q", tc.expectedETag, got)
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+// historyService creates a CarService over the seed data and stored, whose
+// audit trail holds history. The seeded car FWL37LA is repainted through
+// the service, so that its history starts after its first revision.
+func historyService(t *testing.T, stored models.Car, history []models.CarAuditEntry) services.CarService {
+	t.Helper()
+
+	cars := data.Cars()
+	cars[stored.ID] = stored
+
+	audit := repositories.NewAuditStore()
+	if err := audit.Append(history...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	service := services.NewCarService(repositories.NewCarRepository(cars, nil), audit)
+	_, err := service.Patch(context.Background(), "FWL37LA", 0, func(car *models.Car) error {
+		car.Color = "Repainted"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return service
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"cars/api/dto"
+	"cars/pkg/httpx"
+	"cars/pkg/logger"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	e "cars/pkg/errors"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Makes handles listing the distinct makes of the stored cars with the
+// number of cars of each, ordered by name ignoring case. Makes differing
+// only in case are counted together.
+//
+// Method: GET
+// Path: /makes
+func (c *CarController) Makes(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	makes, err := c.service.Makes()
+	if err != nil {
+		log.Printf("error retrieving makes: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if err := httpx.JSON(w, http.StatusOK, dto.ToFacetResponseList(makes)); err != nil {
+		log.Printf("error encoding makes response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("%d makes retrieved", len(makes))
+}
+
+// Models handles listing the distinct models of the cars of a make, with
+// the number of cars of each, ordered by name ignoring case. The make is
+// matched ignoring case; a 404 error is returned if no car has it.
+//
+// Method: GET
+// Path: /makes/{make}/models
+func (c *CarController) Models(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context())
+
+	name, err := getMakeParam(r)
+	if err != nil {
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	list, err := c.service.Models(name)
+	if err != nil {
+		log.Printf("error retrieving models make=%s: %v", name, err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	if err := httpx.JSON(w, http.StatusOK, dto.ToFacetResponseList(list)); err != nil {
+		log.Printf("error encoding models response: %v", err)
+		httpx.HandleServiceError(w, err)
+		return
+	}
+
+	log.Printf("%d models retrieved make=%s", len(list), name)
+}
+
+// getMakeParam extracts the make param from the request. Routing matches
+// the escaped path when it holds characters such as "%2F", in which case
+// the param is unescaped here.
+func getMakeParam(r *http.Request) (string, error) {
+	name := chi.URLParam(r, "make")
+	if r.URL.RawPath != "" {
+		var err error
+		if name, err = url.PathUnescape(name); err != nil {
+			return "", e.NewValidationError(err)
+		}
+	}
+
+	if name = strings.TrimSpace(name); name == "" {
+		return "", e.NewValidationError(errors.New("make is required"))
+	}
+	return name, nil
+}
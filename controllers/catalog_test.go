@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"cars/models"
+	e "cars/pkg/errors"
+	"cars/services"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func Test_Car_Makes(t *testing.T) {
+	tCases := []struct {
+		name           string
+		makesFn        func() ([]models.Facet[string], error)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "should list makes with their counts",
+			makesFn: func() ([]models.Facet[string], error) {
+				return []models.Facet[string]{{Value: "Ford", Count: 12}, {Value: "Toyota", Count: 30}}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":"Ford","count":12},{"value":"Toyota","count":30}]`,
+		},
+		{
+			name: "should return an empty list without cars",
+			makesFn: func() ([]models.Facet[string], error) {
+				return []models.Facet[string]{}, nil
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[]`,
+		},
+		{
+			name: "should return internal error when repository fails",
+			makesFn: func() ([]models.Facet[string], error) {
+				return nil, errors.New("db down")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"code":"INTERNAL_ERROR"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := &MockCarRepository{MakesFn: tc.makesFn}
+			controller := NewCarController(services.NewCarService(repo, nil))
+
+			router := chi.NewRouter()
+			router.Get("/makes", controller.Makes)
+
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/makes", nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
+
+func Test_Car_Models(t *testing.T) {
+	var requested string
+	repo := &MockCarRepository{
+		ModelsFn: func(make string) ([]models.Facet[string], error) {
+			requested = make
+			switch strings.ToLower(make) {
+			case "toyota":
+				return []models.Facet[string]{{Value: "Camry", Count: 2}, {Value: "Rav4", Count: 1}}, nil
+			case "mercedes/benz":
+				return []models.Facet[string]{{Value: "C-Class", Count: 1}}, nil
+			case "broken":
+				return nil, errors.New("db down")
+			}
+			return nil, e.ErrMakeNotFound
+		},
+	}
+
+	controller := NewCarController(services.NewCarService(repo, nil))
+
+	router := chi.NewRouter()
+	router.Get("/makes/{make}/models", controller.Models)
+
+	tCases := []struct {
+		name           string
+		target         string
+		expectedMake   string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "should list the models of a make",
+			target:         "/makes/TOYOTA/models",
+			expectedMake:   "TOYOTA",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":"Camry","count":2},{"value":"Rav4","count":1}]`,
+		},
+		{
+			name:           "should unescape the make",
+			target:         "/makes/Mercedes%2FBenz/models",
+			expectedMake:   "Mercedes/Benz",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"value":"C-Class","count":1}]`,
+		},
+		{
+			name:           "should return not found for unknown make",
+			target:         "/makes/Honda/models",
+			expectedMake:   "Honda",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `"code":"MAKE_NOT_FOUND"`,
+		},
+		{
+			name:           "should return internal error when repository fails",
+			target:         "/makes/broken/models",
+			expectedMake:   "broken",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `"code":"INTERNAL_ERROR"`,
+		},
+		{
+			name:           "should reject a blank make",
+			target:         "/makes/%20/models",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `"details":"make is required"`,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			requested = ""
+			resp := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+
+			// Act
+			router.ServeHTTP(resp, req)
+
+			// Assert
+			if resp.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.expectedStatus, resp.Code, resp.Body.String())
+			}
+
+			if requested != tc.expectedMake {
+				t.Fatalf("expected models of %q to be requested, got %q", tc.expectedMake, requested)
+			}
+
+			if got := strings.TrimSpace(resp.Body.String()); !strings.Contains(got, tc.expectedBody) {
+				t.Fatalf("expected body to contain %s, got %s", tc.expectedBody, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"cars/api/dto"
+	"cars/pkg/config"
+	"cars/pkg/contextkeys"
+	e "cars/pkg/errors"
+	"cars/repositories"
+	"cars/services"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// importActor is the actor recorded in the audit trail for the cars
+// created by the import subcommand.
+const importActor = "import"
+
+// runImport implements the import subcommand: it creates the cars of a
+// CSV file in the configured repository and prints a row-numbered report
+// of the rejected rows to stdout, followed by the number of cars created.
+// FILE may be "-" to read standard input.
+//
+// In atomic mode (the default) nothing is imported if any row is rejected.
+// In partial mode the valid rows are imported regardless, and the rows the
+// repository fails to store are reported along with the invalid ones.
+func runImport(cfg config.Config, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	mode := flags.String("mode", "atomic", "how rejected rows are handled: atomic or partial")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || *mode != "atomic" && *mode != "partial" {
+		return errors.New("usage: cars import [-mode atomic|partial] FILE")
+	}
+	partial := *mode == "partial"
+
+	in := stdin
+	if name := flags.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	imp, err := dto.DecodeCarsCSV(in)
+	if err != nil {
+		return err
+	}
+
+	if len(imp.Errors) > 0 && !partial {
+		printImportErrors(stdout, imp.Errors)
+		return fmt.Errorf("%d rows rejected, no car imported", len(imp.Errors))
+	}
+	if len(imp.Cars) == 0 {
+		printImportErrors(stdout, imp.Errors)
+		fmt.Fprintln(stdout, "0 cars imported")
+		return nil
+	}
+
+	if cfg.Storage == config.StorageMemory {
+		log.Printf("warning: %s storage does not persist imported cars", cfg.Storage)
+	}
+
+	repo, audit, err := repositories.Open(cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.WithValue(context.Background(), contextkeys.ActorKey, importActor)
+	errs, err := services.NewCarService(repo, audit).Import(ctx, imp.Cars, partial)
+	if err != nil {
+		printImportErrors(stdout, imp.Errors)
+
+		var serviceError *e.ServiceError
+		if errors.As(err, &serviceError) {
+			return fmt.Errorf("%s: %s", serviceError.Message, serviceError.Details())
+		}
+		return err
+	}
+
+	report := dto.ToImportResponse(imp, errs)
+	printImportErrors(stdout, report.Errors)
+	fmt.Fprintf(stdout, "%d cars imported\n", report.Imported)
+	return nil
+}
+
+// printImportErrors prints one line per rejected row.
+func printImportErrors(w io.Writer, errs []dto.CarImportError) {
+	for _, rowErr := range errs {
+		fmt.Fprintf(w, "row %d: %s\n", rowErr.Row, rowErr.Message)
+	}
+}
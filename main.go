@@ -1,13 +1,44 @@
+// Command cars runs the Cars API server.
+//
+// Usage:
+//
+//	cars                                     start the HTTP server on :8080
+//	cars import [-mode atomic|partial] FILE  import cars from a CSV file
+//	cars purge [-retention DURATION]         remove cars deleted before the retention period
+//
+// All use the repository selected by the CARS_* environment variables
+// (see package config).
 package main
 
 import (
+	"cars/pkg/config"
 	"cars/routes"
 	"log"
 	"net/http"
+	"os"
 )
 
 func main() {
-	r := routes.Register()
+	cfg := config.Load()
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(cfg, os.Args[2:], os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "purge" {
+		if err := runPurge(cfg, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	r, err := routes.Register(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("Starting server on :8080")
 
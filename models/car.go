@@ -2,10 +2,26 @@ package models
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 )
 
+// CarIDPattern is the regular expression car IDs must match. It is also
+// the pattern of the {id} route parameter, so that any ID a client can
+// address is one it may choose.
+const CarIDPattern = `[A-Za-z0-9-]+`
+
+// carIDRegexp matches a whole string against CarIDPattern.
+var carIDRegexp = regexp.MustCompile(`^` + CarIDPattern + `$`)
+
+// reservedCarIDs lists the IDs that match CarIDPattern but name other
+// routes under /cars, so that a car stored under one of them could not be
+// read back.
+var reservedCarIDs = []string{"bulk", "import", "stats"}
+
 var (
 	// ErrCarIDMustBeEmpty is returned when a car ID is provided during creation.
 	// The ID must be empty because it is generated by the system.
@@ -16,6 +32,14 @@ var (
 	// resource being modified.
 	ErrCarIDRequiredForUpdate = errors.New("id is required for update")
 
+	// ErrInvalidCarID is returned when a client-supplied car ID does not
+	// match CarIDPattern.
+	ErrInvalidCarID = errors.New("id must contain only letters, digits and hyphens")
+
+	// ErrCarIDReserved is returned when a client-supplied car ID is one of
+	// the path segments reserved for other routes under /cars.
+	ErrCarIDReserved = errors.New("id is reserved")
+
 	// ErrCarMakeRequired is returned when a car is validated without a make.
 	// The make field is mandatory for both creation and update operations.
 	ErrCarMakeRequired = errors.New("make is required")
@@ -58,11 +82,25 @@ type Car struct {
 	Package *string // Package level (e.g., SE, XSE).
 	Mileage *int64  // Distance the car has traveled, measured in miles.
 	Price   *int64  // Price of the car in cents.
+
+	Revision  int64      // Version assigned by the repository, incremented on every write.
+	DeletedAt *time.Time // Time the car was deleted, or nil while it is live.
+}
+
+// Deleted reports whether the car has been soft-deleted.
+func (c Car) Deleted() bool {
+	return c.DeletedAt != nil
 }
 
 // Cars represents a collection of Car objects.
 type Cars []Car
 
+// CarPatchFunc modifies a car in place as part of a partial update.
+//
+// It receives a copy of the currently stored car and returns an error
+// if the patch cannot be applied to it.
+type CarPatchFunc func(car *Car) error
+
 // ValidateForCreate validates the car before creation.
 //
 // It ensures that the ID is empty (since it is generated by the system)
@@ -85,6 +123,20 @@ func (c Car) ValidateForUpdate() error {
 	return c.validate()
 }
 
+// ValidateForInsert validates a car created under a client-supplied ID.
+//
+// The ID must match CarIDPattern and must not be reserved for another
+// route, and all required fields must contain valid values.
+func (c Car) ValidateForInsert() error {
+	if !carIDRegexp.MatchString(c.ID) {
+		return ErrInvalidCarID
+	}
+	if slices.Contains(reservedCarIDs, c.ID) {
+		return fmt.Errorf("%w: %q", ErrCarIDReserved, c.ID)
+	}
+	return c.validate()
+}
+
 // Validate checks that all required fields in the Car struct are present and valid.
 func (c Car) validate() error {
 	if isBlank(c.Make) {
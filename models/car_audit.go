@@ -0,0 +1,190 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"time"
+)
+
+var (
+	// ErrCarDidNotExist is returned when a car is read as of a time at which
+	// it had not been created yet or was deleted.
+	ErrCarDidNotExist = errors.New("car did not exist at that time")
+
+	// ErrCarRevisionNotFound is returned when a car is read at a revision
+	// its history does not record.
+	ErrCarRevisionNotFound = errors.New("revision not found")
+)
+
+// CarAuditAction names the kind of change recorded by a CarAuditEntry.
+type CarAuditAction string
+
+const (
+	CarCreated  CarAuditAction = "create"
+	CarUpdated  CarAuditAction = "update"
+	CarDeleted  CarAuditAction = "delete"
+	CarRestored CarAuditAction = "restore"
+)
+
+// CarAuditEntry records a single change made to a car: who made it, as
+// part of which request, when, and the car before and after the change.
+//
+// Entries are immutable once stored. Revision is the revision of the car
+// after the change, so the entries of a car are ordered by it.
+type CarAuditEntry struct {
+	CarID     string         // ID of the changed car.
+	Revision  int64          // Revision of the car after the change.
+	Action    CarAuditAction // Kind of change.
+	Actor     string         // Who made the change.
+	RequestID string         // ID of the request that made the change, if any.
+	Time      time.Time      // When the change was made.
+
+	Before *Car // The car before the change, or nil when it was created.
+	After  Car  // The car after the change.
+}
+
+// CarFieldChange describes a field whose value differs between two
+// versions of a car. A nil From or To stands for an unset optional field.
+type CarFieldChange struct {
+	Field string
+	From  any
+	To    any
+}
+
+// Changes lists the fields changed by the entry, in a fixed order. Every
+// set field of the car is listed for a creation.
+func (e CarAuditEntry) Changes() []CarFieldChange {
+	var before Car
+	if e.Before != nil {
+		before = *e.Before
+	}
+
+	var changes []CarFieldChange
+	for _, f := range carAuditFields {
+		from, to := f.value(before), f.value(e.After)
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		changes = append(changes, CarFieldChange{Field: f.name, From: from, To: to})
+	}
+	return changes
+}
+
+// CarAsOf reconstructs a car as it was at the given time from its history
+// and current, the car as stored now or nil if it no longer is.
+//
+// The car left by the latest change made at or before that time is
+// returned; if no change had been made by then, the car as it was before
+// the earliest later change. current is returned when no change was made
+// after that time, so that a car with no history is read as stored.
+// Entries are not assumed to be ordered, as concurrent writers may record
+// them out of order, and entries recorded before the car was last created
+// are ignored. It reports false if the car had not been created by then.
+func CarAsOf(history []CarAuditEntry, current *Car, at time.Time) (Car, bool) {
+	var (
+		car   Car
+		found bool
+		next  *CarAuditEntry
+	)
+	history = sinceCreated(history)
+	for i, entry := range history {
+		if entry.Time.After(at) {
+			if next == nil || entry.Revision < next.Revision {
+				next = &history[i]
+			}
+			continue
+		}
+		if !found || entry.Revision > car.Revision {
+			car, found = entry.After, true
+		}
+	}
+
+	switch {
+	case next == nil && current != nil:
+		return *current, true
+	case found:
+		return car, true
+	case next != nil && next.Before != nil:
+		return *next.Before, true
+	}
+	return Car{}, false
+}
+
+// CarAtRevision returns the car as it was at the given revision, either
+// current, the car as stored now or nil if it no longer is, or a car
+// recorded before or after a change in its history since it was last
+// created. It reports false if none of them has that revision.
+func CarAtRevision(history []CarAuditEntry, current *Car, revision int64) (Car, bool) {
+	if current != nil && current.Revision == revision {
+		return *current, true
+	}
+
+	for _, entry := range sinceCreated(history) {
+		if entry.Revision == revision {
+			return entry.After, true
+		}
+		if entry.Before != nil && entry.Before.Revision == revision {
+			return *entry.Before, true
+		}
+	}
+	return Car{}, false
+}
+
+// sinceCreated returns the entries of history recorded since the latest
+// creation of the car, in their original order. The ID of a purged car can
+// be given to a new car, whose revisions start over, so the entries of the
+// purged car would be mistaken for those of the new one.
+func sinceCreated(history []CarAuditEntry) []CarAuditEntry {
+	var created *CarAuditEntry
+	for i, entry := range history {
+		if entry.Action == CarCreated && (created == nil || entry.Time.After(created.Time)) {
+			created = &history[i]
+		}
+	}
+	if created == nil {
+		return history
+	}
+
+	out := make([]CarAuditEntry, 0, len(history))
+	for i, entry := range history {
+		if &history[i] == created || entry.Action != CarCreated && !entry.Time.Before(created.Time) {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// carAuditFields lists the fields compared by Changes. Optional fields
+// are dereferenced, so that two pointers to equal values compare equal.
+var carAuditFields = []struct {
+	name  string
+	value func(Car) any
+}{
+	{"make", func(c Car) any { return zeroToNil(c.Make) }},
+	{"model", func(c Car) any { return zeroToNil(c.Model) }},
+	{"package", func(c Car) any { return deref(c.Package) }},
+	{"color", func(c Car) any { return zeroToNil(c.Color) }},
+	{"category", func(c Car) any { return zeroToNil(c.Category) }},
+	{"year", func(c Car) any { return zeroToNil(c.Year) }},
+	{"mileage", func(c Car) any { return deref(c.Mileage) }},
+	{"price", func(c Car) any { return deref(c.Price) }},
+	{"deleted_at", func(c Car) any { return deref(c.DeletedAt) }},
+}
+
+// zeroToNil returns nil for the zero value of T and v otherwise, so that
+// the fields of a car that did not exist yet read as unset.
+func zeroToNil[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+// deref returns the value p points to, or nil when p is nil.
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	u "cars/pkg/utils"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCarAuditEntry_Changes(t *testing.T) {
+	deletedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	car := Car{ID: "1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010, Price: u.Ptr(int64(1999900)), Revision: 1}
+
+	tests := []struct {
+		name     string
+		entry    CarAuditEntry
+		expected []CarFieldChange
+	}{
+		{
+			name:  "should list every set field on create",
+			entry: CarAuditEntry{Action: CarCreated, After: car},
+			expected: []CarFieldChange{
+				{Field: "make", To: "Ford"},
+				{Field: "model", To: "F10"},
+				{Field: "color", To: "Silver"},
+				{Field: "category", To: "Truck"},
+				{Field: "year", To: 2010},
+				{Field: "price", To: int64(1999900)},
+			},
+		},
+		{
+			name: "should list only changed fields on update",
+			entry: CarAuditEntry{
+				Action: CarUpdated,
+				Before: &car,
+				After:  Car{ID: "1", Make: "Ford", Model: "F10", Color: "Red", Category: "Truck", Year: 2010, Price: u.Ptr(int64(1999900)), Mileage: u.Ptr(int64(100)), Revision: 2},
+			},
+			expected: []CarFieldChange{
+				{Field: "color", From: "Silver", To: "Red"},
+				{Field: "mileage", To: int64(100)},
+			},
+		},
+		{
+			name: "should list the deletion time on delete",
+			entry: CarAuditEntry{
+				Action: CarDeleted,
+				Before: &car,
+				After:  Car{ID: "1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010, Price: u.Ptr(int64(1999900)), Revision: 2, DeletedAt: &deletedAt},
+			},
+			expected: []CarFieldChange{
+				{Field: "deleted_at", To: deletedAt},
+			},
+		},
+		{
+			name:     "should list nothing when only the revision changed",
+			entry:    CarAuditEntry{Action: CarUpdated, Before: &car, After: Car{ID: "1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010, Price: u.Ptr(int64(1999900)), Revision: 2}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := tt.entry.Changes()
+
+			// Assert
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCarAsOf(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := Car{ID: "1", Make: "Ford", Color: "Silver", Revision: 1}
+	updated := Car{ID: "1", Make: "Ford", Color: "Red", Revision: 2}
+	deleted := Car{ID: "1", Make: "Ford", Color: "Red", Revision: 3, DeletedAt: u.Ptr(at.Add(2 * time.Hour))}
+
+	history := []CarAuditEntry{
+		{Revision: 1, Action: CarCreated, Time: at, After: created},
+		{Revision: 2, Action: CarUpdated, Time: at.Add(time.Hour), Before: &created, After: updated},
+		{Revision: 3, Action: CarDeleted, Time: at.Add(2 * time.Hour), Before: &updated, After: deleted},
+	}
+
+	restored := Car{ID: "1", Make: "Ford", Color: "Red", Revision: 4}
+
+	// seeded was stored before the history was recorded, at revision 1.
+	seeded := Car{ID: "2", Make: "Kia", Color: "Blue", Revision: 1}
+	repainted := Car{ID: "2", Make: "Kia", Color: "Green", Revision: 2}
+
+	// reborn is a new car given the ID of the purged one.
+	reborn := Car{ID: "1", Make: "Kia", Color: "Blue", Revision: 1}
+	rebornUpdated := Car{ID: "1", Make: "Kia", Color: "Green", Revision: 2}
+	recreated := append(slices.Clone(history),
+		CarAuditEntry{Revision: 1, Action: CarCreated, Time: at.Add(4 * time.Hour), After: reborn},
+		CarAuditEntry{Revision: 2, Action: CarUpdated, Time: at.Add(5 * time.Hour), Before: &reborn, After: rebornUpdated},
+	)
+
+	tests := []struct {
+		name          string
+		history       []CarAuditEntry
+		current       *Car
+		at            time.Time
+		expected      Car
+		expectedFound bool
+	}{
+		{name: "should not find the car before it was created", history: history, current: &restored, at: at.Add(-time.Second)},
+		{name: "should find the car at its creation time", history: history, current: &restored, at: at, expected: created, expectedFound: true},
+		{name: "should find the car between two changes", history: history, current: &restored, at: at.Add(90 * time.Minute), expected: updated, expectedFound: true},
+		{name: "should find the deleted car after its deletion", history: history, at: at.Add(3 * time.Hour), expected: deleted, expectedFound: true},
+		{name: "should find the stored car after its last change", history: history, current: &restored, at: at.Add(3 * time.Hour), expected: restored, expectedFound: true},
+		{name: "should find the stored car without history", current: &seeded, at: at, expected: seeded, expectedFound: true},
+		{
+			name:          "should find the car before its first recorded change",
+			history:       []CarAuditEntry{{Revision: 2, Action: CarUpdated, Time: at, Before: &seeded, After: repainted}},
+			current:       &repainted,
+			at:            at.Add(-time.Hour),
+			expected:      seeded,
+			expectedFound: true,
+		},
+		{name: "should find the car created again under the ID of a purged car", history: recreated, current: &rebornUpdated, at: at.Add(270 * time.Minute), expected: reborn, expectedFound: true},
+		{name: "should not find the purged car once its ID was given to another", history: recreated, current: &rebornUpdated, at: at.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, found := CarAsOf(tt.history, tt.current, tt.at)
+
+			// Assert
+			if found != tt.expectedFound || !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %+v (%v), got %+v (%v)", tt.expected, tt.expectedFound, got, found)
+			}
+		})
+	}
+}
+
+func TestCarAtRevision(t *testing.T) {
+	created := Car{ID: "1", Make: "Ford", Color: "Silver", Revision: 1}
+	updated := Car{ID: "1", Make: "Ford", Color: "Red", Revision: 2}
+	history := []CarAuditEntry{
+		{Revision: 1, Action: CarCreated, After: created},
+		{Revision: 2, Action: CarUpdated, Before: &created, After: updated},
+	}
+
+	t.Run("should find the car at a recorded revision", func(t *testing.T) {
+		// Act
+		got, found := CarAtRevision(history, &updated, 1)
+
+		// Assert
+		if !found || !reflect.DeepEqual(got, created) {
+			t.Fatalf("expected %+v, got %+v (%v)", created, got, found)
+		}
+	})
+
+	t.Run("should find the car before its first recorded change", func(t *testing.T) {
+		// Arrange
+		partial := []CarAuditEntry{{Revision: 2, Action: CarUpdated, Before: &created, After: updated}}
+
+		// Act
+		got, found := CarAtRevision(partial, &updated, 1)
+
+		// Assert
+		if !found || !reflect.DeepEqual(got, created) {
+			t.Fatalf("expected %+v, got %+v (%v)", created, got, found)
+		}
+	})
+
+	t.Run("should find the stored car at its revision", func(t *testing.T) {
+		// Act
+		got, found := CarAtRevision(nil, &created, 1)
+
+		// Assert
+		if !found || !reflect.DeepEqual(got, created) {
+			t.Fatalf("expected %+v, got %+v (%v)", created, got, found)
+		}
+	})
+
+	t.Run("should not find the revisions of a purged car once its ID was given to another", func(t *testing.T) {
+		// Arrange
+		reborn := Car{ID: "1", Make: "Kia", Color: "Blue", Revision: 1}
+		recreated := []CarAuditEntry{
+			{Revision: 1, Action: CarCreated, Time: time.Unix(1, 0), After: created},
+			{Revision: 2, Action: CarUpdated, Time: time.Unix(2, 0), Before: &created, After: updated},
+			{Revision: 1, Action: CarCreated, Time: time.Unix(3, 0), After: reborn},
+		}
+
+		// Act
+		got, found := CarAtRevision(recreated, nil, 1)
+		_, foundPurged := CarAtRevision(recreated, nil, 2)
+
+		// Assert
+		if !found || !reflect.DeepEqual(got, reborn) {
+			t.Fatalf("expected %+v, got %+v (%v)", reborn, got, found)
+		}
+		if foundPurged {
+			t.Fatal("expected revision 2 of the purged car not to be found")
+		}
+	})
+
+	t.Run("should not find an unrecorded revision", func(t *testing.T) {
+		// Act
+		_, found := CarAtRevision(history, &updated, 3)
+
+		// Assert
+		if found {
+			t.Fatal("expected revision 3 not to be found")
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package models
+
+import "cars/pkg/filterexpr"
+
+// CarExprFields declares the car fields that filter expressions may refer
+// to. Package, mileage and price are optional and may be compared with NULL.
+var CarExprFields = map[string]filterexpr.Field{
+	"make":     {Kind: filterexpr.KindString},
+	"model":    {Kind: filterexpr.KindString},
+	"color":    {Kind: filterexpr.KindString},
+	"category": {Kind: filterexpr.KindString},
+	"package":  {Kind: filterexpr.KindString, Nullable: true},
+	"year":     {Kind: filterexpr.KindNumber},
+	"mileage":  {Kind: filterexpr.KindNumber, Nullable: true},
+	"price":    {Kind: filterexpr.KindNumber, Nullable: true},
+}
+
+// ParseCarExpr parses a filter expression and checks it against
+// CarExprFields.
+func ParseCarExpr(src string) (filterexpr.Expr, error) {
+	expr, err := filterexpr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := filterexpr.Check(expr, CarExprFields); err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// EvalCarExpr reports whether car satisfies a checked filter expression.
+func EvalCarExpr(expr filterexpr.Expr, car Car) bool {
+	return filterexpr.Eval(expr, func(field string) filterexpr.Value {
+		switch field {
+		case "make":
+			return filterexpr.String(car.Make)
+		case "model":
+			return filterexpr.String(car.Model)
+		case "color":
+			return filterexpr.String(car.Color)
+		case "category":
+			return filterexpr.String(car.Category)
+		case "package":
+			if car.Package != nil {
+				return filterexpr.String(*car.Package)
+			}
+		case "year":
+			return filterexpr.Number(int64(car.Year))
+		case "mileage":
+			if car.Mileage != nil {
+				return filterexpr.Number(*car.Mileage)
+			}
+		case "price":
+			if car.Price != nil {
+				return filterexpr.Number(*car.Price)
+			}
+		}
+		return filterexpr.Null()
+	})
+}
@@ -1,8 +1,300 @@
 package models
 
+import (
+	"cars/pkg/filterexpr"
+	"cars/pkg/search"
+	"cars/pkg/utils"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidRange is returned when a range filter has a lower bound
+// greater than its upper bound.
+var ErrInvalidRange = errors.New("invalid range")
+
 // CarFilters defines optional criteria used to filter cars when listing them.
+//
+// Each list filter matches a car whose attribute equals any of the given
+// values (OR), while different filters must all match (AND). An empty list
+// does not filter. String values are compared case-insensitively, and a car
+// without a package never matches a Package filter.
+//
+// Query restricts the result to cars matching a free-text search across
+// make, model, color, category and package (see CarRelevance).
+//
+// Repositories return matching cars ordered by Sort and then by ID, so the
+// order is always total and Limit and After can be used together for
+// keyset pagination. When Query is set and Sort is empty, cars are ranked
+// by decreasing relevance instead, again breaking ties by ID.
+//
+// Range bounds are inclusive and nil means unbounded. A car without a price
+// or mileage never matches a filter that bounds that value, since it is
+// unknown whether it lies in the range.
+//
+// Deleted cars never match unless IncludeDeleted is set.
 type CarFilters struct {
-	Make  string
-	Model string
-	Year  *int
+	Make     []string
+	Model    []string
+	Category []string
+	Color    []string
+	Package  []string
+	Year     []int
+
+	Query string
+
+	// Expr is an optional filter expression that cars must also satisfy.
+	// It must have been built with ParseCarExpr.
+	Expr filterexpr.Expr
+
+	MinYear    *int
+	MaxYear    *int
+	MinPrice   *int64
+	MaxPrice   *int64
+	MinMileage *int64
+	MaxMileage *int64
+
+	// IncludeDeleted makes soft-deleted cars match like live ones.
+	IncludeDeleted bool
+
+	// Sort lists the keys cars are ordered by before the ID tie-breaker.
+	// Empty means order by ID only.
+	Sort []CarSort
+
+	// Limit caps the number of cars returned. Zero means no limit.
+	Limit int
+
+	// After restricts the result to cars that sort strictly after the
+	// position described by the cursor. It must have been issued for the
+	// same Sort.
+	After *CarCursor
+}
+
+// Selective reports whether f restricts the cars it matches, that is,
+// whether any filter, range, query or expression is set.
+func (f CarFilters) Selective() bool {
+	return len(f.Make) > 0 || len(f.Model) > 0 || len(f.Category) > 0 ||
+		len(f.Color) > 0 || len(f.Package) > 0 || len(f.Year) > 0 ||
+		f.Query != "" || f.Expr != nil ||
+		f.MinYear != nil || f.MaxYear != nil ||
+		f.MinPrice != nil || f.MaxPrice != nil ||
+		f.MinMileage != nil || f.MaxMileage != nil
+}
+
+// Validate checks that every range filter has its lower bound less than
+// or equal to its upper bound and that Query, when set, has something
+// to search for.
+func (f CarFilters) Validate() error {
+	if f.Query != "" && len(search.QueryTokens(f.Query)) == 0 {
+		return ErrEmptySearchQuery
+	}
+	if err := validateRange("year", f.MinYear, f.MaxYear); err != nil {
+		return err
+	}
+	if err := validateRange("price", f.MinPrice, f.MaxPrice); err != nil {
+		return err
+	}
+	return validateRange("mileage", f.MinMileage, f.MaxMileage)
+}
+
+// Ranked reports whether matching cars are ordered by search relevance.
+func (f CarFilters) Ranked() bool {
+	return f.Query != "" && len(f.Sort) == 0
+}
+
+// Match reports whether car satisfies every filter criterion.
+//
+// Pagination (Limit and After) and ordering are not taken into account.
+// Use Matcher when evaluating the same filters against many cars.
+func (f CarFilters) Match(car Car) bool {
+	return f.Matcher().Match(car)
+}
+
+// Matcher compiles the filters into a CarMatcher.
+func (f CarFilters) Matcher() CarMatcher {
+	m := CarMatcher{
+		make:     foldSet(f.Make),
+		model:    foldSet(f.Model),
+		category: foldSet(f.Category),
+		color:    foldSet(f.Color),
+		pkg:      foldSet(f.Package),
+		filters:  f,
+	}
+
+	if len(f.Year) > 0 {
+		m.year = make(map[int]struct{}, len(f.Year))
+		for _, y := range f.Year {
+			m.year[y] = struct{}{}
+		}
+	}
+	return m
+}
+
+// CarMatcher evaluates CarFilters against cars.
+//
+// Value lists are compiled into case-folded hash sets, so each car is
+// checked in constant time regardless of how many values are given.
+type CarMatcher struct {
+	make, model, category, color, pkg map[string]struct{}
+	year                              map[int]struct{}
+	filters                           CarFilters
+}
+
+// Match reports whether car satisfies every filter criterion.
+func (m CarMatcher) Match(car Car) bool {
+	if car.Deleted() && !m.filters.IncludeDeleted {
+		return false
+	}
+
+	if !inFoldSet(m.make, car.Make) ||
+		!inFoldSet(m.model, car.Model) ||
+		!inFoldSet(m.category, car.Category) ||
+		!inFoldSet(m.color, car.Color) {
+		return false
+	}
+
+	if m.pkg != nil && (car.Package == nil || !inFoldSet(m.pkg, *car.Package)) {
+		return false
+	}
+
+	if m.year != nil {
+		if _, ok := m.year[car.Year]; !ok {
+			return false
+		}
+	}
+
+	f := m.filters
+	if f.Query != "" && CarRelevance(car, f.Query) == 0 {
+		return false
+	}
+
+	if f.Expr != nil && !EvalCarExpr(f.Expr, car) {
+		return false
+	}
+
+	return inRange(&car.Year, f.MinYear, f.MaxYear) &&
+		inRange(car.Price, f.MinPrice, f.MaxPrice) &&
+		inRange(car.Mileage, f.MinMileage, f.MaxMileage)
+}
+
+// foldSet returns the values with ASCII case folded as a set, or nil if
+// there are none. Only ASCII case is ignored, like SQLite's NOCASE.
+func foldSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[utils.Fold(v)] = struct{}{}
+	}
+	return set
+}
+
+// inFoldSet reports whether v is in set ignoring ASCII case. A nil set
+// contains everything.
+func inFoldSet(set map[string]struct{}, v string) bool {
+	if set == nil {
+		return true
+	}
+	_, ok := set[utils.Fold(v)]
+	return ok
+}
+
+// validateRange returns ErrInvalidRange if both bounds are set and min > max.
+func validateRange[T int | int64](name string, min, max *T) error {
+	if min != nil && max != nil && *min > *max {
+		return fmt.Errorf("%w: %s min %d is greater than max %d", ErrInvalidRange, name, *min, *max)
+	}
+	return nil
+}
+
+// inRange reports whether v lies within the inclusive bounds [min, max].
+// A nil v only matches when both bounds are nil.
+func inRange[T int | int64](v, min, max *T) bool {
+	if min == nil && max == nil {
+		return true
+	}
+	if v == nil {
+		return false
+	}
+	return (min == nil || *v >= *min) && (max == nil || *v <= *max)
+}
+
+// CarCursor identifies a position in an ordered list of cars.
+//
+// Besides the ID of the last car on the previous page, it records the
+// ordering it was issued for and the values of that car's sort keys.
+// Fields that are not part of Sort are left empty.
+type CarCursor struct {
+	ID   string
+	Sort []CarSort
+
+	// Score is the relevance of the last car when the listing is ranked
+	// by a search query, and zero otherwise.
+	Score float64
+
+	Make    string
+	Model   string
+	Year    int
+	Price   *int64
+	Mileage *int64
+}
+
+// NewCarCursor returns a cursor pointing just after car in a listing
+// ordered by sort.
+func NewCarCursor(car Car, sort []CarSort) *CarCursor {
+	c := &CarCursor{ID: car.ID, Sort: sort}
+	for _, key := range sort {
+		switch key.Field {
+		case SortByYear:
+			c.Year = car.Year
+		case SortByPrice:
+			c.Price = car.Price
+		case SortByMileage:
+			c.Mileage = car.Mileage
+		case SortByMake:
+			c.Make = car.Make
+		case SortByModel:
+			c.Model = car.Model
+		}
+	}
+	return c
+}
+
+// CursorAfter returns the cursor pointing just after car in the listing
+// of f, including car's relevance when the listing is ranked.
+func (f CarFilters) CursorAfter(car Car) *CarCursor {
+	c := NewCarCursor(car, f.Sort)
+	if f.Ranked() {
+		c.Score = CarRelevance(car, f.Query)
+	}
+	return c
+}
+
+// IssuedFor reports whether the cursor was issued for a listing ordered
+// the same way as one filtered by f.
+func (c CarCursor) IssuedFor(f CarFilters) bool {
+	return FormatCarSort(c.Sort) == FormatCarSort(f.Sort) && (c.Score > 0) == f.Ranked()
+}
+
+// Position returns a car holding the cursor's ID and sort key values,
+// suitable for comparison with CompareCars.
+func (c CarCursor) Position() Car {
+	return Car{
+		ID:      c.ID,
+		Make:    c.Make,
+		Model:   c.Model,
+		Year:    c.Year,
+		Price:   c.Price,
+		Mileage: c.Mileage,
+	}
+}
+
+// CarPage is a single page of cars returned by a paginated listing.
+type CarPage struct {
+	Cars Cars
+
+	// Next points to the position after the last car of this page,
+	// or is nil when there are no more cars.
+	Next *CarCursor
 }
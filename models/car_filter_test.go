@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCarFilters_Validate(t *testing.T) {
+	low, high := 1000, 2000
+	lowCents, highCents := int64(1000), int64(2000)
+
+	tests := []struct {
+		name    string
+		filters CarFilters
+		wantErr error
+	}{
+		{
+			name:    "should succeed without ranges",
+			filters: CarFilters{},
+		},
+		{
+			name:    "should succeed for open-ended ranges",
+			filters: CarFilters{MinYear: &high, MaxPrice: &lowCents},
+		},
+		{
+			name:    "should succeed when min equals max",
+			filters: CarFilters{MinMileage: &lowCents, MaxMileage: &lowCents},
+		},
+		{
+			name:    "should fail when min year is greater than max year",
+			filters: CarFilters{MinYear: &high, MaxYear: &low},
+			wantErr: ErrInvalidRange,
+		},
+		{
+			name:    "should fail when min price is greater than max price",
+			filters: CarFilters{MinPrice: &highCents, MaxPrice: &lowCents},
+			wantErr: ErrInvalidRange,
+		},
+		{
+			name:    "should fail when min mileage is greater than max mileage",
+			filters: CarFilters{MinMileage: &highCents, MaxMileage: &lowCents},
+			wantErr: ErrInvalidRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filters.Validate()
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCarFilters_Match(t *testing.T) {
+	year, minYear := 2020, 2019
+	price, minPrice, maxPrice := int64(2500000), int64(2000000), int64(3000000)
+
+	car := Car{Make: "Toyota", Model: "Camry", Year: year, Price: &price}
+
+	deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted := Car{Make: "Toyota", DeletedAt: &deletedAt}
+
+	tests := []struct {
+		name    string
+		car     Car
+		filters CarFilters
+		want    bool
+	}{
+		{name: "should match without filters", car: car, filters: CarFilters{}, want: true},
+		{name: "should match make ignoring case", car: car, filters: CarFilters{Make: []string{"toyota"}}, want: true},
+		{name: "should not match other model", car: car, filters: CarFilters{Model: []string{"Rav4"}}, want: false},
+		{name: "should match any of several makes", car: car, filters: CarFilters{Make: []string{"Ford", "TOYOTA"}}, want: true},
+		{name: "should only ignore ASCII case, like SQLite", car: Car{Make: "škoda"}, filters: CarFilters{Make: []string{"ŠKODA"}}, want: false},
+		{name: "should require every filter to match", car: car, filters: CarFilters{Make: []string{"Toyota"}, Category: []string{"SUV"}}, want: false},
+		{name: "should match any of several years", car: car, filters: CarFilters{Year: []int{2019, 2020}}, want: true},
+		{name: "should not match missing package", car: car, filters: CarFilters{Package: []string{"SE"}}, want: false},
+		{name: "should match inclusive bounds", car: car, filters: CarFilters{MinYear: &year, MaxYear: &year}, want: true},
+		{name: "should not match below min", car: Car{Year: 2018}, filters: CarFilters{MinYear: &minYear}, want: false},
+		{name: "should match price within range", car: car, filters: CarFilters{MinPrice: &minPrice, MaxPrice: &maxPrice}, want: true},
+		{name: "should not match price above max", car: car, filters: CarFilters{MaxPrice: &minPrice}, want: false},
+		{name: "should not match missing price when bounded", car: Car{}, filters: CarFilters{MaxPrice: &maxPrice}, want: false},
+		{name: "should match missing mileage when unbounded", car: car, filters: CarFilters{MinPrice: &minPrice}, want: true},
+		{name: "should not match deleted car", car: deleted, filters: CarFilters{}, want: false},
+		{name: "should match deleted car when included", car: deleted, filters: CarFilters{Make: []string{"toyota"}, IncludeDeleted: true}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filters.Match(tt.car); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
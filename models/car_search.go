@@ -0,0 +1,41 @@
+package models
+
+import (
+	"cars/pkg/search"
+	"cmp"
+	"errors"
+)
+
+// ErrEmptySearchQuery is returned when a search query contains no letters
+// or digits to search for.
+var ErrEmptySearchQuery = errors.New("search query has no searchable terms")
+
+// CarSearchFields returns the searchable text of car with the weight of
+// each field. Make and model weigh the most, followed by package, color
+// and category.
+func CarSearchFields(car Car) []search.Field {
+	fields := []search.Field{
+		{Text: car.Make, Weight: 3},
+		{Text: car.Model, Weight: 3},
+		{Text: car.Color, Weight: 1},
+		{Text: car.Category, Weight: 1},
+	}
+	if car.Package != nil {
+		fields = append(fields, search.Field{Text: *car.Package, Weight: 2})
+	}
+	return fields
+}
+
+// CarRelevance returns how well car matches query, or zero if it does not.
+func CarRelevance(car Car, query string) float64 {
+	return search.Score(query, CarSearchFields(car)...)
+}
+
+// CompareRanked compares two search results given their relevance:
+// higher relevance comes first and ties are broken by ID.
+func CompareRanked(a Car, aScore float64, b Car, bScore float64) int {
+	if c := cmp.Compare(bScore, aScore); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
@@ -0,0 +1,38 @@
+package models
+
+import "errors"
+
+var (
+	// ErrSelectorRequired is returned when a bulk operation selects no cars,
+	// or selects them with a filter that matches every car.
+	ErrSelectorRequired = errors.New("either ids or a filter is required")
+
+	// ErrSelectorConflict is returned when a bulk operation selects cars
+	// both by ID and with a filter.
+	ErrSelectorConflict = errors.New("ids and a filter cannot be combined")
+)
+
+// CarSelector selects the cars a bulk operation applies to: either the
+// cars with the given IDs, or the cars matching Filters.
+//
+// IDs that do not exist select nothing. The ordering and paging fields of
+// Filters (Sort, Limit and After) are ignored.
+type CarSelector struct {
+	IDs     []string
+	Filters *CarFilters
+}
+
+// Validate checks that the selector uses exactly one of IDs and Filters,
+// and that Filters, when used, is valid and restricts the cars it matches,
+// so that a bulk operation never applies to every car by accident.
+func (s CarSelector) Validate() error {
+	switch {
+	case len(s.IDs) > 0 && s.Filters != nil:
+		return ErrSelectorConflict
+	case len(s.IDs) > 0:
+		return nil
+	case s.Filters == nil || !s.Filters.Selective():
+		return ErrSelectorRequired
+	}
+	return s.Filters.Validate()
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"cars/pkg/filterexpr"
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnknownSortField is returned when a sort expression refers to a
+	// field cars cannot be ordered by.
+	ErrUnknownSortField = errors.New("unknown sort field")
+
+	// ErrDuplicateSortField is returned when a sort expression lists the
+	// same field more than once.
+	ErrDuplicateSortField = errors.New("duplicate sort field")
+)
+
+// CarSortField names a car attribute that listings can be ordered by.
+type CarSortField string
+
+const (
+	SortByYear    CarSortField = "year"
+	SortByPrice   CarSortField = "price"
+	SortByMileage CarSortField = "mileage"
+	SortByMake    CarSortField = "make"
+	SortByModel   CarSortField = "model"
+)
+
+// CarSortFields lists every supported CarSortField.
+var CarSortFields = []CarSortField{SortByYear, SortByPrice, SortByMileage, SortByMake, SortByModel}
+
+// CarSort is a single key of a multi-key ordering.
+type CarSort struct {
+	Field CarSortField
+	Desc  bool
+}
+
+// String returns the key in the form accepted by ParseCarSort,
+// e.g. "year" or "-price".
+func (s CarSort) String() string {
+	if s.Desc {
+		return "-" + string(s.Field)
+	}
+	return string(s.Field)
+}
+
+// ParseCarSort parses a comma-separated list of sort keys such as
+// "-price,year". A leading "-" sorts that key in descending order.
+// An empty expression yields no keys.
+func ParseCarSort(expr string) ([]CarSort, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+
+	var keys []CarSort
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+
+		key := CarSort{Field: CarSortField(strings.TrimPrefix(part, "-"))}
+		key.Desc = key.Field != CarSortField(part)
+
+		if !slices.Contains(CarSortFields, key.Field) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownSortField, key.Field)
+		}
+
+		if slices.ContainsFunc(keys, func(k CarSort) bool { return k.Field == key.Field }) {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateSortField, key.Field)
+		}
+
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// FormatCarSort is the inverse of ParseCarSort.
+func FormatCarSort(keys []CarSort) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// CompareCars compares a and b by the given keys, breaking ties by ID,
+// and returns -1, 0 or +1.
+//
+// Make and model are compared case-insensitively (ASCII only, like
+// SQLite's NOCASE collation). A missing price or mileage sorts before any
+// value, so it comes first in ascending and last in descending order.
+func CompareCars(a, b Car, keys []CarSort) int {
+	for _, key := range keys {
+		var c int
+		switch key.Field {
+		case SortByYear:
+			c = cmp.Compare(a.Year, b.Year)
+		case SortByPrice:
+			c = compareOptional(a.Price, b.Price)
+		case SortByMileage:
+			c = compareOptional(a.Mileage, b.Mileage)
+		case SortByMake:
+			c = filterexpr.CompareFold(a.Make, b.Make)
+		case SortByModel:
+			c = filterexpr.CompareFold(a.Model, b.Model)
+		}
+
+		if key.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// SortCars sorts cars in place by the given keys, then by ID.
+func SortCars(cars Cars, keys []CarSort) {
+	slices.SortFunc(cars, func(a, b Car) int {
+		return CompareCars(a, b, keys)
+	})
+}
+
+// compareOptional compares two optional values, with nil sorting first.
+func compareOptional(a, b *int64) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return cmp.Compare(*a, *b)
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCarSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    []CarSort
+		wantErr error
+	}{
+		{
+			name: "should return no keys for empty expression",
+			expr: "",
+			want: nil,
+		},
+		{
+			name: "should parse ascending and descending keys in order",
+			expr: "-price,year",
+			want: []CarSort{{Field: SortByPrice, Desc: true}, {Field: SortByYear}},
+		},
+		{
+			name: "should ignore whitespace around keys",
+			expr: " make , -model ",
+			want: []CarSort{{Field: SortByMake}, {Field: SortByModel, Desc: true}},
+		},
+		{
+			name:    "should fail for unknown field",
+			expr:    "year,color",
+			wantErr: ErrUnknownSortField,
+		},
+		{
+			name:    "should fail for empty key",
+			expr:    "year,,price",
+			wantErr: ErrUnknownSortField,
+		},
+		{
+			name:    "should fail for duplicate field",
+			expr:    "year,-year",
+			wantErr: ErrDuplicateSortField,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCarSort(tt.expr)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+
+			if err == nil && FormatCarSort(got) != FormatCarSort(tt.want) {
+				t.Fatalf("expected %q, got %q", FormatCarSort(tt.want), FormatCarSort(got))
+			}
+		})
+	}
+}
+
+func TestSortCars(t *testing.T) {
+	price := func(v int64) *int64 { return &v }
+
+	cars := Cars{
+		{ID: "1", Make: "toyota", Year: 2019, Price: price(2000)},
+		{ID: "2", Make: "Ford", Year: 2022},
+		{ID: "3", Make: "Toyota", Year: 2018, Price: price(3000)},
+		{ID: "4", Make: "ford", Year: 2010, Price: price(2000)},
+	}
+
+	tests := []struct {
+		name string
+		sort string
+		want []string
+	}{
+		{name: "should order by ID without keys", sort: "", want: []string{"1", "2", "3", "4"}},
+		{name: "should put missing values first ascending", sort: "price", want: []string{"2", "1", "4", "3"}},
+		{name: "should put missing values last descending", sort: "-price", want: []string{"3", "1", "4", "2"}},
+		{name: "should compare make ignoring case", sort: "make,-year", want: []string{"2", "4", "1", "3"}},
+		{name: "should break ties with the next key", sort: "price,year", want: []string{"2", "4", "1", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseCarSort(tt.sort)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			sorted := append(Cars(nil), cars...)
+			SortCars(sorted, keys)
+
+			got := make([]string, len(sorted))
+			for i, car := range sorted {
+				got[i] = car.ID
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
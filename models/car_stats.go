@@ -0,0 +1,190 @@
+package models
+
+import (
+	"cars/pkg/filterexpr"
+	"cars/pkg/utils"
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Facet is the number of cars sharing a value of an attribute.
+type Facet[T comparable] struct {
+	Value T
+	Count int
+}
+
+// CarValueStats summarises an optional numeric attribute over the cars
+// that have it.
+type CarValueStats struct {
+	Count int
+	Min   int64
+	Max   int64
+
+	// Avg is the mean value, rounded to the nearest integer.
+	Avg int64
+}
+
+// CarStats summarises a set of cars: how many there are, how many share
+// each make, model, category, color and year, and the range of their
+// prices and mileages.
+//
+// Facets are ordered by decreasing count, then by value. Text values are
+// grouped ignoring ASCII case, like filters compare them; each group is
+// reported under its most frequent spelling (see Spellings.Name). Price and
+// Mileage are nil when no car has a value.
+type CarStats struct {
+	Count int
+
+	Make     []Facet[string]
+	Model    []Facet[string]
+	Category []Facet[string]
+	Color    []Facet[string]
+	Year     []Facet[int]
+
+	Price   *CarValueStats
+	Mileage *CarValueStats
+}
+
+// CarStatsCollector computes CarStats from cars added one at a time, so
+// that a listing can be summarised without being held in memory.
+// The zero value is ready to use.
+type CarStatsCollector struct {
+	count int
+
+	make, model, category, color textFacets
+	year                         map[int]int
+
+	price, mileage valueStats
+}
+
+// Add counts car in the statistics.
+func (c *CarStatsCollector) Add(car Car) {
+	c.count++
+
+	c.make.add(car.Make)
+	c.model.add(car.Model)
+	c.category.add(car.Category)
+	c.color.add(car.Color)
+
+	if c.year == nil {
+		c.year = make(map[int]int)
+	}
+	c.year[car.Year]++
+
+	c.price.add(car.Price)
+	c.mileage.add(car.Mileage)
+}
+
+// Stats returns the statistics of the cars added so far.
+func (c *CarStatsCollector) Stats() CarStats {
+	years := make([]Facet[int], 0, len(c.year))
+	for year, count := range c.year {
+		years = append(years, Facet[int]{Value: year, Count: count})
+	}
+	sortFacets(years, cmp.Compare[int])
+
+	return CarStats{
+		Count:    c.count,
+		Make:     c.make.facets(),
+		Model:    c.model.facets(),
+		Category: c.category.facets(),
+		Color:    c.color.facets(),
+		Year:     years,
+		Price:    c.price.stats(),
+		Mileage:  c.mileage.stats(),
+	}
+}
+
+// Spellings counts the values of a group of text values equal ignoring
+// ASCII case, by spelling.
+type Spellings map[string]int
+
+// Name returns the spelling a group is reported under: the most frequent
+// one, ties going to the smallest in byte order so that the name does not
+// depend on the order the values were counted in. It returns "" if there
+// are none.
+func (s Spellings) Name() string {
+	var name string
+	var best int
+	for spelling, count := range s {
+		if count > best || count == best && spelling < name {
+			name, best = spelling, count
+		}
+	}
+	return name
+}
+
+// textFacets counts text values grouped ignoring ASCII case, by the fold
+// of the group.
+type textFacets map[string]Spellings
+
+func (t *textFacets) add(value string) {
+	if *t == nil {
+		*t = make(textFacets)
+	}
+
+	key := utils.Fold(value)
+	if (*t)[key] == nil {
+		(*t)[key] = make(Spellings)
+	}
+	(*t)[key][value]++
+}
+
+func (t textFacets) facets() []Facet[string] {
+	out := make([]Facet[string], 0, len(t))
+	for _, spellings := range t {
+		f := Facet[string]{Value: spellings.Name()}
+		for _, count := range spellings {
+			f.Count += count
+		}
+		out = append(out, f)
+	}
+	sortFacets(out, filterexpr.CompareFold)
+	return out
+}
+
+// sortFacets orders facets by decreasing count, then by value.
+func sortFacets[T comparable](facets []Facet[T], compare func(a, b T) int) {
+	slices.SortFunc(facets, func(a, b Facet[T]) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return compare(a.Value, b.Value)
+	})
+}
+
+// valueStats accumulates an optional numeric attribute.
+type valueStats struct {
+	count    int
+	min, max int64
+	sum      float64
+}
+
+func (v *valueStats) add(value *int64) {
+	if value == nil {
+		return
+	}
+
+	if v.count == 0 || *value < v.min {
+		v.min = *value
+	}
+	if v.count == 0 || *value > v.max {
+		v.max = *value
+	}
+	v.sum += float64(*value)
+	v.count++
+}
+
+func (v valueStats) stats() *CarValueStats {
+	if v.count == 0 {
+		return nil
+	}
+
+	return &CarValueStats{
+		Count: v.count,
+		Min:   v.min,
+		Max:   v.max,
+		Avg:   int64(math.Round(v.sum / float64(v.count))),
+	}
+}
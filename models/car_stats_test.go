@@ -0,0 +1,77 @@
+package models
+
+import (
+	u "cars/pkg/utils"
+	"reflect"
+	"testing"
+)
+
+func TestCarStatsCollector(t *testing.T) {
+	t.Run("should count facets and summarise values", func(t *testing.T) {
+		// Arrange
+		cars := Cars{
+			{Make: "ford", Model: "F10", Category: "Truck", Color: "Silver", Year: 2010, Price: u.Ptr(int64(1999900)), Mileage: u.Ptr(int64(120123))},
+			{Make: "Toyota", Model: "Camry", Category: "Sedan", Color: "White", Year: 2019, Price: u.Ptr(int64(2899000))},
+			{Make: "Toyota", Model: "Rav4", Category: "SUV", Color: "Red", Year: 2019, Price: u.Ptr(int64(2275001))},
+			{Make: "Ford", Model: "Bronco", Category: "SUV", Color: "red", Year: 2022},
+		}
+
+		var collector CarStatsCollector
+
+		// Act
+		for _, car := range cars {
+			collector.Add(car)
+		}
+		got := collector.Stats()
+
+		// Assert
+		expected := CarStats{
+			Count:    4,
+			Make:     []Facet[string]{{Value: "Ford", Count: 2}, {Value: "Toyota", Count: 2}},
+			Model:    []Facet[string]{{Value: "Bronco", Count: 1}, {Value: "Camry", Count: 1}, {Value: "F10", Count: 1}, {Value: "Rav4", Count: 1}},
+			Category: []Facet[string]{{Value: "SUV", Count: 2}, {Value: "Sedan", Count: 1}, {Value: "Truck", Count: 1}},
+			Color:    []Facet[string]{{Value: "Red", Count: 2}, {Value: "Silver", Count: 1}, {Value: "White", Count: 1}},
+			Year:     []Facet[int]{{Value: 2019, Count: 2}, {Value: 2010, Count: 1}, {Value: 2022, Count: 1}},
+			Price:    &CarValueStats{Count: 3, Min: 1999900, Max: 2899000, Avg: 2391300},
+			Mileage:  &CarValueStats{Count: 1, Min: 120123, Max: 120123, Avg: 120123},
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+	})
+
+	t.Run("should report groups under their most frequent spelling, ignoring only ASCII case", func(t *testing.T) {
+		// Arrange
+		var collector CarStatsCollector
+		for _, name := range []string{"FORD", "Ford", "ford", "Ford", "Škoda", "škoda"} {
+			collector.Add(Car{Make: name})
+		}
+
+		// Act
+		got := collector.Stats()
+
+		// Assert
+		expected := []Facet[string]{{Value: "Ford", Count: 4}, {Value: "Škoda", Count: 1}, {Value: "škoda", Count: 1}}
+		if !reflect.DeepEqual(got.Make, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, got.Make)
+		}
+	})
+
+	t.Run("should return empty facets and no values without cars", func(t *testing.T) {
+		// Arrange
+		var collector CarStatsCollector
+
+		// Act
+		got := collector.Stats()
+
+		// Assert
+		if got.Count != 0 || got.Make == nil || len(got.Make) != 0 || got.Year == nil || len(got.Year) != 0 {
+			t.Fatalf("expected empty non-nil facets, got %+v", got)
+		}
+
+		if got.Price != nil || got.Mileage != nil {
+			t.Fatalf("expected no price or mileage stats, got %+v and %+v", got.Price, got.Mileage)
+		}
+	})
+}
@@ -249,6 +249,64 @@ func Test_ValidateForUpdate(t *testing.T) {
 	}
 }
 
+func Test_ValidateForInsert(t *testing.T) {
+	validCar := Car{
+		ID:       "JHK290XJ",
+		Make:     "Toyota",
+		Model:    "Corolla",
+		Color:    "Black",
+		Category: "Sedan",
+		Year:     time.Now().Year(),
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{name: "should succeed for a valid ID", id: "JHK290XJ"},
+		{name: "should succeed for an ID with hyphens", id: "lot-7-a"},
+		{name: "should fail when ID is missing", id: "", wantErr: ErrInvalidCarID},
+		{name: "should fail when ID has a space", id: "JHK 290", wantErr: ErrInvalidCarID},
+		{name: "should fail when ID has a slash", id: "JHK/290", wantErr: ErrInvalidCarID},
+		{name: "should fail when ID has a non-ASCII letter", id: "VÉLO", wantErr: ErrInvalidCarID},
+		{name: "should fail when ID names the stats route", id: "stats", wantErr: ErrCarIDReserved},
+		{name: "should fail when ID names the bulk route", id: "bulk", wantErr: ErrCarIDReserved},
+		{name: "should fail when ID names the import route", id: "import", wantErr: ErrCarIDReserved},
+		{name: "should succeed for a reserved ID in another case", id: "Stats"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			car := validCar
+			car.ID = tt.id
+
+			// Act
+			err := car.ValidateForInsert()
+
+			// Assert
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("should validate the other fields", func(t *testing.T) {
+		// Arrange
+		car := validCar
+		car.Make = ""
+
+		// Act
+		err := car.ValidateForInsert()
+
+		// Assert
+		if !errors.Is(err, ErrCarMakeRequired) {
+			t.Fatalf("expected error %v, got %v", ErrCarMakeRequired, err)
+		}
+	})
+}
+
 func Test_Car_validate(t *testing.T) {
 	currentYear := time.Now().Year()
 
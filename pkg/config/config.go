@@ -0,0 +1,136 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// StorageMemory selects the in-memory repository seeded with sample data.
+	StorageMemory = "memory"
+
+	// StorageSQLite selects the durable SQLite-backed repository.
+	StorageSQLite = "sqlite"
+)
+
+const (
+	// IDStrategyHex generates random hexadecimal car IDs.
+	IDStrategyHex = "hex"
+
+	// IDStrategyUUIDv7 generates time-ordered UUIDv7 car IDs.
+	IDStrategyUUIDv7 = "uuidv7"
+
+	// IDStrategyULID generates time-ordered ULID car IDs.
+	IDStrategyULID = "ulid"
+)
+
+// Config holds the application settings.
+type Config struct {
+	// Storage is the repository backend, either StorageMemory or StorageSQLite.
+	Storage string
+
+	// SQLiteDSN is the data source name used when Storage is StorageSQLite.
+	SQLiteDSN string
+
+	// IDStrategy selects how new car IDs are generated: IDStrategyHex,
+	// IDStrategyUUIDv7 or IDStrategyULID.
+	IDStrategy string
+
+	// PurgeRetention is how long deleted cars are kept before a purge
+	// removes them for good.
+	PurgeRetention time.Duration
+
+	// IdempotencyTTL is how long the response to a request carrying an
+	// Idempotency-Key header is kept for replay.
+	IdempotencyTTL time.Duration
+
+	// Upsert makes PUT /cars/{id} create the car when no car has that ID.
+	Upsert bool
+
+	// AdminToken is the bearer token that grants admin access, which
+	// listing deleted cars requires. When empty, no request is an admin.
+	AdminToken string
+}
+
+const (
+	// DefaultPurgeRetention is the PurgeRetention used when none is configured.
+	DefaultPurgeRetention = 30 * 24 * time.Hour
+
+	// DefaultIdempotencyTTL is the IdempotencyTTL used when none is configured.
+	DefaultIdempotencyTTL = 24 * time.Hour
+)
+
+// Load builds a Config from environment variables, applying defaults
+// for any variable that is unset or blank.
+//
+// Variables:
+//
+//	CARS_STORAGE          - repository backend: "memory" (default) or "sqlite"
+//	CARS_SQLITE_DSN       - SQLite database path or DSN (default "cars.db")
+//	CARS_ID_STRATEGY      - car ID format: "hex" (default), "uuidv7" or "ulid"
+//	CARS_PURGE_RETENTION  - how long deleted cars are kept, as a Go duration
+//	                        such as "72h" (default "720h", 30 days)
+//	CARS_IDEMPOTENCY_TTL  - how long responses are kept for Idempotency-Key
+//	                        replays, as a Go duration (default "24h")
+//	CARS_UPSERT           - "true" to let PUT create missing cars
+//	                        (default "false")
+//	CARS_ADMIN_TOKEN      - bearer token granting admin access (default
+//	                        none: no request is an admin)
+//
+// An invalid boolean, or an invalid or negative duration, is logged and
+// replaced by the default.
+func Load() Config {
+	return Config{
+		Storage:        strings.ToLower(getEnv("CARS_STORAGE", StorageMemory)),
+		SQLiteDSN:      getEnv("CARS_SQLITE_DSN", "cars.db"),
+		IDStrategy:     strings.ToLower(getEnv("CARS_ID_STRATEGY", IDStrategyHex)),
+		PurgeRetention: getDurationEnv("CARS_PURGE_RETENTION", DefaultPurgeRetention),
+		IdempotencyTTL: getDurationEnv("CARS_IDEMPOTENCY_TTL", DefaultIdempotencyTTL),
+		Upsert:         getBoolEnv("CARS_UPSERT", false),
+		AdminToken:     getEnv("CARS_ADMIN_TOKEN", ""),
+	}
+}
+
+// getDurationEnv parses the environment variable key as a non-negative
+// duration, returning fallback when it is unset, blank or invalid.
+func getDurationEnv(key string, fallback time.Duration) time.Duration {
+	v := getEnv(key, "")
+	if v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("warning: invalid %s %q, using %s", key, v, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getBoolEnv parses the environment variable key as a boolean, returning
+// fallback when it is unset, blank or invalid.
+func getBoolEnv(key string, fallback bool) bool {
+	v := getEnv(key, "")
+	if v == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("warning: invalid %s %q, using %t", key, v, fallback)
+		return fallback
+	}
+	return b
+}
+
+// getEnv returns the trimmed value of the environment variable key,
+// or fallback when it is unset or blank.
+func getEnv(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
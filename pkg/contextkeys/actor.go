@@ -0,0 +1,9 @@
+package contextkeys
+
+// ActorKeyType is the context key type used to store
+// the actor making a request in a context.Context.
+type ActorKeyType struct{}
+
+// ActorKey is the context key used to store and retrieve the
+// actor on whose behalf a request changes data.
+var ActorKey = ActorKeyType{}
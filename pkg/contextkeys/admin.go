@@ -0,0 +1,9 @@
+package contextkeys
+
+// AdminKeyType is the context key type used to mark a request made
+// with admin access in a context.Context.
+type AdminKeyType struct{}
+
+// AdminKey is the context key used to store and retrieve whether a
+// request was made with admin access. Its value is a bool.
+var AdminKey = AdminKeyType{}
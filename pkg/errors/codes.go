@@ -11,7 +11,40 @@ const (
 	CodeValidationFailed = "VALIDATION_FAILED"
 	MsgValidationFailed  = "Validation failed"
 
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	MsgUnsupportedMediaType  = "Unsupported media type"
+
+	CodeNotAcceptable = "NOT_ACCEPTABLE"
+	MsgNotAcceptable  = "Not acceptable"
+
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	MsgPreconditionFailed  = "Precondition failed"
+
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+	MsgIdempotencyKeyReused  = "Idempotency key reused with a different request"
+
+	CodeIdempotencyKeyInUse = "IDEMPOTENCY_KEY_IN_USE"
+	MsgIdempotencyKeyInUse  = "A request with this idempotency key is in progress"
+
+	CodeAdminRequired = "ADMIN_REQUIRED"
+	MsgAdminRequired  = "Admin access required"
+
 	// Car-Specific Errors
 	CodeCarNotFound = "CAR_NOT_FOUND"
 	MsgCarNotFound  = "Car not found"
+
+	CodeCarExists = "CAR_EXISTS"
+	MsgCarExists  = "Car already exists"
+
+	CodeCarIDReserved = "CAR_ID_RESERVED"
+	MsgCarIDReserved  = "Car ID is reserved"
+
+	CodeCarNotDeleted = "CAR_NOT_DELETED"
+	MsgCarNotDeleted  = "Car is not deleted"
+
+	CodeMakeNotFound = "MAKE_NOT_FOUND"
+	MsgMakeNotFound  = "Make not found"
+
+	CodePatchTestFailed = "PATCH_TEST_FAILED"
+	MsgPatchTestFailed  = "Patch test operation failed"
 )
@@ -34,8 +34,76 @@ func NewValidationError(err error) *ServiceError {
 	return wrap(CodeValidationFailed, http.StatusBadRequest, MsgValidationFailed, err)
 }
 
+// NewUnsupportedMediaTypeError returns a ServiceError indicating that the
+// request body is sent in a format the endpoint does not accept.
+func NewUnsupportedMediaTypeError(err error) *ServiceError {
+	return wrap(CodeUnsupportedMediaType, http.StatusUnsupportedMediaType, MsgUnsupportedMediaType, err)
+}
+
+// NewNotAcceptableError returns a ServiceError indicating that the endpoint
+// cannot respond in any of the formats listed in the request's Accept header.
+func NewNotAcceptableError(err error) *ServiceError {
+	return wrap(CodeNotAcceptable, http.StatusNotAcceptable, MsgNotAcceptable, err)
+}
+
+// NewPreconditionFailedError returns a ServiceError indicating that a
+// conditional request (If-Match) did not match the current resource state.
+func NewPreconditionFailedError(err error) *ServiceError {
+	return wrap(CodePreconditionFailed, http.StatusPreconditionFailed, MsgPreconditionFailed, err)
+}
+
+// NewIdempotencyKeyReusedError returns a ServiceError indicating that an
+// Idempotency-Key was sent again with a request that differs from the one
+// it was first used with.
+func NewIdempotencyKeyReusedError(err error) *ServiceError {
+	return wrap(CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, MsgIdempotencyKeyReused, err)
+}
+
+// NewIdempotencyKeyInUseError returns a ServiceError indicating that the
+// first request sent with an Idempotency-Key has not completed yet.
+func NewIdempotencyKeyInUseError(err error) *ServiceError {
+	return wrap(CodeIdempotencyKeyInUse, http.StatusConflict, MsgIdempotencyKeyInUse, err)
+}
+
+// NewAdminRequiredError returns a ServiceError indicating that the request
+// asks for something only admins may see.
+func NewAdminRequiredError(err error) *ServiceError {
+	return wrap(CodeAdminRequired, http.StatusForbidden, MsgAdminRequired, err)
+}
+
 // NewCarNotFoundError returns a ServiceError indicating that a car resource
 // could not be found.
 func NewCarNotFoundError(err error) *ServiceError {
 	return wrap(CodeCarNotFound, http.StatusNotFound, MsgCarNotFound, err)
 }
+
+// NewCarExistsError returns a ServiceError indicating that a car cannot
+// be created under an ID another car, possibly a deleted one, already has.
+func NewCarExistsError(err error) *ServiceError {
+	return wrap(CodeCarExists, http.StatusConflict, MsgCarExists, err)
+}
+
+// NewCarIDReservedError returns a ServiceError indicating that a car cannot
+// be created under an ID that names another route.
+func NewCarIDReservedError(err error) *ServiceError {
+	return wrap(CodeCarIDReserved, http.StatusUnprocessableEntity, MsgCarIDReserved, err)
+}
+
+// NewCarNotDeletedError returns a ServiceError indicating that a car
+// cannot be restored because it has not been deleted.
+func NewCarNotDeletedError(err error) *ServiceError {
+	return wrap(CodeCarNotDeleted, http.StatusConflict, MsgCarNotDeleted, err)
+}
+
+// NewMakeNotFoundError returns a ServiceError indicating that no car has
+// the requested make.
+func NewMakeNotFoundError(err error) *ServiceError {
+	return wrap(CodeMakeNotFound, http.StatusNotFound, MsgMakeNotFound, err)
+}
+
+// NewPatchTestFailedError returns a ServiceError indicating that a patch
+// could not be applied because one of its "test" operations did not match
+// the current state of the resource.
+func NewPatchTestFailedError(err error) *ServiceError {
+	return wrap(CodePatchTestFailed, http.StatusConflict, MsgPatchTestFailed, err)
+}
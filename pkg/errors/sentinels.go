@@ -6,8 +6,20 @@ var (
 	ErrEmptyBody           = errors.New("empty body")
 	ErrMultipleJSONObjects = errors.New("multiple JSON objects in request body")
 	ErrUnexpectedJSONData  = errors.New("unexpected data after JSON object")
+	ErrUnsupportedMedia    = errors.New("unsupported content type")
+	ErrNotAcceptable       = errors.New("no acceptable content type")
 
-	ErrCarNotFound = errors.New("car not found")
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used with a different request")
+	ErrIdempotencyKeyInUse  = errors.New("idempotency key is in use by a request in progress")
+
+	ErrAdminRequired = errors.New("admin access required")
+
+	ErrCarNotFound      = errors.New("car not found")
+	ErrCarExists        = errors.New("car already exists")
+	ErrIDCollision      = errors.New("could not generate an unused id")
+	ErrCarNotDeleted    = errors.New("car is not deleted")
+	ErrMakeNotFound     = errors.New("make not found")
+	ErrRevisionMismatch = errors.New("revision does not match")
 
 	ErrIDRequired = errors.New("id is required")
 )
@@ -0,0 +1,107 @@
+// Package filterexpr parses and evaluates boolean filter expressions such as
+//
+//	(make = Toyota AND price < 2500000) OR category IN ('Truck', 'SUV')
+//
+// Grammar (keywords are case-insensitive):
+//
+//	expr       = term { "OR" term }
+//	term       = factor { "AND" factor }
+//	factor     = "NOT" factor | "(" expr ")" | comparison
+//	comparison = field op value
+//	           | field [ "NOT" ] "IN" "(" value { "," value } ")"
+//	op         = "=" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//	value      = integer | string | word | "NULL"
+//
+// Strings are enclosed in single or double quotes, with the quote doubled to
+// include it literally. A word is an unquoted run of letters, digits, "_"
+// and "-" that is not a keyword, and stands for the string it spells.
+//
+// Expressions are parsed into an AST with Parse, checked against a set of
+// fields with Check and evaluated with Eval.
+package filterexpr
+
+// Expr is a node of a parsed filter expression: *Logical, *Not, *Compare
+// or *In.
+type Expr interface {
+	// Position returns the 1-based byte offset of the node in the source.
+	Position() int
+}
+
+// Logical combines two expressions with AND or OR.
+type Logical struct {
+	Pos         int
+	Op          string // "AND" or "OR"
+	Left, Right Expr
+}
+
+// Not negates an expression.
+type Not struct {
+	Pos int
+	X   Expr
+}
+
+// Compare compares a field with a value.
+type Compare struct {
+	Pos   int
+	Field string
+	Op    string // one of "=", "!=", "<", "<=", ">", ">="
+	Value Value
+}
+
+// In checks whether a field equals any of a list of values.
+type In struct {
+	Pos    int
+	Field  string
+	Values []Value
+	Not    bool
+}
+
+func (e *Logical) Position() int { return e.Pos }
+func (e *Not) Position() int     { return e.Pos }
+func (e *Compare) Position() int { return e.Pos }
+func (e *In) Position() int      { return e.Pos }
+
+// Kind is the type of a Value or Field.
+type Kind int
+
+const (
+	KindNull Kind = iota
+	KindString
+	KindNumber
+)
+
+// String returns the name of the kind as used in error messages.
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindNumber:
+		return "number"
+	default:
+		return "null"
+	}
+}
+
+// Value is a literal of an expression, or the value of a field being
+// evaluated.
+type Value struct {
+	Pos  int
+	Kind Kind
+	Str  string
+	Num  int64
+}
+
+// Null returns a null Value.
+func Null() Value { return Value{Kind: KindNull} }
+
+// String returns a string Value.
+func String(s string) Value { return Value{Kind: KindString, Str: s} }
+
+// Number returns a number Value.
+func Number(n int64) Value { return Value{Kind: KindNumber, Num: n} }
+
+// Field describes a field expressions may refer to.
+type Field struct {
+	Kind     Kind // KindString or KindNumber
+	Nullable bool // whether the field may be compared with NULL
+}
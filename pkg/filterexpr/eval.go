@@ -0,0 +1,163 @@
+package filterexpr
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// Check verifies that expr only refers to the given fields and compares
+// each of them with values of a matching kind. NULL may only be compared
+// with "=" or "!=" on nullable fields, and strings and numbers are never
+// interchangeable.
+func Check(expr Expr, fields map[string]Field) error {
+	switch e := expr.(type) {
+	case *Logical:
+		if err := Check(e.Left, fields); err != nil {
+			return err
+		}
+		return Check(e.Right, fields)
+
+	case *Not:
+		return Check(e.X, fields)
+
+	case *Compare:
+		field, err := lookupField(fields, e.Field, e.Pos)
+		if err != nil {
+			return err
+		}
+		if e.Value.Kind == KindNull {
+			if !field.Nullable {
+				return &Error{Pos: e.Value.Pos, Msg: fmt.Sprintf("field %q cannot be null", e.Field)}
+			}
+			if e.Op != "=" && e.Op != "!=" {
+				return &Error{Pos: e.Value.Pos, Msg: fmt.Sprintf("null can only be compared with = or !=, not %s", e.Op)}
+			}
+			return nil
+		}
+		return checkKind(e.Field, field, e.Value)
+
+	case *In:
+		field, err := lookupField(fields, e.Field, e.Pos)
+		if err != nil {
+			return err
+		}
+		for _, v := range e.Values {
+			if v.Kind == KindNull {
+				return &Error{Pos: v.Pos, Msg: "null is not allowed in IN lists"}
+			}
+			if err := checkKind(e.Field, field, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return &Error{Pos: expr.Position(), Msg: fmt.Sprintf("unsupported expression %T", expr)}
+}
+
+// lookupField returns the declaration of name.
+func lookupField(fields map[string]Field, name string, pos int) (Field, error) {
+	field, ok := fields[name]
+	if !ok {
+		return Field{}, &Error{Pos: pos, Msg: fmt.Sprintf("unknown field %q", name)}
+	}
+	return field, nil
+}
+
+// checkKind verifies that v can be compared with field.
+func checkKind(name string, field Field, v Value) error {
+	if v.Kind != field.Kind {
+		return &Error{Pos: v.Pos, Msg: fmt.Sprintf("field %q expects a %s, found a %s", name, field.Kind, v.Kind)}
+	}
+	return nil
+}
+
+// Eval reports whether the record whose field values are returned by
+// lookup satisfies expr. The expression must have passed Check.
+//
+// Strings are compared ignoring ASCII case. A null field equals only NULL,
+// so "!=" matches it for any other value, while ordering comparisons and
+// IN never match it.
+func Eval(expr Expr, lookup func(field string) Value) bool {
+	switch e := expr.(type) {
+	case *Logical:
+		if e.Op == "AND" {
+			return Eval(e.Left, lookup) && Eval(e.Right, lookup)
+		}
+		return Eval(e.Left, lookup) || Eval(e.Right, lookup)
+
+	case *Not:
+		return !Eval(e.X, lookup)
+
+	case *Compare:
+		v := lookup(e.Field)
+		switch e.Op {
+		case "=":
+			return equal(v, e.Value)
+		case "!=":
+			return !equal(v, e.Value)
+		}
+
+		if v.Kind == KindNull || e.Value.Kind == KindNull {
+			return false
+		}
+		c := compare(v, e.Value)
+		switch e.Op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		case ">=":
+			return c >= 0
+		}
+
+	case *In:
+		v := lookup(e.Field)
+		found := false
+		for _, candidate := range e.Values {
+			if v.Kind != KindNull && equal(v, candidate) {
+				found = true
+				break
+			}
+		}
+		return found != e.Not
+	}
+	return false
+}
+
+// equal reports whether a and b are equal, treating NULL as a value.
+func equal(a, b Value) bool {
+	if a.Kind != b.Kind {
+		return false
+	}
+	return a.Kind == KindNull || compare(a, b) == 0
+}
+
+// compare orders two non-null values of the same kind.
+func compare(a, b Value) int {
+	if a.Kind == KindNumber {
+		return cmp.Compare(a.Num, b.Num)
+	}
+	return CompareFold(a.Str, b.Str)
+}
+
+// CompareFold compares two strings ignoring ASCII case, like SQLite's
+// NOCASE collation.
+func CompareFold(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := cmp.Compare(lowerASCII(a[i]), lowerASCII(b[i])); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// lowerASCII lowercases an ASCII letter and leaves any other byte as-is.
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
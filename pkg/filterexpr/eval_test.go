@@ -0,0 +1,101 @@
+package filterexpr
+
+import (
+	"errors"
+	"testing"
+)
+
+var testFields = map[string]Field{
+	"make":    {Kind: KindString},
+	"package": {Kind: KindString, Nullable: true},
+	"year":    {Kind: KindNumber},
+	"price":   {Kind: KindNumber, Nullable: true},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		pos  int // 0 means valid
+	}{
+		{name: "valid expression", src: "make = Ford AND (price < 100 OR price = null) AND year NOT IN (1, 2)"},
+		{name: "unknown field", src: "make = Ford OR colour = Red", pos: 16},
+		{name: "string compared with number", src: "year = '2020'", pos: 8},
+		{name: "number compared with string", src: "make > 5", pos: 8},
+		{name: "null on required field", src: "make != null", pos: 9},
+		{name: "null with ordering operator", src: "price < null", pos: 9},
+		{name: "null in IN list", src: "package IN (SE, null)", pos: 17},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			err = Check(expr, testFields)
+
+			if tt.pos == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var exprErr *Error
+			if !errors.As(err, &exprErr) || exprErr.Pos != tt.pos {
+				t.Fatalf("expected error at position %d, got %v", tt.pos, err)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	record := map[string]Value{
+		"make":    String("Toyota"),
+		"package": Null(),
+		"year":    Number(2019),
+		"price":   Number(2899000),
+	}
+	lookup := func(field string) Value { return record[field] }
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: "make = toyota", want: true},
+		{src: "make != TOYOTA", want: false},
+		{src: "make < 'u' AND make > 'T'", want: true},
+		{src: "year >= 2019 AND year <= 2019", want: true},
+		{src: "year > 2019", want: false},
+		{src: "(make = Ford AND year = 2019) OR price < 3000000", want: true},
+		{src: "NOT (make = Ford OR year = 2019)", want: false},
+		{src: "make IN (Ford, toyota)", want: true},
+		{src: "make NOT IN (Ford, Kia)", want: true},
+		{src: "package = null", want: true},
+		{src: "package != null", want: false},
+		{src: "package = SE", want: false},
+		{src: "package != SE", want: true},
+		{src: "NOT package = SE", want: true},
+		{src: "package IN (SE)", want: false},
+		{src: "package NOT IN (SE)", want: true},
+		{src: "price != null AND price > 1", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if err := Check(expr, testFields); err != nil {
+				t.Fatalf("check: %v", err)
+			}
+
+			if got := Eval(expr, lookup); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package filterexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxDepth limits how deeply expressions may nest.
+	MaxDepth = 32
+
+	// MaxComparisons limits the number of comparisons in an expression.
+	MaxComparisons = 64
+)
+
+// Error reports a problem with an expression at a given position.
+type Error struct {
+	Pos int // 1-based byte offset in the source
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos, e.Msg)
+}
+
+// Parse parses src into an expression.
+func Parse(src string) (Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+	return expr, nil
+}
+
+// tokenKind classifies lexical tokens.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+// token is a lexical token of an expression.
+type token struct {
+	kind tokenKind
+	text string // word, operator or unquoted string
+	pos  int
+}
+
+// String describes the token for error messages.
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// keyword reports whether the token is the given keyword.
+func (t token) keyword(kw string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, kw)
+}
+
+// isKeyword reports whether word is reserved by the grammar.
+func isKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "NOT", "IN", "NULL":
+		return true
+	}
+	return false
+}
+
+// lex splits src into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRuneInString(src[i:])
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+
+		case r == '(' || r == ')' || r == ',':
+			kind := map[rune]tokenKind{'(': tokenLParen, ')': tokenRParen, ',': tokenComma}[r]
+			tokens = append(tokens, token{kind: kind, text: string(r), pos: pos})
+			i++
+
+		case strings.ContainsRune("=!<>", r):
+			op := src[i : i+1]
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "!=", "<=", ">=", "<>":
+					op = two
+				}
+			}
+			if op == "!" {
+				return nil, &Error{Pos: pos, Msg: `unexpected "!"`}
+			}
+			i += len(op)
+
+			if op == "<>" {
+				op = "!="
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: pos})
+
+		case r == '\'' || r == '"':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return nil, &Error{Pos: pos, Msg: "unterminated string"}
+				}
+				if src[j] == byte(r) {
+					if j+1 < len(src) && src[j+1] == byte(r) {
+						b.WriteByte(byte(r))
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(src[j])
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: pos})
+			i = j + 1
+
+		case r == '-' || unicode.IsDigit(r) || unicode.IsLetter(r) || r == '_':
+			j := i + size
+			for j < len(src) {
+				r, size := utf8.DecodeRuneInString(src[j:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+					break
+				}
+				j += size
+			}
+
+			text := src[i:j]
+			kind := tokenWord
+			if _, err := strconv.ParseInt(text, 10, 64); err == nil {
+				kind = tokenNumber
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: pos})
+			i = j
+
+		default:
+			return nil, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(src) + 1}), nil
+}
+
+// parser is a recursive descent parser over a token list.
+type parser struct {
+	tokens      []token
+	next        int
+	comparisons int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	tok := p.tokens[p.next]
+	if tok.kind != tokenEOF {
+		p.next++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return &Error{Pos: tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// parseOr parses: term { "OR" term }.
+func (p *parser) parseOr(depth int) (Expr, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().keyword("OR") {
+		op := p.advance()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Pos: op.pos, Op: "OR", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses: factor { "AND" factor }.
+func (p *parser) parseAnd(depth int) (Expr, error) {
+	left, err := p.parseFactor(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().keyword("AND") {
+		op := p.advance()
+		right, err := p.parseFactor(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &Logical{Pos: op.pos, Op: "AND", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseFactor parses: "NOT" factor | "(" expr ")" | comparison.
+func (p *parser) parseFactor(depth int) (Expr, error) {
+	tok := p.peek()
+	if depth >= MaxDepth {
+		return nil, p.errorf(tok, "expression nested more than %d levels deep", MaxDepth)
+	}
+
+	switch {
+	case tok.keyword("NOT"):
+		p.advance()
+		x, err := p.parseFactor(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Pos: tok.pos, X: x}, nil
+
+	case tok.kind == tokenLParen:
+		p.advance()
+		x, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.advance(); closing.kind != tokenRParen {
+			return nil, p.errorf(closing, "expected \")\", found %s", closing)
+		}
+		return x, nil
+
+	default:
+		return p.parseComparison()
+	}
+}
+
+// parseComparison parses: field op value | field [ "NOT" ] "IN" list.
+func (p *parser) parseComparison() (Expr, error) {
+	field := p.advance()
+	if field.kind != tokenWord || isKeyword(field.text) {
+		return nil, p.errorf(field, "expected field name, found %s", field)
+	}
+
+	if p.comparisons++; p.comparisons > MaxComparisons {
+		return nil, p.errorf(field, "expression has more than %d comparisons", MaxComparisons)
+	}
+	name := strings.ToLower(field.text)
+
+	tok := p.advance()
+	switch {
+	case tok.kind == tokenOp:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return &Compare{Pos: field.pos, Field: name, Op: tok.text, Value: value}, nil
+
+	case tok.keyword("IN"):
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &In{Pos: field.pos, Field: name, Values: values}, nil
+
+	case tok.keyword("NOT") && p.peek().keyword("IN"):
+		p.advance()
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &In{Pos: field.pos, Field: name, Values: values, Not: true}, nil
+
+	default:
+		return nil, p.errorf(tok, "expected comparison operator or IN after %s, found %s", field, tok)
+	}
+}
+
+// parseList parses: "(" value { "," value } ")".
+func (p *parser) parseList() ([]Value, error) {
+	if tok := p.advance(); tok.kind != tokenLParen {
+		return nil, p.errorf(tok, "expected \"(\", found %s", tok)
+	}
+
+	var values []Value
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		tok := p.advance()
+		if tok.kind == tokenRParen {
+			return values, nil
+		}
+		if tok.kind != tokenComma {
+			return nil, p.errorf(tok, "expected \",\" or \")\", found %s", tok)
+		}
+	}
+}
+
+// parseValue parses: integer | string | word | "NULL".
+func (p *parser) parseValue() (Value, error) {
+	tok := p.advance()
+
+	switch {
+	case tok.kind == tokenNumber:
+		n, _ := strconv.ParseInt(tok.text, 10, 64)
+		return Value{Pos: tok.pos, Kind: KindNumber, Num: n}, nil
+	case tok.kind == tokenString:
+		return Value{Pos: tok.pos, Kind: KindString, Str: tok.text}, nil
+	case tok.keyword("NULL"):
+		return Value{Pos: tok.pos, Kind: KindNull}, nil
+	case tok.kind == tokenWord && !isKeyword(tok.text):
+		return Value{Pos: tok.pos, Kind: KindString, Str: tok.text}, nil
+	default:
+		return Value{}, p.errorf(tok, "expected value, found %s", tok)
+	}
+}
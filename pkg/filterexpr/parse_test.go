@@ -0,0 +1,122 @@
+package filterexpr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should honour precedence and parentheses", func(t *testing.T) {
+		got, err := Parse(`(make = Toyota AND price < 2500000) OR NOT category IN ('Truck', "SUV")`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := &Logical{
+			Pos: 37,
+			Op:  "OR",
+			Left: &Logical{
+				Pos:   16,
+				Op:    "AND",
+				Left:  &Compare{Pos: 2, Field: "make", Op: "=", Value: Value{Pos: 9, Kind: KindString, Str: "Toyota"}},
+				Right: &Compare{Pos: 20, Field: "price", Op: "<", Value: Value{Pos: 28, Kind: KindNumber, Num: 2500000}},
+			},
+			Right: &Not{
+				Pos: 40,
+				X: &In{Pos: 44, Field: "category", Values: []Value{
+					{Pos: 57, Kind: KindString, Str: "Truck"},
+					{Pos: 66, Kind: KindString, Str: "SUV"},
+				}},
+			},
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %#v, got %#v", expected, got)
+		}
+	})
+
+	t.Run("should bind AND tighter than OR", func(t *testing.T) {
+		got, err := Parse(`year = 1 or year = 2 and year = 3`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		or, ok := got.(*Logical)
+		if !ok || or.Op != "OR" {
+			t.Fatalf("expected OR at the root, got %#v", got)
+		}
+
+		if and, ok := or.Right.(*Logical); !ok || and.Op != "AND" {
+			t.Fatalf("expected AND on the right, got %#v", or.Right)
+		}
+	})
+
+	t.Run("should parse operators, quotes, null and NOT IN", func(t *testing.T) {
+		got, err := Parse(`package <> null and model >= 'O''Hara' and mileage not in (-1, 2)`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		outer := got.(*Logical)
+		inner := outer.Left.(*Logical)
+
+		if c := inner.Left.(*Compare); c.Op != "!=" || c.Value.Kind != KindNull {
+			t.Errorf("expected package != null, got %#v", c)
+		}
+		if c := inner.Right.(*Compare); c.Op != ">=" || c.Value.Str != "O'Hara" {
+			t.Errorf("expected model >= O'Hara, got %#v", c)
+		}
+		if in := outer.Right.(*In); !in.Not || in.Values[0].Num != -1 || in.Values[1].Num != 2 {
+			t.Errorf("expected mileage NOT IN (-1, 2), got %#v", in)
+		}
+	})
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		pos  int
+	}{
+		{name: "empty expression", src: "", pos: 1},
+		{name: "missing value", src: "make =", pos: 7},
+		{name: "missing operator", src: "make Toyota", pos: 6},
+		{name: "unbalanced parenthesis", src: "(year = 2020", pos: 13},
+		{name: "trailing token", src: "year = 2020)", pos: 12},
+		{name: "unterminated string", src: "make = 'Toy", pos: 8},
+		{name: "unexpected character", src: "price < 10.5", pos: 11},
+		{name: "bare exclamation mark", src: "year ! 2020", pos: 6},
+		{name: "keyword as field", src: "and = 1", pos: 1},
+		{name: "keyword as value", src: "make = or", pos: 8},
+		{name: "empty IN list", src: "year IN ()", pos: 10},
+		{name: "missing comma in IN list", src: "year IN (1 2)", pos: 12},
+		{name: "dangling AND", src: "year = 1 AND", pos: 13},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+
+			var exprErr *Error
+			if !errors.As(err, &exprErr) {
+				t.Fatalf("expected *Error, got %v", err)
+			}
+
+			if exprErr.Pos != tt.pos {
+				t.Fatalf("expected error at position %d, got %v", tt.pos, err)
+			}
+		})
+	}
+
+	t.Run("should limit nesting depth", func(t *testing.T) {
+		src := "year = 1"
+		for range MaxDepth {
+			src = "(" + src + ")"
+		}
+
+		if _, err := Parse(src); err == nil {
+			t.Fatal("expected error for deeply nested expression")
+		}
+	})
+}
@@ -46,3 +46,32 @@ func Decode[T any](r *http.Request) (*T, error) {
 
 	return &req, nil
 }
+
+// DecodeList decodes a request body containing a JSON array into a slice
+// of the provided struct type.
+//
+// Unknown fields in array elements are rejected, and the array must
+// contain at least one element.
+func DecodeList[T any](r *http.Request) ([]T, error) {
+	if r.Body == nil {
+		return nil, e.NewInvalidRequestBodyError(e.ErrEmptyBody)
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	var items []T
+	if err := dec.Decode(&items); err != nil {
+		return nil, e.NewInvalidRequestBodyError(err)
+	}
+
+	if len(items) == 0 {
+		return nil, e.NewInvalidRequestBodyError(e.ErrEmptyBody)
+	}
+
+	if err := dec.Decode(new(any)); err != io.EOF {
+		return nil, e.NewInvalidRequestBodyError(e.ErrUnexpectedJSONData)
+	}
+
+	return items, nil
+}
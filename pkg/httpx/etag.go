@@ -0,0 +1,86 @@
+package httpx
+
+import (
+	e "cars/pkg/errors"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag formats a resource revision as a strong entity tag, e.g. "3".
+func ETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// IfMatchRevision returns the revision required by the request's If-Match
+// header, for use with optimistic concurrency.
+//
+// It returns 0 when the header is absent or "*", meaning any current
+// revision is acceptable. Weak or non-numeric entity tags can never match
+// a revision and yield a precondition failed error. Lists of more than one
+// entity tag are rejected as a validation error.
+func IfMatchRevision(r *http.Request) (int64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tags := splitETags(header)
+	if len(tags) != 1 {
+		return 0, e.NewValidationError(errors.New("multiple entity tags in If-Match are not supported"))
+	}
+
+	tag := tags[0]
+	if strings.HasPrefix(tag, "W/") || len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, e.NewPreconditionFailedError(e.ErrRevisionMismatch)
+	}
+
+	revision, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, e.NewPreconditionFailedError(e.ErrRevisionMismatch)
+	}
+	return revision, nil
+}
+
+// IfNoneMatch reports whether the request's If-None-Match header matches
+// etag, meaning the client already holds the current representation.
+//
+// Entity tags are compared using the weak comparison function, and "*"
+// matches any representation.
+func IfNoneMatch(r *http.Request, etag string) bool {
+	header := strings.TrimSpace(r.Header.Get("If-None-Match"))
+	if header == "" {
+		return false
+	}
+
+	if header == "*" {
+		return true
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range splitETags(header) {
+		if strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// IfNoneMatchAny reports whether the request's If-None-Match header is
+// "*", meaning the request must only succeed if the resource does not
+// exist yet.
+func IfNoneMatchAny(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("If-None-Match")) == "*"
+}
+
+// splitETags splits a comma-separated list of entity tags.
+func splitETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
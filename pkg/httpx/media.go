@@ -0,0 +1,133 @@
+package httpx
+
+import (
+	e "cars/pkg/errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MediaTypeJSON is the media type for plain JSON documents.
+	MediaTypeJSON = "application/json"
+
+	// MediaTypeMergePatch is the media type for JSON Merge Patch (RFC 7396) documents.
+	MediaTypeMergePatch = "application/merge-patch+json"
+
+	// MediaTypeJSONPatch is the media type for JSON Patch (RFC 6902) documents.
+	MediaTypeJSONPatch = "application/json-patch+json"
+
+	// MediaTypeCSV is the media type for comma-separated values (RFC 4180).
+	MediaTypeCSV = "text/csv"
+
+	// MediaTypeNDJSON is the media type for newline-delimited JSON, one
+	// document per line.
+	MediaTypeNDJSON = "application/x-ndjson"
+
+	// MediaTypeFormData is the media type for multipart form uploads.
+	MediaTypeFormData = "multipart/form-data"
+)
+
+// ContentType returns the media type of the request body, lowercased and
+// without parameters such as charset.
+//
+// It returns an empty string when the Content-Type header is missing or
+// cannot be parsed.
+func ContentType(r *http.Request) string {
+	header := r.Header.Get("Content-Type")
+	if header == "" {
+		return ""
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(mediaType)
+}
+
+// Negotiate chooses the media type of the response among offers, the types
+// the handler can produce in order of preference, according to the
+// request's Accept header.
+//
+// Each offer is given the quality of the most specific media range that
+// matches it ("text/csv" over "text/*" over "*/*"). The offer with the
+// highest quality wins; ties go to the offer matched by the more specific
+// range, then to the earlier offer. Without an Accept header, the first
+// offer is chosen. When no offer is acceptable, a not acceptable error is
+// returned.
+func Negotiate(r *http.Request, offers ...string) (string, error) {
+	header := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		return offers[0], nil
+	}
+
+	ranges := parseAccept(header)
+
+	best, bestQuality, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		quality, specificity := 0.0, -1
+		for _, rng := range ranges {
+			if s := rng.match(offer); s > specificity {
+				quality, specificity = rng.quality, s
+			}
+		}
+
+		if quality > bestQuality || quality == bestQuality && quality > 0 && specificity > bestSpecificity {
+			best, bestQuality, bestSpecificity = offer, quality, specificity
+		}
+	}
+
+	if best == "" {
+		return "", e.NewNotAcceptableError(
+			fmt.Errorf("%w: %q (supported: %s)", e.ErrNotAcceptable, header, strings.Join(offers, ", ")),
+		)
+	}
+	return best, nil
+}
+
+// mediaRange is an entry of an Accept header, such as "text/*;q=0.5".
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+// parseAccept parses the media ranges of an Accept header. Malformed
+// entries are ignored.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || !strings.Contains(mediaType, "/") {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// match reports how specifically the range matches mediaType: 2 for an
+// exact match, 1 for "type/*", 0 for "*/*" and -1 for no match.
+func (m mediaRange) match(mediaType string) int {
+	switch {
+	case m.mediaType == mediaType:
+		return 2
+	case m.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(m.mediaType, "/*") &&
+		strings.HasPrefix(mediaType, strings.TrimSuffix(m.mediaType, "*")):
+		return 1
+	}
+	return -1
+}
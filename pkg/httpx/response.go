@@ -32,16 +32,22 @@ func JSON(w http.ResponseWriter, status int, payload any) error {
 	return json.NewEncoder(w).Encode(payload)
 }
 
-// writeError writes a ServiceError as a standardized JSON error response.
-func writeError(w http.ResponseWriter, err *e.ServiceError) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(err.StatusCode)
+// NewErrorResponse converts an error into the HTTP status code and
+// ErrorResponse describing it.
+//
+// If err is a ServiceError, its metadata is used as-is.
+// Otherwise, it is reported as an internal server error.
+func NewErrorResponse(err error) (int, ErrorResponse) {
+	var serviceError *e.ServiceError
+	if !errors.As(err, &serviceError) {
+		serviceError = e.NewInternalError(err)
+	}
 
-	_ = json.NewEncoder(w).Encode(ErrorResponse{
-		Code:    err.Code,
-		Message: err.Message,
-		Details: err.Details(),
-	})
+	return serviceError.StatusCode, ErrorResponse{
+		Code:    serviceError.Code,
+		Message: serviceError.Message,
+		Details: serviceError.Details(),
+	}
 }
 
 // HandleServiceError converts an error into a standardized HTTP JSON response.
@@ -53,11 +59,10 @@ func HandleServiceError(w http.ResponseWriter, err error) {
 		return
 	}
 
-	var serviceError *e.ServiceError
-	if errors.As(err, &serviceError) {
-		writeError(w, serviceError)
-		return
-	}
+	status, resp := NewErrorResponse(err)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 
-	writeError(w, e.NewInternalError(err))
+	_ = json.NewEncoder(w).Encode(resp)
 }
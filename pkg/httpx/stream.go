@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONArrayEncoder writes a JSON array one element at a time, so that a
+// list can be sent as it is produced rather than built in memory first.
+//
+// The output matches encoding the whole slice with json.Encoder: elements
+// are separated by commas and the array is followed by a newline.
+type JSONArrayEncoder struct {
+	w     io.Writer
+	count int
+	err   error
+}
+
+// NewJSONArrayEncoder returns an encoder writing an array to w.
+func NewJSONArrayEncoder(w io.Writer) *JSONArrayEncoder {
+	return &JSONArrayEncoder{w: w}
+}
+
+// Encode writes v as the next element of the array, preceded by the opening
+// bracket for the first element.
+//
+// A value that cannot be marshaled is skipped and its error returned. Once
+// writing to the underlying writer fails, every call returns that error.
+func (enc *JSONArrayEncoder) Encode(v any) error {
+	if enc.err != nil {
+		return enc.err
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if enc.count == 0 {
+		sep = "["
+	}
+
+	if err := enc.write(append([]byte(sep), data...)); err != nil {
+		return err
+	}
+	enc.count++
+	return nil
+}
+
+// Close ends the array, writing an empty one if no element was encoded.
+// It does not close the underlying writer.
+func (enc *JSONArrayEncoder) Close() error {
+	if enc.err != nil {
+		return enc.err
+	}
+
+	if enc.count == 0 {
+		return enc.write([]byte("[]\n"))
+	}
+	return enc.write([]byte("]\n"))
+}
+
+func (enc *JSONArrayEncoder) write(data []byte) error {
+	if _, err := enc.w.Write(data); err != nil {
+		enc.err = err
+	}
+	return enc.err
+}
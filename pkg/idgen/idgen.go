@@ -0,0 +1,52 @@
+// Package idgen generates identifiers for stored resources.
+//
+// Three strategies are provided: Hex, which produces random IDs with no
+// order, and UUIDv7 and ULID, whose IDs start with their creation time, so
+// that IDs generated later sort after earlier ones. Each generator is safe
+// for concurrent use.
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/google/uuid"
+)
+
+// Hex generates random 16-character hexadecimal IDs, such as
+// "9f86d081884c7d65".
+type Hex struct{}
+
+// NewHex creates a Hex generator.
+func NewHex() Hex {
+	return Hex{}
+}
+
+// NewID returns a new random ID.
+func (Hex) NewID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UUIDv7 generates version 7 UUIDs (RFC 9562), such as
+// "01964a2b-6c3e-7d4f-8a1b-2c3d4e5f6a7b". The first 48 bits hold the Unix
+// time in milliseconds; IDs generated within the same millisecond still
+// sort in generation order.
+type UUIDv7 struct{}
+
+// NewUUIDv7 creates a UUIDv7 generator.
+func NewUUIDv7() UUIDv7 {
+	return UUIDv7{}
+}
+
+// NewID returns a new UUID in its canonical lowercase form.
+func (UUIDv7) NewID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,135 @@
+package idgen
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name    string
+		gen     interface{ NewID() (string, error) }
+		pattern string
+		ordered bool
+	}{
+		{name: "Hex", gen: NewHex(), pattern: `^[0-9a-f]{16}$`},
+		{name: "UUIDv7", gen: NewUUIDv7(), pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, ordered: true},
+		{name: "ULID", gen: NewULID(), pattern: `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, ordered: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			const n = 1000
+			pattern := regexp.MustCompile(tt.pattern)
+			ids := make([]string, n)
+
+			// Act
+			for i := range ids {
+				id, err := tt.gen.NewID()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				ids[i] = id
+			}
+
+			// Assert
+			for _, id := range ids {
+				if !pattern.MatchString(id) {
+					t.Fatalf("expected %q to match %s", id, tt.pattern)
+				}
+			}
+
+			if tt.ordered && !slices.IsSorted(ids) {
+				t.Fatal("expected IDs to sort in generation order")
+			}
+
+			sorted := slices.Clone(ids)
+			slices.Sort(sorted)
+			if len(slices.Compact(sorted)) != n {
+				t.Fatal("expected unique IDs")
+			}
+		})
+	}
+}
+
+func TestULID_NewID(t *testing.T) {
+	t.Run("should encode the time in the first 10 characters", func(t *testing.T) {
+		// Arrange
+		gen := NewULID()
+		gen.now = func() time.Time { return time.UnixMilli(1469918176385) }
+
+		// Act
+		id, err := gen.NewID()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if id[:10] != "01ARYZ6S41" {
+			t.Fatalf("expected time prefix 01ARYZ6S41, got %s", id[:10])
+		}
+	})
+
+	t.Run("should stay ordered when the clock goes back", func(t *testing.T) {
+		// Arrange
+		now := time.UnixMilli(1469918176385)
+		gen := NewULID()
+		gen.now = func() time.Time { return now }
+		first, _ := gen.NewID()
+		now = now.Add(-time.Second)
+
+		// Act
+		second, err := gen.NewID()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if second <= first {
+			t.Fatalf("expected %s to sort after %s", second, first)
+		}
+	})
+
+	t.Run("should fail when the random component overflows", func(t *testing.T) {
+		// Arrange
+		gen := NewULID()
+		gen.now = func() time.Time { return time.UnixMilli(1469918176385) }
+		if _, err := gen.NewID(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for i := range gen.lastRnd {
+			gen.lastRnd[i] = 0xff
+		}
+
+		// Act
+		_, err := gen.NewID()
+
+		// Assert
+		if !errors.Is(err, ErrULIDOverflow) {
+			t.Fatalf("expected ErrULIDOverflow, got %v", err)
+		}
+	})
+}
+
+func TestEncodeULID(t *testing.T) {
+	// Arrange
+	var max [16]byte
+	for i := range max {
+		max[i] = 0xff
+	}
+
+	// Act
+	zero, ones := encodeULID([16]byte{}), encodeULID(max)
+
+	// Assert
+	if zero != "00000000000000000000000000" {
+		t.Fatalf("expected all zeros, got %s", zero)
+	}
+	if ones != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("expected the largest ULID, got %s", ones)
+	}
+}
@@ -0,0 +1,97 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used to encode ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ErrULIDOverflow is returned when more ULIDs are requested within one
+// millisecond than its random component can order.
+var ErrULIDOverflow = errors.New("ulid: random component overflow")
+
+// ULID generates Universally Unique Lexicographically Sortable Identifiers,
+// such as "01ARYZ6S41TSV4RRFFQ69G5FAV": 26 Crockford base32 characters
+// encoding a 48-bit Unix time in milliseconds followed by 80 random bits.
+//
+// IDs are monotonic: within the same millisecond, the random component of
+// the previous ID is incremented instead of drawn again, so IDs always sort
+// in generation order.
+type ULID struct {
+	now     func() time.Time
+	lastMS  uint64
+	lastRnd [10]byte
+	mu      sync.Mutex
+}
+
+// NewULID creates a ULID generator.
+func NewULID() *ULID {
+	return &ULID{now: time.Now}
+}
+
+// NewID returns a new ULID.
+func (g *ULID) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMS && g.lastMS != 0 {
+		// Same millisecond, or the clock went back: keep ordering by
+		// incrementing the previous random component.
+		ms = g.lastMS
+		if !increment(g.lastRnd[:]) {
+			return "", ErrULIDOverflow
+		}
+	} else {
+		if _, err := rand.Read(g.lastRnd[:]); err != nil {
+			return "", err
+		}
+		g.lastMS = ms
+	}
+
+	var id [16]byte
+	for i := range 6 {
+		id[i] = byte(ms >> (40 - 8*i))
+	}
+	copy(id[6:], g.lastRnd[:])
+	return encodeULID(id), nil
+}
+
+// increment adds one to the big-endian number b, reporting false if it
+// overflowed.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes the 128 bits of id as 26 Crockford base32
+// characters, most significant first.
+func encodeULID(id [16]byte) string {
+	var hi, lo uint64
+	for i := range 8 {
+		hi = hi<<8 | uint64(id[i])
+		lo = lo<<8 | uint64(id[8+i])
+	}
+
+	var out [26]byte
+	for j := range out {
+		shift := uint(5 * j)
+		var v uint64
+		if shift < 64 {
+			v = lo>>shift | hi<<(64-shift)
+		} else {
+			v = hi >> (shift - 64)
+		}
+		out[len(out)-1-j] = crockford[v&31]
+	}
+	return string(out[:])
+}
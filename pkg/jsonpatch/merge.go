@@ -0,0 +1,35 @@
+// Package jsonpatch implements patch formats for JSON documents.
+//
+// Documents are represented the way encoding/json decodes into an
+// interface value: map[string]any for objects, []any for arrays,
+// and float64, string, bool or nil for scalars.
+package jsonpatch
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to target and returns
+// the result.
+//
+// When patch is an object, each member is merged recursively into target:
+// a null value removes the member, any other value replaces or merges it.
+// When patch is not an object, it replaces target entirely.
+//
+// Target maps may be modified in place.
+func MergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if !ok {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+
+	for key, value := range patchObj {
+		if value == nil {
+			delete(targetObj, key)
+			continue
+		}
+		targetObj[key] = MergePatch(targetObj[key], value)
+	}
+	return targetObj
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"cars/pkg/contextkeys"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// ActorHeader is the request header naming the actor on whose behalf the
+// request is made, as recorded in the audit trail.
+const ActorHeader = "X-Actor"
+
+// Actor is an HTTP middleware that stores the actor named by the
+// X-Actor header in the request context. Requests without the header
+// carry no actor.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := strings.TrimSpace(r.Header.Get(ActorHeader)); actor != "" {
+			ctx := context.WithValue(r.Context(), contextkeys.ActorKey, actor)
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"cars/pkg/contextkeys"
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Admin returns an HTTP middleware that grants admin access to requests
+// carrying the given token in an "Authorization: Bearer" header, by
+// setting contextkeys.AdminKey in their context. An empty token grants
+// admin access to no request.
+func Admin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" && isBearer(r, token) {
+				ctx := context.WithValue(r.Context(), contextkeys.AdminKey, true)
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isBearer reports whether r is authorized with the given bearer token.
+func isBearer(r *http.Request, token string) bool {
+	scheme, credentials, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(credentials)), []byte(token)) == 1
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"cars/pkg/contextkeys"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	tCases := []struct {
+		name          string
+		token         string
+		authorization string
+		expected      bool
+	}{
+		{name: "should grant access to the configured bearer token", token: "s3cret", authorization: "Bearer s3cret", expected: true},
+		{name: "should accept the scheme in any case", token: "s3cret", authorization: "bearer s3cret", expected: true},
+		{name: "should deny another token", token: "s3cret", authorization: "Bearer guess", expected: false},
+		{name: "should deny requests without a token", token: "s3cret", expected: false},
+		{name: "should deny every request when no token is configured", authorization: "Bearer ", expected: false},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var got bool
+			h := Admin(tc.token)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = r.Context().Value(contextkeys.AdminKey).(bool)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/cars", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			// Act
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			// Assert
+			if got != tc.expected {
+				t.Fatalf("expected admin %t, got %t", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package middleware
+
+import (
+	"bytes"
+	e "cars/pkg/errors"
+	"cars/pkg/httpx"
+	"cars/pkg/logger"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client-chosen
+	// key that makes retries of a request safe.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set to "true" on a response replayed from
+	// an IdempotencyStore.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// MaxIdempotencyKeyLength is the longest accepted Idempotency-Key.
+	MaxIdempotencyKeyLength = 255
+
+	// MaxIdempotentBodySize is the largest request body, in bytes, accepted
+	// with an Idempotency-Key, as the body is read whole to be hashed.
+	MaxIdempotentBodySize = 1 << 20
+
+	// idempotencySweepInterval is the least time between two removals of
+	// expired responses from an IdempotencyStore.
+	idempotencySweepInterval = time.Minute
+)
+
+// storedResponse is a response kept by an IdempotencyStore for replay.
+type storedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotencyRecord is the state of an Idempotency-Key: the hash of the
+// request first sent with it and, once that request completed, its
+// response and expiry time.
+type idempotencyRecord struct {
+	hash     string
+	response *storedResponse
+	expires  time.Time
+}
+
+// IdempotencyStore keeps the responses to requests sent with an
+// Idempotency-Key, so that a retry of a request gets the response of the
+// first attempt instead of being processed again.
+//
+// Responses are kept in memory for the store's TTL, counted from when the
+// first request completed. The zero value is not usable; create stores
+// with NewIdempotencyStore.
+type IdempotencyStore struct {
+	ttl     time.Duration
+	records map[string]*idempotencyRecord
+	swept   time.Time
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewIdempotencyStore creates an empty IdempotencyStore keeping responses
+// for ttl.
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:     ttl,
+		records: make(map[string]*idempotencyRecord),
+		now:     time.Now,
+	}
+}
+
+// reserve claims key for the request with the given hash. If the key
+// already holds the response to a request with the same hash, that
+// response is returned and the key is left as is.
+//
+// A key claimed by a request that has not completed yet, or by a request
+// with a different hash, cannot be claimed.
+func (s *IdempotencyStore) reserve(key, hash string) (*storedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	if rec, ok := s.records[key]; ok && !s.expired(rec, now) {
+		switch {
+		case rec.hash != hash:
+			return nil, e.NewIdempotencyKeyReusedError(e.ErrIdempotencyKeyReused)
+		case rec.response == nil:
+			return nil, e.NewIdempotencyKeyInUseError(e.ErrIdempotencyKeyInUse)
+		default:
+			return rec.response, nil
+		}
+	}
+
+	s.records[key] = &idempotencyRecord{hash: hash}
+	return nil, nil
+}
+
+// save stores the response to the request that claimed key.
+func (s *IdempotencyStore) save(key string, resp storedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok {
+		rec.response = &resp
+		rec.expires = s.now().Add(s.ttl)
+	}
+}
+
+// release frees key, so that the request that claimed it can be retried
+// as a new one.
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+}
+
+// expired reports whether the response held by rec is no longer kept.
+// Keys claimed by requests in progress never expire.
+func (s *IdempotencyStore) expired(rec *idempotencyRecord, now time.Time) bool {
+	return rec.response != nil && !now.Before(rec.expires)
+}
+
+// sweep removes the expired records, at most once per
+// idempotencySweepInterval. The caller must hold s.mu.
+func (s *IdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < idempotencySweepInterval {
+		return
+	}
+	s.swept = now
+
+	for key, rec := range s.records {
+		if s.expired(rec, now) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// recordingWriter wraps http.ResponseWriter to keep a copy of the
+// response status, headers and body while writing them through.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code and a snapshot of the headers
+// before delegating to the underlying ResponseWriter.
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+		rw.header = rw.ResponseWriter.Header().Clone()
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the body, ensuring a default 200 OK status is recorded
+// if WriteHeader was not called explicitly.
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// Idempotency returns an HTTP middleware that makes requests carrying an
+// Idempotency-Key header safe to retry.
+//
+// The first request sent with a key is processed as usual and its
+// response (status, headers and body) is kept in store. A retry with the
+// same key and the same request, identified by a hash of its method, path
+// and body, gets that response replayed with an Idempotent-Replayed: true
+// header, without reaching the handler. Reusing a key for a different
+// request is rejected with 422, and retrying while the first request is
+// still in progress with 409.
+//
+// Server errors (5xx) are not kept, so a request that failed that way can
+// be retried as a new one. Requests without the header are passed through
+// untouched.
+func Idempotency(store *IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := logger.FromContext(r.Context())
+
+			if len(key) > MaxIdempotencyKeyLength {
+				httpx.HandleServiceError(w, e.NewValidationError(
+					fmt.Errorf("%s must be at most %d characters", IdempotencyKeyHeader, MaxIdempotencyKeyLength),
+				))
+				return
+			}
+
+			body, err := readBody(w, r)
+			if err != nil {
+				httpx.HandleServiceError(w, e.NewInvalidRequestBodyError(err))
+				return
+			}
+
+			stored, err := store.reserve(key, requestHash(r, body))
+			if err != nil {
+				log.Printf("idempotency key %q rejected: %v", key, err)
+				httpx.HandleServiceError(w, err)
+				return
+			}
+
+			if stored != nil {
+				replay(w, stored)
+				log.Printf("response replayed for idempotency key %q", key)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w}
+			saved := false
+			defer func() {
+				if !saved {
+					store.release(key)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+
+			if rw.status == 0 || rw.status >= http.StatusInternalServerError {
+				return
+			}
+			store.save(key, storedResponse{status: rw.status, header: rw.header, body: rw.body.Bytes()})
+			saved = true
+		})
+	}
+}
+
+// readBody reads the whole request body, up to MaxIdempotentBodySize
+// bytes, and replaces it with a copy so the handler can still read it.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxIdempotentBodySize))
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// requestHash identifies a request by its method, path and body.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay writes a stored response to w.
+func replay(w http.ResponseWriter, resp *storedResponse) {
+	for k, v := range resp.header {
+		w.Header()[k] = slices.Clone(v)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.status)
+	_, _ = w.Write(resp.body)
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingHandler creates a car-like resource on every call, answering
+// with 201, a Location header and a body naming the call.
+func countingHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Location", "/cars/"+strings.Repeat("A", *calls))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	})
+}
+
+func post(h http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/cars", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestIdempotency(t *testing.T) {
+	t.Run("should replay the first response to a retry", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		h := Idempotency(NewIdempotencyStore(time.Hour))(countingHandler(&calls))
+		first := post(h, "k1", `{"make":"Ford"}`)
+
+		// Act
+		retry := post(h, "k1", `{"make":"Ford"}`)
+
+		// Assert
+		if calls != 1 {
+			t.Fatalf("expected the handler to be called once, got %d", calls)
+		}
+		if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+			t.Fatalf("expected %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+		}
+		if got := retry.Header().Get("Location"); got != "/cars/A" {
+			t.Fatalf("expected Location /cars/A, got %q", got)
+		}
+		if got := retry.Header().Get(IdempotentReplayedHeader); got != "true" {
+			t.Fatalf("expected %s: true, got %q", IdempotentReplayedHeader, got)
+		}
+		if got := first.Header().Get(IdempotentReplayedHeader); got != "" {
+			t.Fatalf("expected the first response not to be marked replayed, got %q", got)
+		}
+	})
+
+	t.Run("should reject a key reused with a different body", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		h := Idempotency(NewIdempotencyStore(time.Hour))(countingHandler(&calls))
+		post(h, "k1", `{"make":"Ford"}`)
+
+		// Act
+		resp := post(h, "k1", `{"make":"Audi"}`)
+
+		// Assert
+		if resp.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status 422, got %d", resp.Code)
+		}
+		if !strings.Contains(resp.Body.String(), `"code":"IDEMPOTENCY_KEY_REUSED"`) {
+			t.Fatalf("expected IDEMPOTENCY_KEY_REUSED, got %s", resp.Body)
+		}
+		if calls != 1 {
+			t.Fatalf("expected the handler to be called once, got %d", calls)
+		}
+	})
+
+	t.Run("should process requests with different keys separately", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		h := Idempotency(NewIdempotencyStore(time.Hour))(countingHandler(&calls))
+		post(h, "k1", `{"make":"Ford"}`)
+
+		// Act
+		resp := post(h, "k2", `{"make":"Ford"}`)
+
+		// Assert
+		if calls != 2 || resp.Header().Get("Location") != "/cars/AA" {
+			t.Fatalf("expected a second car, got %d calls and Location %q", calls, resp.Header().Get("Location"))
+		}
+	})
+
+	t.Run("should pass requests without a key through", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		h := Idempotency(NewIdempotencyStore(time.Hour))(countingHandler(&calls))
+		post(h, "", `{"make":"Ford"}`)
+
+		// Act
+		post(h, "", `{"make":"Ford"}`)
+
+		// Assert
+		if calls != 2 {
+			t.Fatalf("expected the handler to be called twice, got %d", calls)
+		}
+	})
+
+	t.Run("should process a retry again after the TTL", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		now := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+		store := NewIdempotencyStore(time.Hour)
+		store.now = func() time.Time { return now }
+		h := Idempotency(store)(countingHandler(&calls))
+		post(h, "k1", `{"make":"Ford"}`)
+		now = now.Add(time.Hour)
+
+		// Act
+		resp := post(h, "k1", `{"make":"Audi"}`)
+
+		// Assert
+		if resp.Code != http.StatusCreated || calls != 2 {
+			t.Fatalf("expected the request to be processed again, got %d after %d calls", resp.Code, calls)
+		}
+	})
+
+	t.Run("should not keep server errors", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		h := Idempotency(NewIdempotencyStore(time.Hour))(countingHandler(&calls))
+		post(h, "k1", `{"make":"fail"}`)
+
+		// Act
+		resp := post(h, "k1", `{"make":"fail"}`)
+
+		// Assert
+		if resp.Header().Get(IdempotentReplayedHeader) != "" || calls != 2 {
+			t.Fatalf("expected the request to be processed again, got %d calls", calls)
+		}
+	})
+
+	t.Run("should reject a retry while the first request is in progress", func(t *testing.T) {
+		// Arrange
+		store := NewIdempotencyStore(time.Hour)
+		var retry *httptest.ResponseRecorder
+		var h http.Handler
+		h = Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			retry = post(h, "k1", `{"make":"Ford"}`)
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		// Act
+		post(h, "k1", `{"make":"Ford"}`)
+
+		// Assert
+		if retry.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", retry.Code)
+		}
+		if !strings.Contains(retry.Body.String(), `"code":"IDEMPOTENCY_KEY_IN_USE"`) {
+			t.Fatalf("expected IDEMPOTENCY_KEY_IN_USE, got %s", retry.Body)
+		}
+	})
+
+	t.Run("should reject a key that is too long", func(t *testing.T) {
+		// Arrange
+		calls := 0
+		h := Idempotency(NewIdempotencyStore(time.Hour))(countingHandler(&calls))
+
+		// Act
+		resp := post(h, strings.Repeat("k", MaxIdempotencyKeyLength+1), `{"make":"Ford"}`)
+
+		// Assert
+		if resp.Code != http.StatusBadRequest || calls != 0 {
+			t.Fatalf("expected status 400 without calling the handler, got %d after %d calls", resp.Code, calls)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+// Package search implements tokenization, relevance scoring and an
+// in-memory inverted index for free-text queries.
+//
+// A query matches a document when every query token matches at least one
+// document term, either exactly or as a prefix. Each query token contributes
+// the weight of the best matching term, halved for prefix matches, and the
+// relevance of a document is the sum of those contributions.
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// PrefixFactor scales the weight of a term matched by prefix only.
+const PrefixFactor = 0.5
+
+// Field is a piece of document text with the weight its terms carry.
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Tokenize splits text into lowercase tokens of letters and digits.
+// Every other character separates tokens.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// QueryTokens tokenizes a query and removes duplicate tokens, keeping the
+// first occurrence of each.
+func QueryTokens(query string) []string {
+	var tokens []string
+	for _, token := range Tokenize(query) {
+		if !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// Terms returns the distinct terms of the given fields, each with the
+// highest weight of the fields it appears in.
+func Terms(fields ...Field) map[string]float64 {
+	terms := make(map[string]float64)
+	for _, field := range fields {
+		for _, term := range Tokenize(field.Text) {
+			terms[term] = max(terms[term], field.Weight)
+		}
+	}
+	return terms
+}
+
+// Score returns the relevance of a document made of fields for query,
+// or zero if the document does not match.
+//
+// It computes the same value as Index.Search, without an index.
+func Score(query string, fields ...Field) float64 {
+	tokens := QueryTokens(query)
+	if len(tokens) == 0 {
+		return 0
+	}
+
+	terms := Terms(fields...)
+
+	var score float64
+	for _, token := range tokens {
+		var best float64
+		for term, weight := range terms {
+			best = max(best, match(token, term, weight))
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+	return score
+}
+
+// match returns the contribution of term to a query token.
+func match(token, term string, weight float64) float64 {
+	switch {
+	case term == token:
+		return weight
+	case strings.HasPrefix(term, token):
+		return weight * PrefixFactor
+	default:
+		return 0
+	}
+}
+
+// Index is an inverted index mapping terms to the documents containing them.
+//
+// The zero value is an empty index ready to use. Index is not safe for
+// concurrent use; callers must synchronize writes with other calls.
+type Index struct {
+	postings map[string]map[string]float64 // term -> document ID -> weight
+	terms    []string                      // sorted keys of postings
+	docs     map[string][]string           // document ID -> its terms
+}
+
+// Add indexes the document identified by id, replacing any previous
+// version of it.
+func (ix *Index) Add(id string, fields ...Field) {
+	ix.Remove(id)
+
+	if ix.postings == nil {
+		ix.postings = make(map[string]map[string]float64)
+		ix.docs = make(map[string][]string)
+	}
+
+	terms := Terms(fields...)
+	docTerms := make([]string, 0, len(terms))
+
+	for term, weight := range terms {
+		posting, ok := ix.postings[term]
+		if !ok {
+			posting = make(map[string]float64)
+			ix.postings[term] = posting
+
+			i, _ := slices.BinarySearch(ix.terms, term)
+			ix.terms = slices.Insert(ix.terms, i, term)
+		}
+		posting[id] = weight
+		docTerms = append(docTerms, term)
+	}
+	ix.docs[id] = docTerms
+}
+
+// Remove drops the document identified by id from the index.
+// It is a no-op if the document is not indexed.
+func (ix *Index) Remove(id string) {
+	for _, term := range ix.docs[id] {
+		posting := ix.postings[term]
+		delete(posting, id)
+
+		if len(posting) == 0 {
+			delete(ix.postings, term)
+			if i, ok := slices.BinarySearch(ix.terms, term); ok {
+				ix.terms = slices.Delete(ix.terms, i, i+1)
+			}
+		}
+	}
+	delete(ix.docs, id)
+}
+
+// Search returns the IDs of the documents matching query with their
+// relevance. Only the terms sharing a prefix with a query token are
+// visited.
+func (ix *Index) Search(query string) map[string]float64 {
+	var scores map[string]float64
+
+	for _, token := range QueryTokens(query) {
+		best := make(map[string]float64)
+
+		start, _ := slices.BinarySearch(ix.terms, token)
+		for _, term := range ix.terms[start:] {
+			if !strings.HasPrefix(term, token) {
+				break
+			}
+			for id, weight := range ix.postings[term] {
+				best[id] = max(best[id], match(token, term, weight))
+			}
+		}
+
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for id, score := range scores {
+			if b, ok := best[id]; ok {
+				scores[id] = score + b
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	if scores == nil {
+		scores = make(map[string]float64)
+	}
+	return scores
+}
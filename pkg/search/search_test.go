@@ -0,0 +1,112 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("  Red TOYOTA rav4-XSE, 2024! ")
+	expected := []string{"red", "toyota", "rav4", "xse", "2024"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestQueryTokens(t *testing.T) {
+	got := QueryTokens("ford Ford bronco FORD")
+	expected := []string{"ford", "bronco"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestIndex_Search(t *testing.T) {
+	docs := map[string][]Field{
+		"rav4":   {{Text: "Toyota", Weight: 3}, {Text: "Rav4", Weight: 3}, {Text: "Red", Weight: 1}, {Text: "XSE", Weight: 2}},
+		"camry":  {{Text: "Toyota", Weight: 3}, {Text: "Camry", Weight: 3}, {Text: "White", Weight: 1}, {Text: "SE", Weight: 2}},
+		"bronco": {{Text: "Ford", Weight: 3}, {Text: "Bronco", Weight: 3}, {Text: "Burnt Orange", Weight: 1}},
+	}
+
+	var ix Index
+	for id, fields := range docs {
+		ix.Add(id, fields...)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected map[string]float64
+	}{
+		{
+			name:     "should require every token to match",
+			query:    "red toyota rav4 xse",
+			expected: map[string]float64{"rav4": 9},
+		},
+		{
+			name:     "should match any document containing a single token",
+			query:    "TOYOTA",
+			expected: map[string]float64{"rav4": 3, "camry": 3},
+		},
+		{
+			name:     "should not match terms that only contain the token",
+			query:    "se",
+			expected: map[string]float64{"camry": 2},
+		},
+		{
+			name:     "should require prefix matches in the same document",
+			query:    "toy bro",
+			expected: map[string]float64{},
+		},
+		{
+			name:     "should match prefixes of several terms",
+			query:    "bur or",
+			expected: map[string]float64{"bronco": 1},
+		},
+		{
+			name:     "should return empty result for unknown term",
+			query:    "bmw",
+			expected: map[string]float64{},
+		},
+		{
+			name:     "should return empty result for query without tokens",
+			query:    " ,; ",
+			expected: map[string]float64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ix.Search(tt.query)
+
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+
+			for id, fields := range docs {
+				if score := Score(tt.query, fields...); score != tt.expected[id] {
+					t.Errorf("expected Score %v for %s, got %v", tt.expected[id], id, score)
+				}
+			}
+		})
+	}
+
+	t.Run("should forget removed and replaced documents", func(t *testing.T) {
+		ix.Remove("camry")
+		ix.Add("rav4", Field{Text: "Toyota Corolla", Weight: 1})
+
+		if got := ix.Search("toyota"); !reflect.DeepEqual(got, map[string]float64{"rav4": 1}) {
+			t.Fatalf("expected only replaced document, got %v", got)
+		}
+
+		if got := ix.Search("rav4"); len(got) != 0 {
+			t.Fatalf("expected no match for replaced terms, got %v", got)
+		}
+
+		if len(ix.terms) != len(ix.postings) {
+			t.Fatalf("expected %d sorted terms, got %d", len(ix.postings), len(ix.terms))
+		}
+	})
+}
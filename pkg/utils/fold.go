@@ -0,0 +1,15 @@
+package utils
+
+import "strings"
+
+// Fold returns s with its ASCII letters lowercased and any other rune
+// left as-is, the way SQLite's NOCASE collation and lower() fold case.
+// Two strings are equal ignoring ASCII case exactly when their folds are.
+func Fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
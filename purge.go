@@ -0,0 +1,52 @@
+package main
+
+import (
+	"cars/pkg/config"
+	e "cars/pkg/errors"
+	"cars/repositories"
+	"cars/services"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+)
+
+// runPurge implements the purge subcommand: it permanently removes the
+// cars of the configured repository that were deleted longer ago than the
+// retention period, and prints how many were removed.
+//
+// The retention defaults to cfg.PurgeRetention and can be overridden with
+// the -retention flag.
+func runPurge(cfg config.Config, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("purge", flag.ContinueOnError)
+	retention := flags.Duration("retention", cfg.PurgeRetention, "how long deleted cars are kept, such as 720h")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("usage: cars purge [-retention DURATION]")
+	}
+
+	if cfg.Storage == config.StorageMemory {
+		log.Printf("warning: %s storage does not persist deleted cars", cfg.Storage)
+	}
+
+	repo, audit, err := repositories.Open(cfg)
+	if err != nil {
+		return err
+	}
+
+	n, err := services.NewCarService(repo, audit).Purge(*retention)
+	if err != nil {
+		var serviceError *e.ServiceError
+		if errors.As(err, &serviceError) {
+			return fmt.Errorf("%s: %s", serviceError.Message, serviceError.Details())
+		}
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%d cars purged\n", n)
+	return nil
+}
@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"cars/models"
+	"slices"
+	"sync"
+)
+
+// AuditStore persists the audit trail of the changes made to cars,
+// independently of where the cars themselves are stored.
+//
+// Entries are immutable: a store only appends them and reads them back.
+// Append stores several entries atomically, and History returns the
+// entries of a car in the order they were appended, or an empty list if
+// there are none. The history of a car outlives the car: purging it does
+// not remove its entries.
+type AuditStore interface {
+	Append(entries ...models.CarAuditEntry) error
+	History(carID string) ([]models.CarAuditEntry, error)
+}
+
+// DefaultAuditStore is an in-memory implementation of AuditStore.
+type DefaultAuditStore struct {
+	entries map[string][]models.CarAuditEntry
+	mu      sync.RWMutex
+}
+
+// NewAuditStore creates a new, empty DefaultAuditStore.
+func NewAuditStore() AuditStore {
+	return &DefaultAuditStore{
+		entries: make(map[string][]models.CarAuditEntry),
+	}
+}
+
+// Append stores the given entries.
+//
+// The snapshots are copied, so that later changes to the cars they point
+// to cannot alter the stored history.
+func (s *DefaultAuditStore) Append(entries ...models.CarAuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.Before != nil {
+			before := *entry.Before
+			entry.Before = &before
+		}
+		s.entries[entry.CarID] = append(s.entries[entry.CarID], entry)
+	}
+	return nil
+}
+
+// History returns the entries of the car with the given ID, oldest first.
+func (s *DefaultAuditStore) History(carID string) ([]models.CarAuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := slices.Clone(s.entries[carID])
+	if history == nil {
+		history = []models.CarAuditEntry{}
+	}
+	return history, nil
+}
@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"cars/models"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SQLiteAuditStore is an AuditStore backed by an SQLite database.
+type SQLiteAuditStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteAuditStore creates a new SQLiteAuditStore using db.
+//
+// Pending schema migrations are applied before the store is returned.
+func NewSQLiteAuditStore(db *sql.DB) (AuditStore, error) {
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	return &SQLiteAuditStore{db: db}, nil
+}
+
+// Append stores the given entries in a single transaction.
+func (s *SQLiteAuditStore) Append(entries ...models.CarAuditEntry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO car_audit
+		(car_id, revision, action, actor, request_id, time, before, after)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		var before sql.NullString
+		if entry.Before != nil {
+			b, err := json.Marshal(toSnapshot(*entry.Before))
+			if err != nil {
+				return err
+			}
+			before = sql.NullString{String: string(b), Valid: true}
+		}
+
+		after, err := json.Marshal(toSnapshot(entry.After))
+		if err != nil {
+			return err
+		}
+
+		if _, err := stmt.Exec(
+			entry.CarID, entry.Revision, entry.Action, entry.Actor, entry.RequestID,
+			entry.Time.UnixNano(), before, string(after),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// History returns the entries of the car with the given ID, oldest first.
+func (s *SQLiteAuditStore) History(carID string) ([]models.CarAuditEntry, error) {
+	rows, err := s.db.Query(`SELECT revision, action, actor, request_id, time, before, after
+		FROM car_audit WHERE car_id = ? ORDER BY seq`, carID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.CarAuditEntry{}
+	for rows.Next() {
+		var (
+			entry  = models.CarAuditEntry{CarID: carID}
+			at     int64
+			before sql.NullString
+			after  string
+		)
+
+		if err := rows.Scan(
+			&entry.Revision, &entry.Action, &entry.Actor, &entry.RequestID, &at, &before, &after,
+		); err != nil {
+			return nil, err
+		}
+		entry.Time = time.Unix(0, at).UTC()
+
+		if before.Valid {
+			var snap carSnapshot
+			if err := json.Unmarshal([]byte(before.String), &snap); err != nil {
+				return nil, err
+			}
+			car := snap.car()
+			entry.Before = &car
+		}
+
+		var snap carSnapshot
+		if err := json.Unmarshal([]byte(after), &snap); err != nil {
+			return nil, err
+		}
+		entry.After = snap.car()
+
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
+
+// carSnapshot is the JSON encoding of a car stored in the audit trail. It
+// is kept apart from models.Car so that renaming a Go field does not make
+// the stored history unreadable. Deletion times are Unix nanoseconds, as
+// in the cars table.
+type carSnapshot struct {
+	ID        string  `json:"id"`
+	Make      string  `json:"make"`
+	Model     string  `json:"model"`
+	Color     string  `json:"color"`
+	Category  string  `json:"category"`
+	Year      int     `json:"year"`
+	Package   *string `json:"package,omitempty"`
+	Mileage   *int64  `json:"mileage,omitempty"`
+	Price     *int64  `json:"price,omitempty"`
+	Revision  int64   `json:"revision"`
+	DeletedAt *int64  `json:"deleted_at,omitempty"`
+}
+
+// toSnapshot converts car into its stored form.
+func toSnapshot(car models.Car) carSnapshot {
+	snap := carSnapshot{
+		ID:       car.ID,
+		Make:     car.Make,
+		Model:    car.Model,
+		Color:    car.Color,
+		Category: car.Category,
+		Year:     car.Year,
+		Package:  car.Package,
+		Mileage:  car.Mileage,
+		Price:    car.Price,
+		Revision: car.Revision,
+	}
+	if car.DeletedAt != nil {
+		at := car.DeletedAt.UnixNano()
+		snap.DeletedAt = &at
+	}
+	return snap
+}
+
+// car converts a stored snapshot back into a car.
+func (snap carSnapshot) car() models.Car {
+	car := models.Car{
+		ID:       snap.ID,
+		Make:     snap.Make,
+		Model:    snap.Model,
+		Color:    snap.Color,
+		Category: snap.Category,
+		Year:     snap.Year,
+		Package:  snap.Package,
+		Mileage:  snap.Mileage,
+		Price:    snap.Price,
+		Revision: snap.Revision,
+	}
+	if snap.DeletedAt != nil {
+		at := time.Unix(0, *snap.DeletedAt).UTC()
+		car.DeletedAt = &at
+	}
+	return car
+}
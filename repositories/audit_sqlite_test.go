@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"cars/models"
+	"testing"
+	"time"
+)
+
+func TestSQLiteAuditStore_RejectsChanges(t *testing.T) {
+	// Arrange
+	db, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewSQLiteAuditStore(db)
+	if err != nil {
+		t.Fatalf("create audit store: %v", err)
+	}
+
+	entry := models.CarAuditEntry{
+		CarID: "1", Revision: 1, Action: models.CarCreated, Actor: "alice", Time: time.Now(),
+		After: models.Car{ID: "1", Make: "Ford", Model: "F10", Revision: 1},
+	}
+	if err := store.Append(entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Act
+	_, updateErr := db.Exec(`UPDATE car_audit SET actor = 'mallory'`)
+	_, deleteErr := db.Exec(`DELETE FROM car_audit`)
+
+	// Assert
+	if updateErr == nil {
+		t.Fatal("expected updating an audit entry to fail")
+	}
+	if deleteErr == nil {
+		t.Fatal("expected deleting an audit entry to fail")
+	}
+
+	history, err := store.History("1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(history) != 1 || history[0].Actor != "alice" {
+		t.Fatalf("expected entry to be unchanged, got %+v", history)
+	}
+}
@@ -3,111 +3,589 @@ package repositories
 import (
 	"cars/models"
 	e "cars/pkg/errors"
-	"cars/pkg/utils"
-	"strings"
+	"cars/pkg/idgen"
+	"cars/pkg/search"
+	"iter"
+	"slices"
 	"sync"
+	"time"
 )
 
+// eachChunkSize is the number of cars Each lists at a time.
+const eachChunkSize = 500
+
 // CarRepository defines methods for managing car persistence.
+//
+// Every stored car carries a Revision that starts at 1 on Create and is
+// incremented on each Update, Patch, Delete or Restore. Update and Delete
+// accept an expected revision for optimistic concurrency: zero skips the
+// check, any other value must match the stored revision or
+// ErrRevisionMismatch is returned.
+//
+// Deletes are soft: Delete and DeleteBatch only set the DeletedAt time of
+// the car. Deleted cars are kept out of every read and write as if they did
+// not exist, except for Find when includeDeleted is set, List and Each when
+// the filters include them, and Restore, which makes a deleted car live
+// again. Purge permanently removes the cars deleted before a given time.
+// Deleted cars are not counted by Makes and Models.
+//
+// Each visits the cars List would return, in the same order, without
+// requiring the caller to hold them all: fn is called for each car until it
+// returns false.
+//
+// Makes and Models list the distinct makes, and models of a make, with
+// their car counts. Names differing only in ASCII case are counted
+// together, under their most frequent spelling (see models.Spellings.Name)
+// whatever the order the cars were stored in.
+// The counts are maintained as cars are written, not computed by scanning
+// them.
+//
+// Writes report the states they replaced so that callers can record
+// exactly what changed: Update returns the car as it was before, Delete the
+// car as deleted.
+//
+// Create assigns a new ID to the car, generated by the repository's
+// IDGenerator and retried on collision with any stored car. Insert stores
+// the car under the ID it already has, and returns ErrCarExists if a live
+// or deleted car has that ID.
+//
+// CreateBatch stores several cars atomically: either every car is created
+// or none is. UpdateBatch and DeleteBatch apply to every car chosen by a
+// selector in a single atomic step and return the cars it chose, in ID
+// order, as written; in dry-run mode nothing is written and the cars are
+// returned as they would have been.
 type CarRepository interface {
-	Find(id string) (models.Car, error)
+	Find(id string, includeDeleted bool) (models.Car, error)
 	List(filters models.CarFilters) (models.Cars, error)
+	Each(filters models.CarFilters, fn func(models.Car) bool) error
+	Makes() ([]models.Facet[string], error)
+	Models(make string) ([]models.Facet[string], error)
 	Create(car *models.Car) error
-	Update(car *models.Car) error
-	Delete(id string) error
+	Insert(car *models.Car) error
+	CreateBatch(cars models.Cars) error
+	Update(car *models.Car) (models.Car, error)
+	Patch(id string, patch models.CarPatchFunc) (models.Car, error)
+	Delete(id string, revision int64) (models.Car, error)
+	UpdateBatch(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error)
+	DeleteBatch(sel models.CarSelector, dryRun bool) (models.Cars, error)
+	Restore(id string) (models.Car, error)
+	Purge(before time.Time) (int, error)
 }
 
 // DefaultCarRepository is an in-memory implementation of CarRepository.
+//
+// Free-text queries are answered from an inverted index, and distinct
+// makes and models from a catalog, both kept in sync with the stored cars
+// on every write.
 type DefaultCarRepository struct {
-	cars map[string]models.Car
-	mu   sync.RWMutex
+	cars    map[string]models.Car
+	index   search.Index
+	catalog catalog
+	ids     IDGenerator
+	mu      sync.RWMutex
 }
 
 // NewCarRepository creates a new instance of DefaultCarRepository with initial data.
-func NewCarRepository(initialData map[string]models.Car) CarRepository {
-	if initialData == nil {
-		initialData = make(map[string]models.Car)
+//
+// New cars are given IDs generated by ids, or random hexadecimal IDs when
+// ids is nil. Seeded cars without a revision start at revision 1.
+func NewCarRepository(initialData map[string]models.Car, ids IDGenerator) CarRepository {
+	if ids == nil {
+		ids = idgen.NewHex()
 	}
 
 	repo := &DefaultCarRepository{
-		cars: initialData,
+		cars: make(map[string]models.Car, len(initialData)),
+		ids:  ids,
+	}
+
+	for _, car := range initialData {
+		if car.Revision == 0 {
+			car.Revision = 1
+		}
+		repo.put(car)
 	}
 	return repo
 }
 
-// Find searches for a car by its ID.
-func (r *DefaultCarRepository) Find(id string) (models.Car, error) {
+// Find searches for a car by its ID. A deleted car is only found when
+// includeDeleted is set.
+func (r *DefaultCarRepository) Find(id string, includeDeleted bool) (models.Car, error) {
 	r.mu.RLock()
 	car, exists := r.cars[id]
 	r.mu.RUnlock()
 
-	if !exists {
+	if !exists || car.Deleted() && !includeDeleted {
 		return models.Car{}, e.ErrCarNotFound
 	}
 	return car, nil
 }
 
-// List returns all stored cars.
+// List returns the stored cars matching the given filters, ordered by
+// f.Sort and then by ID, or by relevance when f is ranked.
+//
+// Cars are matched with a compiled f.Matcher. When f.After is set,
+// only cars that sort after the cursor are returned, and at most f.Limit
+// cars are returned when it is positive.
 func (r *DefaultCarRepository) List(f models.CarFilters) (models.Cars, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	list := make(models.Cars, 0, len(r.cars))
+	return r.list(f), nil
+}
 
-	for _, car := range r.cars {
-		if f.Make != "" && !strings.EqualFold(car.Make, f.Make) {
-			continue
-		}
-		if f.Model != "" && !strings.EqualFold(car.Model, f.Model) {
-			continue
-		}
-		if f.Year != nil && *f.Year != car.Year {
-			continue
+// Each calls fn for every car List would return, in the same order, until
+// fn returns false.
+//
+// Cars are listed eachChunkSize at a time, each chunk resuming after the
+// last car of the previous one as in keyset pagination. Only one chunk is
+// held at a time, and fn is called after the read lock is released so that
+// a slow consumer does not hold up writers.
+func (r *DefaultCarRepository) Each(f models.CarFilters, fn func(models.Car) bool) error {
+	return eachChunk(f, fn, func(f models.CarFilters) (models.Cars, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+
+		return r.list(f), nil
+	})
+}
+
+// list implements List. The caller must hold r.mu.
+func (r *DefaultCarRepository) list(f models.CarFilters) models.Cars {
+	if f.Query != "" {
+		return r.search(f)
+	}
+
+	match := f.Matcher()
+	matches := func(yield func(models.Car) bool) {
+		for _, car := range r.cars {
+			if !match.Match(car) {
+				continue
+			}
+			if f.After != nil && models.CompareCars(car, f.After.Position(), f.Sort) <= 0 {
+				continue
+			}
+			if !yield(car) {
+				return
+			}
 		}
-		list = append(list, car)
 	}
 
+	return firstCars(matches, f.Limit, func(a, b models.Car) int {
+		return models.CompareCars(a, b, f.Sort)
+	})
+}
+
+// Makes returns the distinct makes of the stored cars with the number of
+// cars of each, ordered by name ignoring case. Makes differing only in case
+// are counted together.
+func (r *DefaultCarRepository) Makes() ([]models.Facet[string], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.catalog.listMakes(), nil
+}
+
+// Models returns the distinct models of the cars of the given make,
+// matched ignoring case, with the number of cars of each, ordered by name
+// ignoring case. ErrMakeNotFound is returned if no car has that make.
+func (r *DefaultCarRepository) Models(make string) ([]models.Facet[string], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list, ok := r.catalog.listModels(make)
+	if !ok {
+		return nil, e.ErrMakeNotFound
+	}
 	return list, nil
 }
 
 // Create stores a new car in the repository.
 func (r *DefaultCarRepository) Create(car *models.Car) error {
-	id, err := utils.GenerateID()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, err := newID(r.ids, r.taken)
 	if err != nil {
 		return err
 	}
 
+	car.ID = id
+	car.Revision = 1
+	r.put(*car)
+
+	return nil
+}
+
+// Insert stores a new car under its own ID and sets its revision in place.
+// ErrCarExists is returned if a car, even a deleted one, has that ID.
+func (r *DefaultCarRepository) Insert(car *models.Car) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	car.ID = id
-	r.cars[car.ID] = *car
+	if _, exists := r.cars[car.ID]; exists {
+		return e.ErrCarExists
+	}
 
+	car.Revision = 1
+	car.DeletedAt = nil
+	r.put(*car)
 	return nil
 }
 
-// Update updates an existing car in the repository.
-func (r *DefaultCarRepository) Update(car *models.Car) error {
+// CreateBatch stores several new cars at once, setting their IDs and
+// revisions in place.
+func (r *DefaultCarRepository) CreateBatch(cars models.Cars) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.cars[car.ID]; !exists {
-		return e.ErrCarNotFound
+	ids := make([]string, len(cars))
+	chosen := make(map[string]bool, len(cars))
+	for i := range cars {
+		id, err := newID(r.ids, func(id string) (bool, error) {
+			exists, _ := r.taken(id)
+			return exists || chosen[id], nil
+		})
+		if err != nil {
+			return err
+		}
+		ids[i], chosen[id] = id, true
+	}
+
+	for i := range cars {
+		cars[i].ID = ids[i]
+		cars[i].Revision = 1
+		r.put(cars[i])
 	}
 
-	r.cars[car.ID] = *car
 	return nil
 }
 
-// Delete removes a car identified by the given id from the repository.
-func (r *DefaultCarRepository) Delete(id string) error {
+// Update updates an existing car in the repository and returns the car it
+// replaced.
+//
+// If car.Revision is non-zero it must match the stored revision.
+// On success car.Revision is set to the new revision.
+func (r *DefaultCarRepository) Update(car *models.Car) (models.Car, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.live(car.ID)
+	if !exists {
+		return models.Car{}, e.ErrCarNotFound
+	}
+
+	if car.Revision != 0 && car.Revision != stored.Revision {
+		return models.Car{}, e.ErrRevisionMismatch
+	}
+
+	car.Revision = stored.Revision + 1
+	car.DeletedAt = nil
+	r.put(*car)
+	return stored, nil
+}
+
+// Patch atomically applies patch to the car identified by id and stores
+// the result.
+//
+// The patch runs while holding the write lock, so no other writer can
+// interleave between reading and storing the car. If the patch returns an
+// error, the stored car is left unchanged and the error is returned as-is.
+func (r *DefaultCarRepository) Patch(id string, patch models.CarPatchFunc) (models.Car, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, exists := r.live(id)
+	if !exists {
+		return models.Car{}, e.ErrCarNotFound
+	}
+	revision := car.Revision
+
+	if err := patch(&car); err != nil {
+		return models.Car{}, err
+	}
+
+	car.ID = id
+	car.Revision = revision + 1
+	car.DeletedAt = nil
+	r.put(car)
+	return car, nil
+}
+
+// Delete marks the car identified by the given id as deleted and returns
+// it.
+//
+// If revision is non-zero it must match the stored revision.
+func (r *DefaultCarRepository) Delete(id string, revision int64) (models.Car, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, ok := r.cars[id]; !ok {
-		return e.ErrCarNotFound
+	stored, ok := r.live(id)
+	if !ok {
+		return models.Car{}, e.ErrCarNotFound
+	}
+
+	if revision != 0 && revision != stored.Revision {
+		return models.Car{}, e.ErrRevisionMismatch
+	}
+
+	return r.softDelete(stored, deletionTime()), nil
+}
+
+// UpdateBatch atomically applies patch to every car chosen by sel, in ID
+// order, and stores the results.
+//
+// Every patch runs before any car is stored, all while holding the write
+// lock. If a patch returns an error, no car is changed and the error is
+// returned as-is. In dry-run mode the patched cars are returned but not
+// stored.
+func (r *DefaultCarRepository) UpdateBatch(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cars := r.selectCars(sel)
+	for i := range cars {
+		id, revision := cars[i].ID, cars[i].Revision
+
+		if err := patch(&cars[i]); err != nil {
+			return nil, err
+		}
+
+		cars[i].ID = id
+		cars[i].Revision = revision + 1
+		cars[i].DeletedAt = nil
 	}
 
+	if !dryRun {
+		for _, car := range cars {
+			r.put(car)
+		}
+	}
+	return cars, nil
+}
+
+// DeleteBatch atomically marks every car chosen by sel as deleted and
+// returns them. In dry-run mode the cars are returned unchanged.
+func (r *DefaultCarRepository) DeleteBatch(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cars := r.selectCars(sel)
+	if !dryRun {
+		now := deletionTime()
+		for i, car := range cars {
+			cars[i] = r.softDelete(car, now)
+		}
+	}
+	return cars, nil
+}
+
+// Restore makes the deleted car identified by id live again and returns
+// it. ErrCarNotDeleted is returned if the car has not been deleted.
+func (r *DefaultCarRepository) Restore(id string) (models.Car, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, ok := r.cars[id]
+	if !ok {
+		return models.Car{}, e.ErrCarNotFound
+	}
+	if !car.Deleted() {
+		return models.Car{}, e.ErrCarNotDeleted
+	}
+
+	car.Revision++
+	car.DeletedAt = nil
+	r.put(car)
+	return car, nil
+}
+
+// Purge permanently removes the cars deleted before the given time and
+// returns how many were removed.
+func (r *DefaultCarRepository) Purge(before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int
+	for id, car := range r.cars {
+		if car.Deleted() && car.DeletedAt.Before(before) {
+			r.remove(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// selectCars returns the cars chosen by sel, ordered by ID. The caller
+// must hold r.mu.
+func (r *DefaultCarRepository) selectCars(sel models.CarSelector) models.Cars {
+	var list models.Cars
+	if sel.Filters != nil {
+		f := *sel.Filters
+		f.Sort, f.Limit, f.After, f.IncludeDeleted = nil, 0, nil, false
+		list = r.list(f)
+	} else {
+		seen := make(map[string]bool, len(sel.IDs))
+		for _, id := range sel.IDs {
+			if car, ok := r.live(id); ok && !seen[id] {
+				seen[id] = true
+				list = append(list, car)
+			}
+		}
+	}
+
+	models.SortCars(list, nil)
+	return list
+}
+
+// search lists the cars matching f.Query, looking candidates up in the
+// inverted index instead of scanning every car. The caller must hold r.mu.
+func (r *DefaultCarRepository) search(f models.CarFilters) models.Cars {
+	scores := r.index.Search(f.Query)
+
+	f.Query = ""
+	match := f.Matcher()
+
+	matches := func(yield func(models.Car) bool) {
+		for id, score := range scores {
+			car := r.cars[id]
+			if !match.Match(car) {
+				continue
+			}
+			if f.After != nil && r.compare(f, car, score, f.After.Position(), f.After.Score) <= 0 {
+				continue
+			}
+			if !yield(car) {
+				return
+			}
+		}
+	}
+
+	return firstCars(matches, f.Limit, func(a, b models.Car) int {
+		return r.compare(f, a, scores[a.ID], b, scores[b.ID])
+	})
+}
+
+// firstCars returns the cars of seq ordered by cmp, only the first limit
+// of them when limit is positive. In that case no more than limit cars are
+// held while seq is read, so that a page of a large listing does not cost
+// a copy of every match.
+func firstCars(seq iter.Seq[models.Car], limit int, cmp func(a, b models.Car) int) models.Cars {
+	if limit <= 0 {
+		list := models.Cars{}
+		for car := range seq {
+			list = append(list, car)
+		}
+		slices.SortFunc(list, cmp)
+		return list
+	}
+
+	list := make(models.Cars, 0, min(limit, eachChunkSize))
+	for car := range seq {
+		i, _ := slices.BinarySearchFunc(list, car, cmp)
+		switch {
+		case i == limit:
+			continue
+		case len(list) == limit:
+			list = list[:limit-1]
+		}
+		list = slices.Insert(list, i, car)
+	}
+	return list
+}
+
+// eachChunk implements Each on top of list, which must answer f as List
+// does. Cars are listed eachChunkSize at a time, each chunk resuming after
+// the last car of the previous one, and fn is called once a chunk has been
+// listed.
+func eachChunk(f models.CarFilters, fn func(models.Car) bool, list func(models.CarFilters) (models.Cars, error)) error {
+	remaining := f.Limit
+	for {
+		f.Limit = eachChunkSize
+		if remaining > 0 {
+			f.Limit = min(remaining, eachChunkSize)
+		}
+
+		cars, err := list(f)
+		if err != nil {
+			return err
+		}
+
+		for _, car := range cars {
+			if !fn(car) {
+				return nil
+			}
+		}
+
+		if len(cars) < f.Limit || remaining > 0 && remaining == len(cars) {
+			return nil
+		}
+		if remaining > 0 {
+			remaining -= len(cars)
+		}
+		f.After = f.CursorAfter(cars[len(cars)-1])
+	}
+}
+
+// compare orders two search results by relevance when f is ranked, or by
+// f.Sort otherwise.
+func (r *DefaultCarRepository) compare(f models.CarFilters, a models.Car, aScore float64, b models.Car, bScore float64) int {
+	if len(f.Sort) > 0 {
+		return models.CompareCars(a, b, f.Sort)
+	}
+	return models.CompareRanked(a, aScore, b, bScore)
+}
+
+// live returns the car with the given ID unless it is missing or deleted.
+// The caller must hold r.mu.
+func (r *DefaultCarRepository) live(id string) (models.Car, bool) {
+	car, ok := r.cars[id]
+	if !ok || car.Deleted() {
+		return models.Car{}, false
+	}
+	return car, true
+}
+
+// taken reports whether a car, live or deleted, has the given ID. The
+// caller must hold the lock.
+func (r *DefaultCarRepository) taken(id string) (bool, error) {
+	_, exists := r.cars[id]
+	return exists, nil
+}
+
+// softDelete marks car as deleted at the given time, bumps its revision
+// and returns it. The caller must hold the write lock.
+func (r *DefaultCarRepository) softDelete(car models.Car, at time.Time) models.Car {
+	car.Revision++
+	car.DeletedAt = &at
+	r.put(car)
+	return car
+}
+
+// deletionTime returns the current time, as recorded in DeletedAt: in UTC
+// and without a monotonic clock reading, so that it compares equal to the
+// same time read back from storage.
+func deletionTime() time.Time {
+	return time.Now().UTC().Round(0)
+}
+
+// put stores car, indexes its searchable text and counts it in the
+// catalog, replacing any car with the same ID. The caller must hold the
+// write lock.
+func (r *DefaultCarRepository) put(car models.Car) {
+	if stored, ok := r.cars[car.ID]; ok {
+		r.catalog.remove(stored)
+	}
+
+	r.cars[car.ID] = car
+	r.index.Add(car.ID, models.CarSearchFields(car)...)
+	r.catalog.add(car)
+}
+
+// remove deletes the car with the given ID, which must exist, from the
+// store, the index and the catalog. The caller must hold the write lock.
+func (r *DefaultCarRepository) remove(id string) {
+	r.catalog.remove(r.cars[id])
 	delete(r.cars, id)
-	return nil
+	r.index.Remove(id)
 }
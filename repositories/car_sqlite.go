@@ -0,0 +1,1007 @@
+package repositories
+
+import (
+	"cars/models"
+	e "cars/pkg/errors"
+	"cars/pkg/filterexpr"
+	"cars/pkg/idgen"
+	"cars/pkg/search"
+	"cars/pkg/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// carColumns lists the cars table columns in the order expected by scanCar.
+const carColumns = `id, make, model, color, category, year, package, mileage, price, revision, deleted_at`
+
+// updateCarQuery replaces every column of the live car with the given ID,
+// increments its revision and returns the new one. When the last argument
+// is non-zero, the stored revision must match it.
+const updateCarQuery = `UPDATE cars
+	SET make = ?, model = ?, color = ?, category = ?, year = ?,
+	    package = ?, mileage = ?, price = ?, revision = revision + 1
+	WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR revision = ?)
+	RETURNING revision`
+
+// deleteCarQuery marks the live car with the given ID as deleted at the
+// time of the first argument, increments its revision and returns the car.
+// When the last argument is non-zero, the stored revision must match it.
+const deleteCarQuery = `UPDATE cars
+	SET deleted_at = ?, revision = revision + 1
+	WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR revision = ?)
+	RETURNING ` + carColumns
+
+// SQLiteCarRepository is a CarRepository backed by an SQLite database.
+type SQLiteCarRepository struct {
+	db  *sql.DB
+	ids IDGenerator
+}
+
+// OpenSQLite opens the SQLite database identified by dsn.
+//
+// The pool is limited to a single connection: SQLite serializes writers
+// anyway, and it keeps in-memory databases (":memory:") consistent across
+// calls instead of giving each connection its own empty database.
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQLiteCarRepository creates a new SQLiteCarRepository using db.
+//
+// New cars are given IDs generated by ids, or random hexadecimal IDs when
+// ids is nil. Pending schema migrations are applied before the repository
+// is returned.
+func NewSQLiteCarRepository(db *sql.DB, ids IDGenerator) (CarRepository, error) {
+	if ids == nil {
+		ids = idgen.NewHex()
+	}
+
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+	if err := indexMissingTerms(db); err != nil {
+		return nil, err
+	}
+	return &SQLiteCarRepository{db: db, ids: ids}, nil
+}
+
+// Find searches for a car by its ID. A deleted car is only found when
+// includeDeleted is set.
+func (r *SQLiteCarRepository) Find(id string, includeDeleted bool) (models.Car, error) {
+	query := `SELECT ` + carColumns + ` FROM cars WHERE id = ?`
+	if !includeDeleted {
+		query += ` AND deleted_at IS NULL`
+	}
+	row := r.db.QueryRow(query, id)
+
+	car, err := scanCar(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Car{}, e.ErrCarNotFound
+	}
+	if err != nil {
+		return models.Car{}, err
+	}
+	return car, nil
+}
+
+// List returns the stored cars matching the given filters, ordered by
+// f.Sort and then by ID, or by relevance when f is ranked.
+//
+// Text filters are compared case-insensitively. Sorting and pagination
+// are pushed down to the database using keyset conditions on the sort keys
+// and the primary key. Free-text queries are answered from the car_terms
+// inverted index.
+func (r *SQLiteCarRepository) List(f models.CarFilters) (models.Cars, error) {
+	return listCars(r.db, f)
+}
+
+// Each calls fn for every car List would return, in the same order, until
+// fn returns false.
+//
+// Cars are read eachChunkSize at a time, each query resuming after the last
+// car of the previous one as in keyset pagination, so that neither the whole
+// result nor the database connection is held while fn runs.
+func (r *SQLiteCarRepository) Each(f models.CarFilters, fn func(models.Car) bool) error {
+	return eachChunk(f, fn, func(f models.CarFilters) (models.Cars, error) {
+		return listCars(r.db, f)
+	})
+}
+
+// Makes returns the distinct makes of the stored cars with the number of
+// cars of each, ordered by name ignoring case. They are read from the
+// car_makes table, which triggers keep up to date.
+func (r *SQLiteCarRepository) Makes() ([]models.Facet[string], error) {
+	return queryFacets(r.db, `SELECT name, count FROM car_makes ORDER BY key`)
+}
+
+// Models returns the distinct models of the cars of the given make,
+// matched ignoring case, with the number of cars of each, ordered by name
+// ignoring case. ErrMakeNotFound is returned if no car has that make.
+func (r *SQLiteCarRepository) Models(make string) ([]models.Facet[string], error) {
+	list, err := queryFacets(r.db, `SELECT name, count FROM car_models WHERE make_key = lower(?) ORDER BY key`, make)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, e.ErrMakeNotFound
+	}
+	return list, nil
+}
+
+// queryFacets runs a query returning names and counts and scans every row.
+func queryFacets(q querier, query string, args ...any) ([]models.Facet[string], error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]models.Facet[string], 0)
+	for rows.Next() {
+		var f models.Facet[string]
+		if err := rows.Scan(&f.Value, &f.Count); err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// listCars implements List using q.
+func listCars(q querier, f models.CarFilters) (models.Cars, error) {
+	var (
+		query = `SELECT ` + carColumns + ` FROM cars`
+		args  []any
+	)
+
+	if f.Query != "" {
+		tokens := search.QueryTokens(f.Query)
+		if len(tokens) == 0 {
+			return models.Cars{}, nil
+		}
+
+		cte, cteArgs := searchCTE(tokens)
+		query = cte + ` SELECT ` + carColumns + ` FROM cars JOIN matches ON matches.car_id = cars.id`
+		args = cteArgs
+	}
+
+	where, whereArgs := filterConditions(f)
+	args = append(args, whereArgs...)
+
+	if f.After != nil {
+		cond, condArgs := keysetCondition(*f.After, f.Sort)
+		if f.Ranked() {
+			cond, condArgs = "(score < ? OR (score = ? AND id > ?))", []any{f.After.Score, f.After.Score, f.After.ID}
+		}
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if f.Ranked() {
+		query += ` ORDER BY score DESC, id`
+	} else {
+		query += ` ORDER BY ` + orderByClause(f.Sort)
+	}
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	return queryCars(q, query, args...)
+}
+
+// queryCars runs a query returning carColumns and scans every row.
+func queryCars(q querier, query string, args ...any) (models.Cars, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make(models.Cars, 0)
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, car)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Create stores a new car in the repository.
+func (r *SQLiteCarRepository) Create(car *models.Car) error {
+	cars := models.Cars{*car}
+	if err := r.CreateBatch(cars); err != nil {
+		return err
+	}
+
+	*car = cars[0]
+	return nil
+}
+
+// CreateBatch stores several new cars in a single transaction, setting
+// their IDs and revisions in place once it commits.
+func (r *SQLiteCarRepository) CreateBatch(cars models.Cars) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(cars))
+	chosen := make(map[string]bool, len(cars))
+	for i, car := range cars {
+		id, err := newID(r.ids, func(id string) (bool, error) {
+			if chosen[id] {
+				return true, nil
+			}
+			return idTaken(tx, id)
+		})
+		if err != nil {
+			return err
+		}
+		ids[i], chosen[id] = id, true
+
+		car.ID = id
+		if err := insertCar(tx, car); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for i := range cars {
+		cars[i].ID = ids[i]
+		cars[i].Revision = 1
+	}
+	return nil
+}
+
+// Insert stores a new car under its own ID and sets its revision in place.
+// ErrCarExists is returned if a car, even a deleted one, has that ID.
+func (r *SQLiteCarRepository) Insert(car *models.Car) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	taken, err := idTaken(tx, car.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return e.ErrCarExists
+	}
+
+	if err := insertCar(tx, *car); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	car.Revision = 1
+	car.DeletedAt = nil
+	return nil
+}
+
+// Update updates an existing car in the repository and returns the car it
+// replaced, read in the same transaction.
+//
+// If car.Revision is non-zero it must match the stored revision.
+// On success car.Revision is set to the new revision.
+func (r *SQLiteCarRepository) Update(car *models.Car) (models.Car, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Car{}, err
+	}
+	defer tx.Rollback()
+
+	stored, err := findLive(tx, car.ID)
+	if err != nil {
+		return models.Car{}, err
+	}
+
+	revision := car.Revision
+	if err := updateCar(tx, car); err != nil {
+		car.Revision = revision
+		return models.Car{}, err
+	}
+
+	if err := indexTerms(tx, *car); err != nil {
+		car.Revision = revision
+		return models.Car{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		car.Revision = revision
+		return models.Car{}, err
+	}
+	return stored, nil
+}
+
+// Patch atomically applies patch to the car identified by id and stores
+// the result.
+//
+// The read, the patch and the write run in a single transaction. If the
+// patch returns an error, the transaction is rolled back and the error is
+// returned as-is. The patch must not call back into the repository.
+func (r *SQLiteCarRepository) Patch(id string, patch models.CarPatchFunc) (models.Car, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Car{}, err
+	}
+	defer tx.Rollback()
+
+	car, err := findLive(tx, id)
+	if err != nil {
+		return models.Car{}, err
+	}
+	revision := car.Revision
+
+	if err := patch(&car); err != nil {
+		return models.Car{}, err
+	}
+	car.ID = id
+	car.Revision = revision
+
+	if err := updateCar(tx, &car); err != nil {
+		return models.Car{}, err
+	}
+
+	if err := indexTerms(tx, car); err != nil {
+		return models.Car{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Car{}, err
+	}
+	return car, nil
+}
+
+// Delete marks the car identified by the given id as deleted and returns
+// it. Its search terms are kept, so that it can still be found among
+// deleted cars.
+//
+// If revision is non-zero it must match the stored revision.
+func (r *SQLiteCarRepository) Delete(id string, revision int64) (models.Car, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Car{}, err
+	}
+	defer tx.Rollback()
+
+	car, err := scanCar(tx.QueryRow(deleteCarQuery, deletionTime().UnixNano(), id, revision, revision))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Car{}, missingOrMismatch(tx, id)
+	}
+	if err != nil {
+		return models.Car{}, err
+	}
+	return car, tx.Commit()
+}
+
+// UpdateBatch applies patch to every car chosen by sel, in ID order, and
+// stores the results in a single transaction.
+//
+// If a patch returns an error, the transaction is rolled back and the
+// error is returned as-is. In dry-run mode the transaction is always
+// rolled back and the patched cars are returned as they would have been
+// stored.
+func (r *SQLiteCarRepository) UpdateBatch(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cars, err := selectCars(tx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range cars {
+		id, revision := cars[i].ID, cars[i].Revision
+
+		if err := patch(&cars[i]); err != nil {
+			return nil, err
+		}
+		cars[i].ID = id
+		cars[i].Revision = revision
+
+		if err := updateCar(tx, &cars[i]); err != nil {
+			return nil, err
+		}
+
+		if err := indexTerms(tx, cars[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return cars, nil
+	}
+	return cars, tx.Commit()
+}
+
+// DeleteBatch marks every car chosen by sel as deleted in a single
+// transaction and returns them. In dry-run mode the cars are returned
+// unchanged.
+func (r *SQLiteCarRepository) DeleteBatch(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cars, err := selectCars(tx, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return cars, nil
+	}
+
+	now := deletionTime().UnixNano()
+	for i, car := range cars {
+		cars[i], err = scanCar(tx.QueryRow(deleteCarQuery, now, car.ID, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cars, tx.Commit()
+}
+
+// Restore makes the deleted car identified by id live again and returns
+// it. ErrCarNotDeleted is returned if the car has not been deleted.
+func (r *SQLiteCarRepository) Restore(id string) (models.Car, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Car{}, err
+	}
+	defer tx.Rollback()
+
+	car, err := scanCar(tx.QueryRow(`SELECT `+carColumns+` FROM cars WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Car{}, e.ErrCarNotFound
+	}
+	if err != nil {
+		return models.Car{}, err
+	}
+	if !car.Deleted() {
+		return models.Car{}, e.ErrCarNotDeleted
+	}
+
+	err = tx.QueryRow(
+		`UPDATE cars SET deleted_at = NULL, revision = revision + 1 WHERE id = ? RETURNING revision`, id,
+	).Scan(&car.Revision)
+	if err != nil {
+		return models.Car{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Car{}, err
+	}
+
+	car.DeletedAt = nil
+	return car, nil
+}
+
+// Purge permanently removes the cars deleted before the given time, with
+// their search terms, and returns how many were removed.
+func (r *SQLiteCarRepository) Purge(before time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := before.UnixNano()
+	if _, err := tx.Exec(
+		`DELETE FROM car_terms WHERE car_id IN (SELECT id FROM cars WHERE deleted_at < ?)`, cutoff,
+	); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`DELETE FROM cars WHERE deleted_at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), tx.Commit()
+}
+
+// selectCars returns the cars chosen by sel using q, ordered by ID.
+func selectCars(q querier, sel models.CarSelector) (models.Cars, error) {
+	if sel.Filters != nil {
+		f := *sel.Filters
+		f.Sort, f.Limit, f.After, f.IncludeDeleted = nil, 0, nil, false
+
+		cars, err := listCars(q, f)
+		if err != nil {
+			return nil, err
+		}
+		models.SortCars(cars, nil)
+		return cars, nil
+	}
+
+	if len(sel.IDs) == 0 {
+		return models.Cars{}, nil
+	}
+
+	where, args := appendIn(nil, nil, "id", sel.IDs)
+	return queryCars(q, `SELECT `+carColumns+` FROM cars WHERE `+where[0]+` AND deleted_at IS NULL ORDER BY id`, args...)
+}
+
+// searchCTE builds a WITH clause defining matches(car_id, score): the cars
+// matching every token with their relevance, computed like search.Score.
+//
+// Each token gets its own table holding, per car, the best weight among the
+// terms it matches exactly or, scaled by search.PrefixFactor, as a prefix.
+// Prefix matches are the range [token, prefixEnd(token)), which SQLite
+// seeks in the (term, car_id) primary key; LIKE would scan all of it, as it
+// ignores case while term compares bytes.
+func searchCTE(tokens []string) (string, []any) {
+	var (
+		tables []string
+		args   []any
+		score  []string
+		joins  []string
+	)
+
+	for i, token := range tokens {
+		name := fmt.Sprintf("t%d", i)
+		tables = append(tables, name+`(car_id, score) AS (
+			SELECT car_id, MAX(CASE WHEN term = ? THEN weight ELSE weight * ? END)
+			FROM car_terms WHERE term >= ? AND term < ? GROUP BY car_id)`)
+		args = append(args, token, search.PrefixFactor, token, prefixEnd(token))
+
+		score = append(score, name+".score")
+		if i > 0 {
+			joins = append(joins, "JOIN "+name+" ON "+name+".car_id = t0.car_id")
+		}
+	}
+
+	tables = append(tables, `matches(car_id, score) AS (
+		SELECT t0.car_id, `+strings.Join(score, " + ")+` FROM t0 `+strings.Join(joins, " ")+`)`)
+
+	return `WITH ` + strings.Join(tables, ", "), args
+}
+
+// prefixEnd returns the smallest string greater than every string starting
+// with prefix, by incrementing its last byte. Tokens are made of letters and
+// digits, so prefix is non-empty and never ends in the byte 0xFF, which
+// valid UTF-8 does not use.
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	end[len(end)-1]++
+	return string(end)
+}
+
+// indexTerms replaces the car_terms rows of car with the terms of its
+// current searchable text.
+func indexTerms(q querier, car models.Car) error {
+	if _, err := q.Exec(`DELETE FROM car_terms WHERE car_id = ?`, car.ID); err != nil {
+		return err
+	}
+
+	for term, weight := range search.Terms(models.CarSearchFields(car)...) {
+		if _, err := q.Exec(
+			`INSERT INTO car_terms (car_id, term, weight) VALUES (?, ?, ?)`,
+			car.ID, term, weight,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexMissingTerms indexes the cars that have no car_terms rows, such as
+// rows written before the index existed or inserted outside the repository.
+func indexMissingTerms(db *sql.DB) error {
+	rows, err := db.Query(`SELECT ` + carColumns + ` FROM cars
+		WHERE NOT EXISTS (SELECT 1 FROM car_terms WHERE car_terms.car_id = cars.id)`)
+	if err != nil {
+		return err
+	}
+
+	var cars models.Cars
+	for rows.Next() {
+		car, err := scanCar(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		cars = append(cars, car)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, car := range cars {
+		if err := indexTerms(db, car); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterConditions builds the WHERE conditions and their arguments that
+// select the cars matching f, ignoring pagination.
+func filterConditions(f models.CarFilters) ([]string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+
+	if !f.IncludeDeleted {
+		where = append(where, "deleted_at IS NULL")
+	}
+
+	where, args = appendIn(where, args, "make COLLATE NOCASE", f.Make)
+	where, args = appendIn(where, args, "model COLLATE NOCASE", f.Model)
+	where, args = appendIn(where, args, "category COLLATE NOCASE", f.Category)
+	where, args = appendIn(where, args, "color COLLATE NOCASE", f.Color)
+	where, args = appendIn(where, args, "package COLLATE NOCASE", f.Package)
+	where, args = appendIn(where, args, "year", f.Year)
+	where, args = appendRange(where, args, "year", f.MinYear, f.MaxYear)
+	where, args = appendRange(where, args, "price", f.MinPrice, f.MaxPrice)
+	where, args = appendRange(where, args, "mileage", f.MinMileage, f.MaxMileage)
+
+	if f.Expr != nil {
+		cond, condArgs := exprCondition(f.Expr)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+	return where, args
+}
+
+// exprColumns maps filter expression fields to the column expressions they
+// compare. Text columns use NOCASE like filterexpr.Eval.
+var exprColumns = map[string]string{
+	"make":     "make COLLATE NOCASE",
+	"model":    "model COLLATE NOCASE",
+	"color":    "color COLLATE NOCASE",
+	"category": "category COLLATE NOCASE",
+	"package":  "package COLLATE NOCASE",
+	"year":     "year",
+	"mileage":  "mileage",
+	"price":    "price",
+}
+
+// exprCondition translates a checked filter expression into a WHERE
+// condition with the semantics of filterexpr.Eval.
+//
+// Comparisons are wrapped in COALESCE so that a NULL column yields a
+// definite true or false instead of SQL's unknown, which NOT would
+// otherwise propagate.
+func exprCondition(expr filterexpr.Expr) (string, []any) {
+	switch e := expr.(type) {
+	case *filterexpr.Logical:
+		left, leftArgs := exprCondition(e.Left)
+		right, rightArgs := exprCondition(e.Right)
+		return "(" + left + " " + e.Op + " " + right + ")", append(leftArgs, rightArgs...)
+
+	case *filterexpr.Not:
+		cond, args := exprCondition(e.X)
+		return "(NOT " + cond + ")", args
+
+	case *filterexpr.Compare:
+		column := exprColumns[e.Field]
+		switch {
+		case e.Value.Kind == filterexpr.KindNull && e.Op == "=":
+			return column + " IS NULL", nil
+		case e.Value.Kind == filterexpr.KindNull:
+			return column + " IS NOT NULL", nil
+		case e.Op == "!=":
+			return "COALESCE(" + column + " != ?, 1)", []any{exprArg(e.Value)}
+		default:
+			return "COALESCE(" + column + " " + e.Op + " ?, 0)", []any{exprArg(e.Value)}
+		}
+
+	case *filterexpr.In:
+		var args []any
+		for _, v := range e.Values {
+			args = append(args, exprArg(v))
+		}
+		cond := "COALESCE(" + exprColumns[e.Field] + " IN (?" + strings.Repeat(", ?", len(args)-1) + "), 0)"
+		if e.Not {
+			cond = "(NOT " + cond + ")"
+		}
+		return cond, args
+	}
+	return "0", nil
+}
+
+// exprArg converts a filter expression literal into a query argument.
+func exprArg(v filterexpr.Value) any {
+	if v.Kind == filterexpr.KindNumber {
+		return v.Num
+	}
+	return v.Str
+}
+
+// appendIn adds a condition matching column against any of values,
+// unless values is empty.
+func appendIn[T any](where []string, args []any, column string, values []T) ([]string, []any) {
+	if len(values) == 0 {
+		return where, args
+	}
+
+	where = append(where, column+" IN (?"+strings.Repeat(", ?", len(values)-1)+")")
+	for _, v := range values {
+		args = append(args, v)
+	}
+	return where, args
+}
+
+// appendRange adds inclusive bound conditions on column for each non-nil
+// bound. Comparisons with NULL are never true, so cars without a value are
+// excluded as soon as one bound is set.
+func appendRange[T int | int64](where []string, args []any, column string, min, max *T) ([]string, []any) {
+	if min != nil {
+		where = append(where, column+" >= ?")
+		args = append(args, *min)
+	}
+	if max != nil {
+		where = append(where, column+" <= ?")
+		args = append(args, *max)
+	}
+	return where, args
+}
+
+// sortColumns maps each sort field to the column expression it orders by.
+// Text columns use NOCASE to match the case-insensitive filters.
+var sortColumns = map[models.CarSortField]string{
+	models.SortByYear:    "year",
+	models.SortByPrice:   "price",
+	models.SortByMileage: "mileage",
+	models.SortByMake:    "make COLLATE NOCASE",
+	models.SortByModel:   "model COLLATE NOCASE",
+}
+
+// orderByClause builds the ORDER BY list for keys, ending with the ID
+// tie-breaker. SQLite sorts NULLs first, which matches models.CompareCars.
+func orderByClause(keys []models.CarSort) string {
+	terms := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		term := sortColumns[key.Field]
+		if key.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(append(terms, "id"), ", ")
+}
+
+// keysetCondition builds a WHERE condition selecting the rows that sort
+// strictly after the cursor position under keys.
+//
+// For keys k1..kn it expands to
+//
+//	k1 > v1 OR (k1 = v1 AND k2 > v2) OR ... OR (k1 = v1 AND ... AND id > vid)
+//
+// with ">" flipped for descending keys and NULL-safe comparisons for the
+// optional price and mileage columns.
+func keysetCondition(after models.CarCursor, keys []models.CarSort) (string, []any) {
+	position := after.Position()
+
+	var (
+		branches []string
+		args     []any
+		equal    []string
+		eqArgs   []any
+	)
+
+	for _, key := range keys {
+		column := sortColumns[key.Field]
+		value := sortValue(position, key.Field)
+
+		var next string
+		var nextArgs []any
+		switch {
+		case value == nil && key.Desc:
+			// NULLs sort last in descending order: nothing follows them.
+			next = ""
+		case value == nil:
+			next = column + " IS NOT NULL"
+		case key.Desc:
+			next = "(" + column + " < ? OR " + column + " IS NULL)"
+			nextArgs = []any{value}
+		default:
+			next = column + " > ?"
+			nextArgs = []any{value}
+		}
+
+		if next != "" {
+			branches = append(branches, strings.Join(append(slices.Clone(equal), next), " AND "))
+			args = append(append(args, eqArgs...), nextArgs...)
+		}
+
+		if value == nil {
+			equal = append(equal, column+" IS NULL")
+		} else {
+			equal = append(equal, column+" = ?")
+			eqArgs = append(eqArgs, value)
+		}
+	}
+
+	branches = append(branches, strings.Join(append(equal, "id > ?"), " AND "))
+	args = append(append(args, eqArgs...), after.ID)
+
+	return "((" + strings.Join(branches, ") OR (") + "))", args
+}
+
+// sortValue returns the value of field in car as a query argument,
+// or nil when the optional value is missing.
+func sortValue(car models.Car, field models.CarSortField) any {
+	switch field {
+	case models.SortByYear:
+		return car.Year
+	case models.SortByPrice:
+		if car.Price != nil {
+			return *car.Price
+		}
+	case models.SortByMileage:
+		if car.Mileage != nil {
+			return *car.Mileage
+		}
+	case models.SortByMake:
+		return car.Make
+	case models.SortByModel:
+		return car.Model
+	}
+	return nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// updateCar stores car using q, enforcing car.Revision when it is non-zero,
+// and sets car.Revision to the new revision. Deleted cars are not updated.
+func updateCar(q querier, car *models.Car) error {
+	err := q.QueryRow(updateCarQuery,
+		car.Make, car.Model, car.Color, car.Category, car.Year,
+		car.Package, car.Mileage, car.Price,
+		car.ID, car.Revision, car.Revision,
+	).Scan(&car.Revision)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return missingOrMismatch(q, car.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	car.DeletedAt = nil
+	return nil
+}
+
+// insertCar stores car as a new live car at revision 1 using q and
+// indexes its search terms.
+func insertCar(q querier, car models.Car) error {
+	_, err := q.Exec(
+		`INSERT INTO cars (`+carColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, NULL)`,
+		car.ID, car.Make, car.Model, car.Color, car.Category, car.Year,
+		car.Package, car.Mileage, car.Price,
+	)
+	if err != nil {
+		return err
+	}
+	return indexTerms(q, car)
+}
+
+// idTaken reports whether a car, live or deleted, has the given ID.
+func idTaken(q querier, id string) (bool, error) {
+	var exists int
+	err := q.QueryRow(`SELECT 1 FROM cars WHERE id = ?`, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// findLive reads the live car with the given ID using q.
+func findLive(q querier, id string) (models.Car, error) {
+	car, err := scanCar(q.QueryRow(`SELECT `+carColumns+` FROM cars WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Car{}, e.ErrCarNotFound
+	}
+	return car, err
+}
+
+// missingOrMismatch explains why a conditional write on id changed no rows:
+// ErrRevisionMismatch if the car is live, ErrCarNotFound otherwise.
+func missingOrMismatch(q querier, id string) error {
+	var exists int
+	err := q.QueryRow(`SELECT 1 FROM cars WHERE id = ? AND deleted_at IS NULL`, id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return e.ErrCarNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return e.ErrRevisionMismatch
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanCar reads a single car from a row selected with carColumns.
+func scanCar(s rowScanner) (models.Car, error) {
+	var (
+		car     models.Car
+		pkg     sql.NullString
+		mileage sql.NullInt64
+		price   sql.NullInt64
+		deleted sql.NullInt64
+	)
+
+	if err := s.Scan(
+		&car.ID, &car.Make, &car.Model, &car.Color, &car.Category, &car.Year,
+		&pkg, &mileage, &price, &car.Revision, &deleted,
+	); err != nil {
+		return models.Car{}, err
+	}
+
+	if pkg.Valid {
+		car.Package = utils.Ptr(pkg.String)
+	}
+	if mileage.Valid {
+		car.Mileage = utils.Ptr(mileage.Int64)
+	}
+	if price.Valid {
+		car.Price = utils.Ptr(price.Int64)
+	}
+	if deleted.Valid {
+		car.DeletedAt = utils.Ptr(time.Unix(0, deleted.Int64).UTC())
+	}
+	return car, nil
+}
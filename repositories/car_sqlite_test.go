@@ -0,0 +1,562 @@
+package repositories
+
+import (
+	"cars/models"
+	e "cars/pkg/errors"
+	u "cars/pkg/utils"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestSQLiteRepository returns an SQLiteCarRepository backed by a fresh
+// in-memory database, seeded with the given cars.
+func newTestSQLiteRepository(t *testing.T, seed ...models.Car) *SQLiteCarRepository {
+	t.Helper()
+
+	db, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := NewSQLiteCarRepository(db, nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+
+	for _, car := range seed {
+		_, err := db.Exec(
+			`INSERT INTO cars (`+carColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			car.ID, car.Make, car.Model, car.Color, car.Category, car.Year,
+			car.Package, car.Mileage, car.Price, max(car.Revision, 1), nil,
+		)
+		if err != nil {
+			t.Fatalf("seed car %s: %v", car.ID, err)
+		}
+	}
+	return repo.(*SQLiteCarRepository)
+}
+
+func TestMigrate_IsIdempotent(t *testing.T) {
+	db, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := Migrate(db); err != nil {
+			t.Fatalf("migrate run %d: %v", i+1, err)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatalf("count migrations: %v", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+
+	if count != len(migrations) {
+		t.Fatalf("expected %d applied migrations, got %d", len(migrations), count)
+	}
+}
+
+func TestSQLiteCarRepository_Find(t *testing.T) {
+	t.Run("should return car when it exists", func(t *testing.T) {
+		// Arrange
+		expected := models.Car{
+			ID:       "1",
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Black",
+			Category: "Sedan",
+			Year:     2020,
+			Package:  u.Ptr("LE"),
+			Mileage:  u.Ptr(int64(1500)),
+			Price:    u.Ptr(int64(1999900)),
+			Revision: 1,
+		}
+
+		repo := newTestSQLiteRepository(t, expected)
+
+		// Act
+		got, err := repo.Find(expected.ID, false)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("expected %+v car, got %+v", expected, got)
+		}
+	})
+
+	t.Run("should keep optional fields nil", func(t *testing.T) {
+		// Arrange
+		expected := models.Car{ID: "1", Make: "Kia", Model: "Rio", Color: "White", Category: "Sedan", Year: 2021}
+		repo := newTestSQLiteRepository(t, expected)
+
+		// Act
+		got, err := repo.Find(expected.ID, false)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.Package != nil || got.Mileage != nil || got.Price != nil {
+			t.Errorf("expected nil optional fields, got %+v", got)
+		}
+	})
+
+	t.Run("should return error when car does not exist", func(t *testing.T) {
+		// Arrange
+		repo := newTestSQLiteRepository(t)
+
+		// Act
+		_, err := repo.Find("missing-id", false)
+
+		// Assert
+		if !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+}
+
+func TestSQLiteCarRepository_List(t *testing.T) {
+	repo := newTestSQLiteRepository(t,
+		models.Car{ID: "1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010},
+		models.Car{ID: "2", Make: "Toyota", Model: "Camry", Color: "White", Category: "Sedan", Year: 2019},
+		models.Car{ID: "3", Make: "Toyota", Model: "Rav4", Color: "Red", Category: "SUV", Year: 2018},
+		models.Car{ID: "4", Make: "Ford", Model: "Bronco", Color: "Burnt Orange", Category: "SUV", Year: 2022},
+	)
+
+	tests := []struct {
+		name     string
+		filters  models.CarFilters
+		expected []string
+	}{
+		{
+			name:     "No filters, returns all",
+			filters:  models.CarFilters{},
+			expected: []string{"1", "2", "3", "4"},
+		},
+		{
+			name:     "Filter by make",
+			filters:  models.CarFilters{Make: []string{"Toyota"}},
+			expected: []string{"2", "3"},
+		},
+		{
+			name:     "Filter by year",
+			filters:  models.CarFilters{Year: []int{2010}},
+			expected: []string{"1"},
+		},
+		{
+			name: "Filter by make, model and year",
+			filters: models.CarFilters{
+				Make:  []string{"Ford"},
+				Model: []string{"Bronco"},
+				Year:  []int{2022},
+			},
+			expected: []string{"4"},
+		},
+		{
+			name: "Case-insensitive filtering",
+			filters: models.CarFilters{
+				Make:  []string{"tOyOtA"},
+				Model: []string{"cAmRy"},
+			},
+			expected: []string{"2"},
+		},
+		{
+			name:     "No match, returns empty list",
+			filters:  models.CarFilters{Make: []string{"BMW"}},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.List(tt.filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got == nil {
+				t.Fatal("expected non-nil list")
+			}
+
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d cars, got %d", len(tt.expected), len(got))
+			}
+
+			ids := make(map[string]bool, len(got))
+			for _, car := range got {
+				ids[car.ID] = true
+			}
+			for _, id := range tt.expected {
+				if !ids[id] {
+					t.Errorf("expected car ID %s not found in result", id)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteCarRepository_Create(t *testing.T) {
+	// Arrange
+	repo := newTestSQLiteRepository(t)
+
+	car := &models.Car{
+		Make:     "Honda",
+		Model:    "Civic",
+		Color:    "Blue",
+		Category: "Sedan",
+		Year:     2022,
+		Price:    u.Ptr(int64(2100000)),
+	}
+
+	// Act
+	err := repo.Create(car)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if car.ID == "" {
+		t.Fatal("expected generated car ID")
+	}
+
+	stored, err := repo.Find(car.ID, false)
+	if err != nil {
+		t.Fatalf("expected car to be stored in repository: %v", err)
+	}
+
+	if !reflect.DeepEqual(stored, *car) {
+		t.Fatalf("expected stored car %+v, got %+v", *car, stored)
+	}
+}
+
+func TestSQLiteCarRepository_CreateBatch(t *testing.T) {
+	t.Run("should create nothing when one insert fails", func(t *testing.T) {
+		// Arrange
+		repo := newTestSQLiteRepository(t)
+
+		if _, err := repo.db.Exec(`CREATE TRIGGER reject_car BEFORE INSERT ON cars
+			WHEN NEW.make = 'Reject' BEGIN SELECT RAISE(ABORT, 'rejected'); END`); err != nil {
+			t.Fatalf("create trigger: %v", err)
+		}
+
+		cars := models.Cars{
+			{Make: "Honda", Model: "Civic", Color: "Blue", Category: "Sedan", Year: 2022},
+			{Make: "Reject", Model: "Civic", Color: "Blue", Category: "Sedan", Year: 2022},
+		}
+
+		// Act
+		err := repo.CreateBatch(cars)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error")
+		}
+
+		if cars[0].ID != "" || cars[0].Revision != 0 {
+			t.Fatalf("expected cars to be left untouched, got %+v", cars[0])
+		}
+
+		got, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("expected no car to be stored, got %+v", got)
+		}
+
+		found, err := repo.List(models.CarFilters{Query: "honda"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(found) != 0 {
+			t.Fatalf("expected no indexed terms, got %+v", found)
+		}
+	})
+}
+
+func TestSQLiteCarRepository_Update(t *testing.T) {
+	t.Run("should update existing car", func(t *testing.T) {
+		// Arrange
+		repo := newTestSQLiteRepository(t,
+			models.Car{ID: "1", Make: "Toyota", Model: "Corolla", Color: "Black", Category: "Sedan", Year: 2020},
+		)
+
+		updated := &models.Car{
+			ID:       "1",
+			Make:     "Honda",
+			Model:    "Civic",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2021,
+			Mileage:  u.Ptr(int64(42)),
+		}
+
+		// Act
+		_, err := repo.Update(updated)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stored, err := repo.Find(updated.ID, false)
+		if err != nil {
+			t.Fatalf("expected updated car to exist in repository: %v", err)
+		}
+
+		if !reflect.DeepEqual(stored, *updated) {
+			t.Fatalf("expected stored car %+v, got %+v", *updated, stored)
+		}
+	})
+
+	t.Run("should return error when car does not exist", func(t *testing.T) {
+		// Arrange
+		repo := newTestSQLiteRepository(t)
+
+		// Act
+		_, err := repo.Update(&models.Car{ID: "missing-id", Make: "Mazda", Model: "3"})
+
+		// Assert
+		if !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected ErrCarNotFound, got %v", err)
+		}
+	})
+}
+
+func TestSQLiteCarRepository_Delete(t *testing.T) {
+	t.Run("should delete existing car", func(t *testing.T) {
+		// Arrange
+		repo := newTestSQLiteRepository(t,
+			models.Car{ID: "1", Make: "Toyota", Model: "Corolla", Color: "Black", Category: "Sedan", Year: 2020},
+		)
+
+		// Act
+		_, err := repo.Delete("1", 0)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := repo.Find("1", false); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected car to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("should return error when car does not exist", func(t *testing.T) {
+		// Arrange
+		repo := newTestSQLiteRepository(t)
+
+		// Act
+		_, err := repo.Delete("missing-id", 0)
+
+		// Assert
+		if !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected ErrCarNotFound, got %v", err)
+		}
+	})
+}
+
+func TestNewSQLiteCarRepository_IndexesExistingCars(t *testing.T) {
+	// Arrange
+	db, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	if err := Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	if _, err := db.Exec(
+		`INSERT INTO cars (` + carColumns + `) VALUES ('1', 'Toyota', 'Rav4', 'Red', 'SUV', 2018, 'XSE', NULL, NULL, 1, NULL)`,
+	); err != nil {
+		t.Fatalf("insert car: %v", err)
+	}
+
+	// Act
+	repo, err := NewSQLiteCarRepository(db, nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+
+	// Assert
+	got, err := repo.List(models.CarFilters{Query: "red rav4"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 1 || got[0].ID != "1" {
+		t.Fatalf("expected car 1 to be found by search, got %+v", got)
+	}
+}
+
+func TestMigrate_NamesCatalogAfterMostFrequentSpelling(t *testing.T) {
+	// Arrange
+	db, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL)`); err != nil {
+		t.Fatalf("create schema_migrations: %v", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.version >= 8 {
+			break
+		}
+		if err := applyMigration(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The catalog of the earlier schema is named after the first car.
+	for i, name := range []string{"ford", "Ford", "FORD", "Ford"} {
+		if _, err := db.Exec(
+			`INSERT INTO cars (`+carColumns+`) VALUES (?, ?, 'f10', 'Red', 'Truck', 2010, NULL, NULL, NULL, 1, NULL)`,
+			i, name,
+		); err != nil {
+			t.Fatalf("insert car: %v", err)
+		}
+	}
+
+	// Act
+	repo, err := NewSQLiteCarRepository(db, nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+
+	// Assert
+	makes, err := repo.Makes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []models.Facet[string]{{Value: "Ford", Count: 4}}
+	if !reflect.DeepEqual(makes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, makes)
+	}
+}
+
+// errPlanned stops a query once planQuerier has recorded its plan.
+var errPlanned = errors.New("query planned")
+
+// planQuerier records the plan SQLite chooses for each query instead of
+// running it.
+type planQuerier struct {
+	*sql.DB
+	plan []string
+}
+
+func (p *planQuerier) Query(query string, args ...any) (*sql.Rows, error) {
+	rows, err := p.DB.Query(`EXPLAIN QUERY PLAN `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, err
+		}
+		p.plan = append(p.plan, detail)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errPlanned
+}
+
+func TestSQLiteCarRepository_ListPlan(t *testing.T) {
+	tCases := []struct {
+		name    string
+		filters models.CarFilters
+	}{
+		{
+			name:    "should walk the primary key for the default page",
+			filters: models.CarFilters{Limit: 100},
+		},
+		{
+			name: "should walk the price index for a price keyset page",
+			filters: models.CarFilters{
+				Sort:  []models.CarSort{{Field: models.SortByPrice}},
+				After: &models.CarCursor{ID: "A1", Sort: []models.CarSort{{Field: models.SortByPrice}}, Price: u.Ptr(int64(1999900))},
+				Limit: 100,
+			},
+		},
+		{
+			name: "should walk the mileage index for a mileage keyset page",
+			filters: models.CarFilters{
+				Sort:  []models.CarSort{{Field: models.SortByMileage}},
+				After: &models.CarCursor{ID: "A1", Sort: []models.CarSort{{Field: models.SortByMileage}}, Mileage: u.Ptr(int64(120123))},
+				Limit: 100,
+			},
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := newTestSQLiteRepository(t)
+			q := &planQuerier{DB: repo.db}
+
+			// Act
+			_, err := listCars(q, tc.filters)
+
+			// Assert
+			if !errors.Is(err, errPlanned) {
+				t.Fatalf("expected the query to be planned, got %v", err)
+			}
+
+			plan := strings.Join(q.plan, "\n")
+			if strings.Contains(plan, "TEMP B-TREE") || strings.Contains(plan, "idx_cars_deleted_at") {
+				t.Fatalf("expected live cars to be read in order without a sort, got plan:\n%s", plan)
+			}
+		})
+	}
+}
+
+func TestSQLiteCarRepository_SearchPlan(t *testing.T) {
+	// Arrange
+	repo := newTestSQLiteRepository(t)
+	q := &planQuerier{DB: repo.db}
+
+	// Act
+	_, err := listCars(q, models.CarFilters{Query: "toy"})
+
+	// Assert
+	if !errors.Is(err, errPlanned) {
+		t.Fatalf("expected the query to be planned, got %v", err)
+	}
+
+	plan := strings.Join(q.plan, "\n")
+	if !strings.Contains(plan, "SEARCH car_terms USING INDEX sqlite_autoindex_car_terms_1 (term>? AND term<?)") {
+		t.Fatalf("expected prefixes to be sought in the primary key, got plan:\n%s", plan)
+	}
+}
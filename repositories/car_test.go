@@ -3,8 +3,12 @@ package repositories
 import (
 	"cars/models"
 	e "cars/pkg/errors"
+	"cars/pkg/idgen"
 	u "cars/pkg/utils"
 	"errors"
+	"fmt"
+	"reflect"
+	"slices"
 	"testing"
 )
 
@@ -24,7 +28,7 @@ func TestDefaultCarRepository_Find(t *testing.T) {
 		}
 
 		// Act
-		got, err := repo.Find(expected.ID)
+		got, err := repo.Find(expected.ID, false)
 
 		// Assert
 		if err != nil {
@@ -43,7 +47,7 @@ func TestDefaultCarRepository_Find(t *testing.T) {
 		}
 
 		// Act
-		_, err := repo.Find("missing-id")
+		_, err := repo.Find("missing-id", false)
 
 		// Assert
 		if err == nil {
@@ -85,7 +89,7 @@ func TestDefaultCarRepository_List(t *testing.T) {
 		},
 	}
 
-	repo := NewCarRepository(cars)
+	repo := NewCarRepository(cars, nil)
 
 	tests := []struct {
 		name     string
@@ -99,63 +103,63 @@ func TestDefaultCarRepository_List(t *testing.T) {
 		},
 		{
 			name:     "Filter by make",
-			filters:  models.CarFilters{Make: "Toyota"},
+			filters:  models.CarFilters{Make: []string{"Toyota"}},
 			expected: []string{"2", "3"},
 		},
 		{
 			name:     "Filter by model",
-			filters:  models.CarFilters{Model: "Bronco"},
+			filters:  models.CarFilters{Model: []string{"Bronco"}},
 			expected: []string{"4"},
 		},
 		{
 			name:     "Filter by year",
-			filters:  models.CarFilters{Year: u.Ptr(2010)},
+			filters:  models.CarFilters{Year: []int{2010}},
 			expected: []string{"1"},
 		},
 		{
 			name: "Filter by make and model",
 			filters: models.CarFilters{
-				Make:  "Ford",
-				Model: "F10",
+				Make:  []string{"Ford"},
+				Model: []string{"F10"},
 			},
 			expected: []string{"1"},
 		},
 		{
 			name: "Filter by make and year",
 			filters: models.CarFilters{
-				Make: "Toyota",
-				Year: u.Ptr(2019),
+				Make: []string{"Toyota"},
+				Year: []int{2019},
 			},
 			expected: []string{"2"},
 		},
 		{
 			name: "Filter by model and year",
 			filters: models.CarFilters{
-				Model: "Rav4",
-				Year:  u.Ptr(2018),
+				Model: []string{"Rav4"},
+				Year:  []int{2018},
 			},
 			expected: []string{"3"},
 		},
 		{
 			name: "Filter by make, model and year",
 			filters: models.CarFilters{
-				Make:  "Ford",
-				Model: "Bronco",
-				Year:  u.Ptr(2022),
+				Make:  []string{"Ford"},
+				Model: []string{"Bronco"},
+				Year:  []int{2022},
 			},
 			expected: []string{"4"},
 		},
 		{
 			name: "Case-insensitive filtering",
 			filters: models.CarFilters{
-				Make:  "tOyOtA",
-				Model: "cAmRy",
+				Make:  []string{"tOyOtA"},
+				Model: []string{"cAmRy"},
 			},
 			expected: []string{"2"},
 		},
 		{
 			name:     "No match, returns empty list",
-			filters:  models.CarFilters{Make: "BMW"},
+			filters:  models.CarFilters{Make: []string{"BMW"}},
 			expected: []string{},
 		},
 	}
@@ -188,10 +192,83 @@ func TestDefaultCarRepository_List(t *testing.T) {
 	}
 }
 
+func Test_firstCars(t *testing.T) {
+	cars := make(models.Cars, 50)
+	for i := range cars {
+		cars[i] = models.Car{ID: fmt.Sprintf("%02d", (i*17)%50), Year: 2000 + i%5}
+	}
+	byYear := func(a, b models.Car) int {
+		return models.CompareCars(a, b, []models.CarSort{{Field: models.SortByYear, Desc: true}})
+	}
+
+	expected := slices.Clone(cars)
+	slices.SortFunc(expected, byYear)
+
+	tests := []struct {
+		name     string
+		limit    int
+		expected models.Cars
+	}{
+		{name: "should sort every car without a limit", limit: 0, expected: expected},
+		{name: "should keep the first cars up to the limit", limit: 7, expected: expected[:7]},
+		{name: "should keep every car under a larger limit", limit: 80, expected: expected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := firstCars(slices.Values(cars), tt.limit, byYear)
+
+			// Assert
+			if !slices.Equal(ids(got), ids(tt.expected)) {
+				t.Fatalf("expected %v, got %v", ids(tt.expected), ids(got))
+			}
+		})
+	}
+}
+
+func ids(cars models.Cars) []string {
+	out := make([]string, len(cars))
+	for i, car := range cars {
+		out[i] = car.ID
+	}
+	return out
+}
+
+func TestDefaultCarRepository_Makes(t *testing.T) {
+	t.Run("should name a mixed-case seed after its most frequent spelling", func(t *testing.T) {
+		// Arrange
+		cars := make(map[string]models.Car)
+		for i, name := range []string{"ford", "Ford", "FORD", "Ford"} {
+			id := fmt.Sprint(i)
+			cars[id] = models.Car{ID: id, Make: name, Model: "F10"}
+		}
+
+		// Map iteration order varies, so the seed is stored in several orders.
+		for range 10 {
+			repo := NewCarRepository(cars, nil)
+
+			// Act
+			makes, err := repo.Makes()
+
+			// Assert
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := []models.Facet[string]{{Value: "Ford", Count: 4}}
+			if !reflect.DeepEqual(makes, expected) {
+				t.Fatalf("expected %+v, got %+v", expected, makes)
+			}
+		}
+	})
+}
+
 func TestDefaultCarRepository_Create(t *testing.T) {
 	// Arrange
 	repo := &DefaultCarRepository{
 		cars: map[string]models.Car{},
+		ids:  idgen.NewHex(),
 	}
 
 	car := &models.Car{
@@ -244,7 +321,7 @@ func TestDefaultCarRepository_Update(t *testing.T) {
 		}
 
 		// Act
-		err := repo.Update(updated)
+		_, err := repo.Update(updated)
 
 		// Assert
 		if err != nil {
@@ -275,7 +352,7 @@ func TestDefaultCarRepository_Update(t *testing.T) {
 		}
 
 		// Act
-		err := repo.Update(car)
+		_, err := repo.Update(car)
 
 		// Assert
 		if err == nil {
@@ -289,7 +366,7 @@ func TestDefaultCarRepository_Update(t *testing.T) {
 }
 
 func TestDefaultCarRepository_Delete(t *testing.T) {
-	t.Run("should delete existing car", func(t *testing.T) {
+	t.Run("should mark existing car as deleted", func(t *testing.T) {
 		// Arrange
 		car := models.Car{
 			ID:    "1",
@@ -304,19 +381,24 @@ func TestDefaultCarRepository_Delete(t *testing.T) {
 		}
 
 		// Act
-		err := repo.Delete(car.ID)
+		_, err := repo.Delete(car.ID, 0)
 
 		// Assert
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		if _, exists := repo.cars[car.ID]; exists {
-			t.Fatal("expected car to be deleted from repository")
+		stored, exists := repo.cars[car.ID]
+		if !exists {
+			t.Fatal("expected car to be kept in repository")
+		}
+
+		if stored.DeletedAt == nil {
+			t.Fatal("expected car to be marked as deleted")
 		}
 
-		if len(repo.cars) != 0 {
-			t.Fatalf("expected repository to be empty, got %d items", len(repo.cars))
+		if _, err := repo.Find(car.ID, false); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected deleted car to be hidden, got %v", err)
 		}
 	})
 
@@ -327,7 +409,7 @@ func TestDefaultCarRepository_Delete(t *testing.T) {
 		}
 
 		// Act
-		err := repo.Delete("missing-id")
+		_, err := repo.Delete("missing-id", 0)
 
 		// Assert
 		if err == nil {
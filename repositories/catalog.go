@@ -0,0 +1,140 @@
+package repositories
+
+import (
+	"cars/models"
+	"cars/pkg/utils"
+	"slices"
+)
+
+// catalog counts live cars per make, and per model of each make, so that
+// distinct values can be listed without scanning every car. It is updated
+// as cars are stored and removed; deleted cars are not counted.
+//
+// Names are grouped ignoring ASCII case, like the SQLite backend does with
+// lower(). A group is reported under the most frequent spelling of its
+// cars (see models.Spellings.Name), and disappears with its last car.
+type catalog struct {
+	makes map[string]*catalogMake
+}
+
+// catalogMake is a make of the catalog and its models.
+type catalogMake struct {
+	catalogEntry
+	models map[string]*catalogEntry
+}
+
+// catalogEntry is a group of names and the number of cars spelling it each
+// way.
+type catalogEntry struct {
+	spellings models.Spellings
+	count     int
+}
+
+// add counts car in its make and model, unless it is deleted.
+func (c *catalog) add(car models.Car) {
+	if car.Deleted() {
+		return
+	}
+	if c.makes == nil {
+		c.makes = make(map[string]*catalogMake)
+	}
+
+	m, ok := c.makes[catalogKey(car.Make)]
+	if !ok {
+		m = &catalogMake{
+			catalogEntry: catalogEntry{spellings: make(models.Spellings)},
+			models:       make(map[string]*catalogEntry),
+		}
+		c.makes[catalogKey(car.Make)] = m
+	}
+	m.add(car.Make)
+
+	model, ok := m.models[catalogKey(car.Model)]
+	if !ok {
+		model = &catalogEntry{spellings: make(models.Spellings)}
+		m.models[catalogKey(car.Model)] = model
+	}
+	model.add(car.Model)
+}
+
+// remove uncounts car from its make and model. Deleted cars, and cars that
+// were never added, are ignored.
+func (c *catalog) remove(car models.Car) {
+	if car.Deleted() {
+		return
+	}
+
+	m, ok := c.makes[catalogKey(car.Make)]
+	if !ok {
+		return
+	}
+	model, ok := m.models[catalogKey(car.Model)]
+	if !ok {
+		return
+	}
+
+	if m.remove(car.Make); m.count == 0 {
+		delete(c.makes, catalogKey(car.Make))
+	}
+	if model.remove(car.Model); model.count == 0 {
+		delete(m.models, catalogKey(car.Model))
+	}
+}
+
+// listMakes returns every make, ordered by name ignoring case.
+func (c *catalog) listMakes() []models.Facet[string] {
+	out := make([]models.Facet[string], 0, len(c.makes))
+	for _, key := range sortedKeys(c.makes) {
+		out = append(out, c.makes[key].facet())
+	}
+	return out
+}
+
+// listModels returns the models of the make with the given name, ordered
+// by name ignoring case. It reports false if no car has that make.
+func (c *catalog) listModels(name string) ([]models.Facet[string], bool) {
+	m, ok := c.makes[catalogKey(name)]
+	if !ok {
+		return nil, false
+	}
+
+	out := make([]models.Facet[string], 0, len(m.models))
+	for _, key := range sortedKeys(m.models) {
+		out = append(out, m.models[key].facet())
+	}
+	return out, true
+}
+
+// add counts a car spelling the entry's name as name.
+func (e *catalogEntry) add(name string) {
+	e.spellings[name]++
+	e.count++
+}
+
+// remove uncounts a car spelling the entry's name as name.
+func (e *catalogEntry) remove(name string) {
+	if e.spellings[name]--; e.spellings[name] == 0 {
+		delete(e.spellings, name)
+	}
+	e.count--
+}
+
+func (e catalogEntry) facet() models.Facet[string] {
+	return models.Facet[string]{Value: e.spellings.Name(), Count: e.count}
+}
+
+// catalogKey returns the key a name is grouped under: the name with ASCII
+// letters lowercased.
+func catalogKey(name string) string {
+	return utils.Fold(name)
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
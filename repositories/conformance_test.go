@@ -0,0 +1,73 @@
+package repositories_test
+
+import (
+	"cars/repositories"
+	"cars/repositories/repotest"
+	"testing"
+)
+
+func TestDefaultCarRepository_Conformance(t *testing.T) {
+	repotest.TestCarRepository(t, func(t *testing.T) repositories.CarRepository {
+		return repositories.NewCarRepository(nil, nil)
+	})
+}
+
+func TestSQLiteCarRepository_Conformance(t *testing.T) {
+	repotest.TestCarRepository(t, func(t *testing.T) repositories.CarRepository {
+		db, err := repositories.OpenSQLite(":memory:")
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		repo, err := repositories.NewSQLiteCarRepository(db, nil)
+		if err != nil {
+			t.Fatalf("create repository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestDefaultCarRepository_IDGeneration(t *testing.T) {
+	repotest.TestIDGeneration(t, func(t *testing.T, ids repositories.IDGenerator) repositories.CarRepository {
+		return repositories.NewCarRepository(nil, ids)
+	})
+}
+
+func TestSQLiteCarRepository_IDGeneration(t *testing.T) {
+	repotest.TestIDGeneration(t, func(t *testing.T, ids repositories.IDGenerator) repositories.CarRepository {
+		db, err := repositories.OpenSQLite(":memory:")
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		repo, err := repositories.NewSQLiteCarRepository(db, ids)
+		if err != nil {
+			t.Fatalf("create repository: %v", err)
+		}
+		return repo
+	})
+}
+
+func TestDefaultAuditStore_Conformance(t *testing.T) {
+	repotest.TestAuditStore(t, func(t *testing.T) repositories.AuditStore {
+		return repositories.NewAuditStore()
+	})
+}
+
+func TestSQLiteAuditStore_Conformance(t *testing.T) {
+	repotest.TestAuditStore(t, func(t *testing.T) repositories.AuditStore {
+		db, err := repositories.OpenSQLite(":memory:")
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		store, err := repositories.NewSQLiteAuditStore(db)
+		if err != nil {
+			t.Fatalf("create audit store: %v", err)
+		}
+		return store
+	})
+}
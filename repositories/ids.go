@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"cars/pkg/config"
+	e "cars/pkg/errors"
+	"cars/pkg/idgen"
+	"fmt"
+)
+
+// maxIDAttempts is how many IDs are generated for a new car before
+// giving up when every one of them is already taken.
+const maxIDAttempts = 5
+
+// IDGenerator generates the IDs of the cars created by a repository.
+//
+// Generated IDs must match models.CarIDPattern. They need not be unique:
+// repositories check every ID against the stored cars and generate another
+// one on collision.
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// NewIDGenerator returns the IDGenerator for the given strategy, one of
+// config.IDStrategyHex, config.IDStrategyUUIDv7 or config.IDStrategyULID.
+func NewIDGenerator(strategy string) (IDGenerator, error) {
+	switch strategy {
+	case config.IDStrategyHex:
+		return idgen.NewHex(), nil
+	case config.IDStrategyUUIDv7:
+		return idgen.NewUUIDv7(), nil
+	case config.IDStrategyULID:
+		return idgen.NewULID(), nil
+	default:
+		return nil, fmt.Errorf("unknown ID strategy %q", strategy)
+	}
+}
+
+// newID generates an ID with gen for which taken reports false, trying at
+// most maxIDAttempts IDs before returning ErrIDCollision.
+func newID(gen IDGenerator, taken func(id string) (bool, error)) (string, error) {
+	for range maxIDAttempts {
+		id, err := gen.NewID()
+		if err != nil {
+			return "", err
+		}
+
+		exists, err := taken(id)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return id, nil
+		}
+	}
+	return "", e.ErrIDCollision
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"cars/pkg/config"
+	"cars/pkg/idgen"
+	"reflect"
+	"testing"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		expected IDGenerator
+	}{
+		{name: "should return a hex generator", strategy: config.IDStrategyHex, expected: idgen.NewHex()},
+		{name: "should return a UUIDv7 generator", strategy: config.IDStrategyUUIDv7, expected: idgen.NewUUIDv7()},
+		{name: "should return a ULID generator", strategy: config.IDStrategyULID, expected: idgen.NewULID()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got, err := NewIDGenerator(tt.strategy)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.expected) {
+				t.Fatalf("expected %T, got %T", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("should reject an unknown strategy", func(t *testing.T) {
+		// Act
+		_, err := NewIDGenerator("sequential")
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+	})
+}
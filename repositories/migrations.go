@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles holds the versioned SQL schema migrations.
+//
+// Each file is named "<version>_<description>.sql" and is applied
+// exactly once, in ascending version order.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration represents a single versioned schema change.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// Migrate applies all pending schema migrations to the given database.
+//
+// Applied versions are recorded in the schema_migrations table, so calling
+// Migrate on an up-to-date database is a no-op. Each migration runs inside
+// its own transaction.
+func Migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name    TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	pending, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range pending {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns the set of migration versions already applied.
+func appliedMigrations(db *sql.DB) (map[int]bool, error) {
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// loadMigrations reads the embedded migration files sorted by version.
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", name, err)
+		}
+
+		content, err := migrationFiles.ReadFile("migrations/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].version < list[j].version
+	})
+	return list, nil
+}
+
+// applyMigration executes a migration and records it as applied.
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("apply migration %s: %w", m.name, err)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+		m.version, m.name,
+	); err != nil {
+		return fmt.Errorf("record migration %s: %w", m.name, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,14 @@
+CREATE TABLE cars (
+    id       TEXT PRIMARY KEY,
+    make     TEXT NOT NULL,
+    model    TEXT NOT NULL,
+    color    TEXT NOT NULL,
+    category TEXT NOT NULL,
+    year     INTEGER NOT NULL,
+    package  TEXT,
+    mileage  INTEGER,
+    price    INTEGER
+);
+
+CREATE INDEX idx_cars_make_model ON cars (make COLLATE NOCASE, model COLLATE NOCASE);
+CREATE INDEX idx_cars_year ON cars (year);
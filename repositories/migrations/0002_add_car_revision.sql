@@ -0,0 +1 @@
+ALTER TABLE cars ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+CREATE INDEX idx_cars_price ON cars (price);
+CREATE INDEX idx_cars_mileage ON cars (mileage);
@@ -0,0 +1,8 @@
+CREATE TABLE car_terms (
+    car_id TEXT NOT NULL,
+    term   TEXT NOT NULL,
+    weight REAL NOT NULL,
+    PRIMARY KEY (term, car_id)
+);
+
+CREATE INDEX idx_car_terms_car_id ON car_terms (car_id);
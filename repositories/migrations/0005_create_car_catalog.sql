@@ -0,0 +1,51 @@
+-- Distinct makes, and models of each make, with their car counts. Names
+-- are grouped by lower(), which folds ASCII case only; each group keeps
+-- the spelling of the first car stored with it. The triggers below keep
+-- the counts in sync with the cars table.
+CREATE TABLE car_makes (
+    key   TEXT NOT NULL PRIMARY KEY,
+    name  TEXT NOT NULL,
+    count INTEGER NOT NULL
+);
+
+CREATE TABLE car_models (
+    make_key TEXT NOT NULL,
+    key      TEXT NOT NULL,
+    name     TEXT NOT NULL,
+    count    INTEGER NOT NULL,
+    PRIMARY KEY (make_key, key)
+);
+
+INSERT INTO car_makes (key, name, count)
+SELECT lower(make), min(make), count(*) FROM cars GROUP BY lower(make);
+
+INSERT INTO car_models (make_key, key, name, count)
+SELECT lower(make), lower(model), min(model), count(*) FROM cars GROUP BY lower(make), lower(model);
+
+CREATE TRIGGER car_catalog_insert AFTER INSERT ON cars
+BEGIN
+    INSERT INTO car_makes (key, name, count) VALUES (lower(NEW.make), NEW.make, 1)
+        ON CONFLICT (key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_models (make_key, key, name, count) VALUES (lower(NEW.make), lower(NEW.model), NEW.model, 1)
+        ON CONFLICT (make_key, key) DO UPDATE SET count = count + 1;
+END;
+
+CREATE TRIGGER car_catalog_delete AFTER DELETE ON cars
+BEGIN
+    UPDATE car_makes SET count = count - 1 WHERE key = lower(OLD.make);
+    DELETE FROM car_makes WHERE key = lower(OLD.make) AND count = 0;
+    UPDATE car_models SET count = count - 1 WHERE make_key = lower(OLD.make) AND key = lower(OLD.model);
+    DELETE FROM car_models WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND count = 0;
+END;
+
+CREATE TRIGGER car_catalog_update AFTER UPDATE OF make, model ON cars
+BEGIN
+    UPDATE car_makes SET count = count - 1 WHERE key = lower(OLD.make);
+    DELETE FROM car_makes WHERE key = lower(OLD.make) AND count = 0;
+    UPDATE car_models SET count = count - 1 WHERE make_key = lower(OLD.make) AND key = lower(OLD.model);
+    DELETE FROM car_models WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND count = 0;
+    INSERT INTO car_makes (key, name, count) VALUES (lower(NEW.make), NEW.make, 1)
+        ON CONFLICT (key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_models (make_key, key, name, count) VALUES (lower(NEW.make), lower(NEW.model), NEW.model, 1)
+        ON CONFLICT (make_key, key) DO UPDATE SET count = count + 1;
+END;
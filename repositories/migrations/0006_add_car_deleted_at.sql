@@ -0,0 +1,45 @@
+-- Soft deletes: deleted_at holds the time a car was deleted, in Unix
+-- nanoseconds, and is NULL while the car is live. Deleted cars are not
+-- counted in the catalog, so its triggers are recreated to skip them.
+ALTER TABLE cars ADD COLUMN deleted_at INTEGER;
+
+CREATE INDEX idx_cars_deleted_at ON cars (deleted_at);
+
+DROP TRIGGER car_catalog_insert;
+DROP TRIGGER car_catalog_delete;
+DROP TRIGGER car_catalog_update;
+
+CREATE TRIGGER car_catalog_insert AFTER INSERT ON cars
+WHEN NEW.deleted_at IS NULL
+BEGIN
+    INSERT INTO car_makes (key, name, count) VALUES (lower(NEW.make), NEW.make, 1)
+        ON CONFLICT (key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_models (make_key, key, name, count) VALUES (lower(NEW.make), lower(NEW.model), NEW.model, 1)
+        ON CONFLICT (make_key, key) DO UPDATE SET count = count + 1;
+END;
+
+CREATE TRIGGER car_catalog_delete AFTER DELETE ON cars
+WHEN OLD.deleted_at IS NULL
+BEGIN
+    UPDATE car_makes SET count = count - 1 WHERE key = lower(OLD.make);
+    DELETE FROM car_makes WHERE key = lower(OLD.make) AND count = 0;
+    UPDATE car_models SET count = count - 1 WHERE make_key = lower(OLD.make) AND key = lower(OLD.model);
+    DELETE FROM car_models WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND count = 0;
+END;
+
+-- A single trigger uncounts the old row before counting the new one, like
+-- an update does in memory, so that a group emptied and refilled by the
+-- same update takes the new spelling.
+CREATE TRIGGER car_catalog_update AFTER UPDATE OF make, model, deleted_at ON cars
+BEGIN
+    UPDATE car_makes SET count = count - 1 WHERE key = lower(OLD.make) AND OLD.deleted_at IS NULL;
+    DELETE FROM car_makes WHERE key = lower(OLD.make) AND count = 0;
+    UPDATE car_models SET count = count - 1
+        WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND OLD.deleted_at IS NULL;
+    DELETE FROM car_models WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND count = 0;
+    INSERT INTO car_makes (key, name, count) SELECT lower(NEW.make), NEW.make, 1 WHERE NEW.deleted_at IS NULL
+        ON CONFLICT (key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_models (make_key, key, name, count)
+        SELECT lower(NEW.make), lower(NEW.model), NEW.model, 1 WHERE NEW.deleted_at IS NULL
+        ON CONFLICT (make_key, key) DO UPDATE SET count = count + 1;
+END;
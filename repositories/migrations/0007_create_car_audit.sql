@@ -0,0 +1,27 @@
+-- Append-only audit trail of the changes made to cars. The snapshots of
+-- the car before and after each change are stored as JSON; before is NULL
+-- for a creation. Entries are not tied to the cars table, so the history
+-- of a car outlives it, and the triggers below keep them immutable.
+CREATE TABLE car_audit (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    car_id     TEXT NOT NULL,
+    revision   INTEGER NOT NULL,
+    action     TEXT NOT NULL,
+    actor      TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    time       INTEGER NOT NULL,
+    before     TEXT,
+    after      TEXT NOT NULL
+);
+
+CREATE INDEX car_audit_car_id ON car_audit (car_id, seq);
+
+CREATE TRIGGER car_audit_no_update BEFORE UPDATE ON car_audit
+BEGIN
+    SELECT RAISE(ABORT, 'audit entries are immutable');
+END;
+
+CREATE TRIGGER car_audit_no_delete BEFORE DELETE ON car_audit
+BEGIN
+    SELECT RAISE(ABORT, 'audit entries are immutable');
+END;
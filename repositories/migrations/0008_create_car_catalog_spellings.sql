@@ -0,0 +1,128 @@
+-- Catalog groups were named after the first car stored with them, which
+-- depends on the order cars were written in. They are now named after
+-- their most frequent spelling, ties going to the smallest in byte order,
+-- as in memory. The spelling tables count the live cars of each group by
+-- spelling, so that the triggers can rename a group from its own few rows
+-- rather than from the cars table.
+CREATE TABLE car_make_spellings (
+    key      TEXT NOT NULL,
+    spelling TEXT NOT NULL,
+    count    INTEGER NOT NULL,
+    PRIMARY KEY (key, spelling)
+);
+
+CREATE TABLE car_model_spellings (
+    make_key TEXT NOT NULL,
+    key      TEXT NOT NULL,
+    spelling TEXT NOT NULL,
+    count    INTEGER NOT NULL,
+    PRIMARY KEY (make_key, key, spelling)
+);
+
+INSERT INTO car_make_spellings (key, spelling, count)
+SELECT lower(make), make, count(*) FROM cars WHERE deleted_at IS NULL GROUP BY make;
+
+INSERT INTO car_model_spellings (make_key, key, spelling, count)
+SELECT lower(make), lower(model), model, count(*) FROM cars WHERE deleted_at IS NULL
+GROUP BY lower(make), model;
+
+UPDATE car_makes SET name = (
+    SELECT spelling FROM car_make_spellings WHERE key = car_makes.key
+    ORDER BY count DESC, spelling LIMIT 1
+);
+
+UPDATE car_models SET name = (
+    SELECT spelling FROM car_model_spellings WHERE make_key = car_models.make_key AND key = car_models.key
+    ORDER BY count DESC, spelling LIMIT 1
+);
+
+DROP TRIGGER car_catalog_insert;
+DROP TRIGGER car_catalog_delete;
+DROP TRIGGER car_catalog_update;
+
+CREATE TRIGGER car_catalog_insert AFTER INSERT ON cars
+WHEN NEW.deleted_at IS NULL
+BEGIN
+    INSERT INTO car_makes (key, name, count) VALUES (lower(NEW.make), NEW.make, 1)
+        ON CONFLICT (key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_make_spellings (key, spelling, count) VALUES (lower(NEW.make), NEW.make, 1)
+        ON CONFLICT (key, spelling) DO UPDATE SET count = count + 1;
+    UPDATE car_makes SET name = (
+        SELECT spelling FROM car_make_spellings WHERE key = car_makes.key
+        ORDER BY count DESC, spelling LIMIT 1
+    ) WHERE key = lower(NEW.make);
+
+    INSERT INTO car_models (make_key, key, name, count) VALUES (lower(NEW.make), lower(NEW.model), NEW.model, 1)
+        ON CONFLICT (make_key, key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_model_spellings (make_key, key, spelling, count)
+        VALUES (lower(NEW.make), lower(NEW.model), NEW.model, 1)
+        ON CONFLICT (make_key, key, spelling) DO UPDATE SET count = count + 1;
+    UPDATE car_models SET name = (
+        SELECT spelling FROM car_model_spellings WHERE make_key = car_models.make_key AND key = car_models.key
+        ORDER BY count DESC, spelling LIMIT 1
+    ) WHERE make_key = lower(NEW.make) AND key = lower(NEW.model);
+END;
+
+CREATE TRIGGER car_catalog_delete AFTER DELETE ON cars
+WHEN OLD.deleted_at IS NULL
+BEGIN
+    UPDATE car_makes SET count = count - 1 WHERE key = lower(OLD.make);
+    DELETE FROM car_makes WHERE key = lower(OLD.make) AND count = 0;
+    UPDATE car_make_spellings SET count = count - 1 WHERE key = lower(OLD.make) AND spelling = OLD.make;
+    DELETE FROM car_make_spellings WHERE key = lower(OLD.make) AND spelling = OLD.make AND count = 0;
+    UPDATE car_makes SET name = (
+        SELECT spelling FROM car_make_spellings WHERE key = car_makes.key
+        ORDER BY count DESC, spelling LIMIT 1
+    ) WHERE key = lower(OLD.make);
+
+    UPDATE car_models SET count = count - 1 WHERE make_key = lower(OLD.make) AND key = lower(OLD.model);
+    DELETE FROM car_models WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND count = 0;
+    UPDATE car_model_spellings SET count = count - 1
+        WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND spelling = OLD.model;
+    DELETE FROM car_model_spellings
+        WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND spelling = OLD.model AND count = 0;
+    UPDATE car_models SET name = (
+        SELECT spelling FROM car_model_spellings WHERE make_key = car_models.make_key AND key = car_models.key
+        ORDER BY count DESC, spelling LIMIT 1
+    ) WHERE make_key = lower(OLD.make) AND key = lower(OLD.model);
+END;
+
+-- As before, the old row is uncounted before the new one is counted; the
+-- groups of both are renamed once their spellings are up to date.
+CREATE TRIGGER car_catalog_update AFTER UPDATE OF make, model, deleted_at ON cars
+BEGIN
+    UPDATE car_makes SET count = count - 1 WHERE key = lower(OLD.make) AND OLD.deleted_at IS NULL;
+    DELETE FROM car_makes WHERE key = lower(OLD.make) AND count = 0;
+    UPDATE car_make_spellings SET count = count - 1
+        WHERE key = lower(OLD.make) AND spelling = OLD.make AND OLD.deleted_at IS NULL;
+    DELETE FROM car_make_spellings WHERE key = lower(OLD.make) AND spelling = OLD.make AND count = 0;
+    UPDATE car_models SET count = count - 1
+        WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND OLD.deleted_at IS NULL;
+    DELETE FROM car_models WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND count = 0;
+    UPDATE car_model_spellings SET count = count - 1
+        WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND spelling = OLD.model
+            AND OLD.deleted_at IS NULL;
+    DELETE FROM car_model_spellings
+        WHERE make_key = lower(OLD.make) AND key = lower(OLD.model) AND spelling = OLD.model AND count = 0;
+
+    INSERT INTO car_makes (key, name, count) SELECT lower(NEW.make), NEW.make, 1 WHERE NEW.deleted_at IS NULL
+        ON CONFLICT (key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_make_spellings (key, spelling, count)
+        SELECT lower(NEW.make), NEW.make, 1 WHERE NEW.deleted_at IS NULL
+        ON CONFLICT (key, spelling) DO UPDATE SET count = count + 1;
+    INSERT INTO car_models (make_key, key, name, count)
+        SELECT lower(NEW.make), lower(NEW.model), NEW.model, 1 WHERE NEW.deleted_at IS NULL
+        ON CONFLICT (make_key, key) DO UPDATE SET count = count + 1;
+    INSERT INTO car_model_spellings (make_key, key, spelling, count)
+        SELECT lower(NEW.make), lower(NEW.model), NEW.model, 1 WHERE NEW.deleted_at IS NULL
+        ON CONFLICT (make_key, key, spelling) DO UPDATE SET count = count + 1;
+
+    UPDATE car_makes SET name = (
+        SELECT spelling FROM car_make_spellings WHERE key = car_makes.key
+        ORDER BY count DESC, spelling LIMIT 1
+    ) WHERE key IN (lower(OLD.make), lower(NEW.make));
+    UPDATE car_models SET name = (
+        SELECT spelling FROM car_model_spellings WHERE make_key = car_models.make_key AND key = car_models.key
+        ORDER BY count DESC, spelling LIMIT 1
+    ) WHERE (make_key, key) IN (VALUES (lower(OLD.make), lower(OLD.model)), (lower(NEW.make), lower(NEW.model)));
+END;
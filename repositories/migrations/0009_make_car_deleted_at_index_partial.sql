@@ -0,0 +1,7 @@
+-- Nearly every car is live, so an index over all of deleted_at lets the
+-- planner pick it for "deleted_at IS NULL" listings, where it selects
+-- almost every row and forces a sort instead of walking the order by
+-- indexes. Only purging looks up deleted cars, so only they are indexed.
+DROP INDEX idx_cars_deleted_at;
+
+CREATE INDEX idx_cars_deleted_at ON cars (deleted_at) WHERE deleted_at IS NOT NULL;
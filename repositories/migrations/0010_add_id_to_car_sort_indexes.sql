@@ -0,0 +1,8 @@
+-- Listings sorted by price or mileage order ties by id. Indexing id after
+-- the sort column lets those pages, and the keyset pages after them, be
+-- read in index order instead of being sorted in a temporary b-tree.
+DROP INDEX idx_cars_price;
+DROP INDEX idx_cars_mileage;
+
+CREATE INDEX idx_cars_price ON cars (price, id);
+CREATE INDEX idx_cars_mileage ON cars (mileage, id);
@@ -0,0 +1,44 @@
+package repositories
+
+import (
+	"cars/data"
+	"cars/pkg/config"
+	"fmt"
+)
+
+// Open builds the CarRepository selected by the configuration, with the
+// AuditStore recording the changes made to its cars.
+//
+// New car IDs are generated with the configured strategy. The memory
+// backend is seeded with sample data and keeps the audit trail in memory;
+// the SQLite backend opens the configured database, applies pending
+// migrations and stores both in it.
+func Open(cfg config.Config) (CarRepository, AuditStore, error) {
+	ids, err := NewIDGenerator(cfg.IDStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.Storage {
+	case config.StorageMemory:
+		return NewCarRepository(data.Cars(), ids), NewAuditStore(), nil
+	case config.StorageSQLite:
+		db, err := OpenSQLite(cfg.SQLiteDSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open sqlite database: %w", err)
+		}
+
+		repo, err := NewSQLiteCarRepository(db, ids)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		audit, err := NewSQLiteAuditStore(db)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, audit, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage backend %q", cfg.Storage)
+	}
+}
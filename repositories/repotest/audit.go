@@ -0,0 +1,99 @@
+package repotest
+
+import (
+	"cars/models"
+	u "cars/pkg/utils"
+	"cars/repositories"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// AuditFactory returns a fresh, empty AuditStore for a single test.
+type AuditFactory func(t *testing.T) repositories.AuditStore
+
+// TestAuditStore runs the AuditStore conformance suite against stores
+// produced by newStore.
+func TestAuditStore(t *testing.T, newStore AuditFactory) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	created := models.Car{
+		ID: "car-1", Make: "Ford", Model: "F10", Package: u.Ptr("Base"),
+		Color: "Silver", Year: 2010, Category: "Truck",
+		Mileage: u.Ptr(int64(120123)), Price: u.Ptr(int64(1999900)), Revision: 1,
+	}
+	updated := created
+	updated.Color, updated.Package, updated.Revision = "Black", nil, 2
+	deleted := updated
+	deleted.DeletedAt, deleted.Revision = u.Ptr(at.Add(time.Hour)), 3
+
+	entries := []models.CarAuditEntry{
+		{CarID: "car-1", Revision: 1, Action: models.CarCreated, Actor: "alice", RequestID: "req-1", Time: at, After: created},
+		{CarID: "car-1", Revision: 2, Action: models.CarUpdated, Actor: "bob", Time: at.Add(time.Minute), Before: &created, After: updated},
+		{CarID: "car-1", Revision: 3, Action: models.CarDeleted, Actor: "bob", RequestID: "req-3", Time: at.Add(time.Hour), Before: &updated, After: deleted},
+	}
+
+	t.Run("should return the entries of a car in append order", func(t *testing.T) {
+		store := newStore(t)
+		other := models.CarAuditEntry{CarID: "car-2", Revision: 1, Action: models.CarCreated, Actor: "alice", Time: at, After: models.Car{ID: "car-2", Make: "Toyota", Revision: 1}}
+
+		if err := store.Append(entries[0], other); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := store.Append(entries[1:]...); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := store.History("car-1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, entries) {
+			t.Fatalf("expected %+v, got %+v", entries, got)
+		}
+
+		got, err = store.History("car-2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, []models.CarAuditEntry{other}) {
+			t.Fatalf("expected %+v, got %+v", other, got)
+		}
+	})
+
+	t.Run("should return an empty history for an unknown car", func(t *testing.T) {
+		store := newStore(t)
+
+		got, err := store.History("missing-id")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Fatalf("expected empty non-nil history, got %+v", got)
+		}
+	})
+
+	t.Run("should not let callers alter stored entries", func(t *testing.T) {
+		store := newStore(t)
+		before := created
+		entry := models.CarAuditEntry{CarID: "car-1", Revision: 2, Action: models.CarUpdated, Actor: "bob", Time: at, Before: &before, After: updated}
+
+		if err := store.Append(entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		before.Color = "Green"
+
+		got, err := store.History("car-1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got[0].Actor = "mallory"
+
+		again, err := store.History("car-1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if again[0].Actor != "bob" || again[0].Before.Color != "Silver" {
+			t.Fatalf("expected stored entry to be unchanged, got %+v", again[0])
+		}
+	})
+}
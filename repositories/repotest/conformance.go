@@ -0,0 +1,1854 @@
+// Package repotest provides conformance test suites for implementations
+// of repositories.CarRepository and repositories.AuditStore.
+//
+// Any backend can prove it honours the repository contract by running
+// the suite from its own tests:
+//
+//	func TestMyRepository(t *testing.T) {
+//		repotest.TestCarRepository(t, func(t *testing.T) repositories.CarRepository {
+//			return NewMyRepository(...)
+//		})
+//	}
+package repotest
+
+import (
+	"cars/models"
+	e "cars/pkg/errors"
+	u "cars/pkg/utils"
+	"cars/repositories"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns a fresh, empty CarRepository for a single test.
+//
+// Implementations can use t to register cleanup of any resources
+// (database handles, temporary files) they allocate.
+type Factory func(t *testing.T) repositories.CarRepository
+
+// TestCarRepository runs the full CarRepository conformance suite
+// against repositories produced by newRepo.
+//
+// Each subtest receives its own repository, so implementations do not
+// need to support resetting state between tests.
+func TestCarRepository(t *testing.T, newRepo Factory) {
+	t.Run("Find", func(t *testing.T) { testFind(t, newRepo) })
+	t.Run("List", func(t *testing.T) { testList(t, newRepo) })
+	t.Run("Each", func(t *testing.T) { testEach(t, newRepo) })
+	t.Run("Create", func(t *testing.T) { testCreate(t, newRepo) })
+	t.Run("Insert", func(t *testing.T) { testInsert(t, newRepo) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newRepo) })
+	t.Run("Patch", func(t *testing.T) { testPatch(t, newRepo) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newRepo) })
+	t.Run("Restore", func(t *testing.T) { testRestore(t, newRepo) })
+	t.Run("Purge", func(t *testing.T) { testPurge(t, newRepo) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newRepo) })
+	t.Run("Expr", func(t *testing.T) { testExpr(t, newRepo) })
+	t.Run("UpdateBatch", func(t *testing.T) { testUpdateBatch(t, newRepo) })
+	t.Run("DeleteBatch", func(t *testing.T) { testDeleteBatch(t, newRepo) })
+	t.Run("Catalog", func(t *testing.T) { testCatalog(t, newRepo) })
+	t.Run("Revision", func(t *testing.T) { testRevision(t, newRepo) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newRepo) })
+}
+
+// sampleCars returns a fixed inventory used to exercise filters.
+func sampleCars() models.Cars {
+	return models.Cars{
+		{
+			Make: "Ford", Model: "F10", Package: u.Ptr("Base"),
+			Color: "Silver", Year: 2010, Category: "Truck",
+			Mileage: u.Ptr(int64(120123)), Price: u.Ptr(int64(1999900)),
+		},
+		{
+			Make: "Toyota", Model: "Camry", Package: u.Ptr("SE"),
+			Color: "White", Year: 2019, Category: "Sedan",
+			Mileage: u.Ptr(int64(3999)), Price: u.Ptr(int64(2899000)),
+		},
+		{
+			Make: "Toyota", Model: "Rav4", Package: u.Ptr("XSE"),
+			Color: "Red", Year: 2018, Category: "SUV",
+			Mileage: u.Ptr(int64(24001)), Price: u.Ptr(int64(2275000)),
+		},
+		{
+			Make: "Ford", Model: "Bronco",
+			Color: "Burnt Orange", Year: 2022, Category: "SUV",
+		},
+	}
+}
+
+// seed creates the given cars and returns them with their generated IDs,
+// in the same order.
+func seed(t *testing.T, repo repositories.CarRepository, cars models.Cars) models.Cars {
+	t.Helper()
+
+	out := make(models.Cars, len(cars))
+	for i, car := range cars {
+		c := car
+		if err := repo.Create(&c); err != nil {
+			t.Fatalf("seed car %d: %v", i, err)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// facets is a list of distinct makes or models with their car counts.
+type facets = []models.Facet[string]
+
+// assertMakes fails the test unless repo lists exactly the expected makes.
+func assertMakes(t *testing.T, repo repositories.CarRepository, expected facets) {
+	t.Helper()
+
+	got, err := repo.Makes()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected makes %+v, got %+v", expected, got)
+	}
+}
+
+// assertModels fails the test unless repo lists exactly the expected
+// models of make.
+func assertModels(t *testing.T, repo repositories.CarRepository, make string, expected facets) {
+	t.Helper()
+
+	got, err := repo.Models(make)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected models of %s %+v, got %+v", make, expected, got)
+	}
+}
+
+// ids returns the sorted IDs of the given cars.
+func ids(cars models.Cars) []string {
+	out := make([]string, len(cars))
+	for i, car := range cars {
+		out[i] = car.ID
+	}
+	sort.Strings(out)
+	return out
+}
+
+// order returns the IDs of the given cars in the order they were listed.
+func order(cars models.Cars) []string {
+	out := make([]string, len(cars))
+	for i, car := range cars {
+		out[i] = car.ID
+	}
+	return out
+}
+
+func testFind(t *testing.T, newRepo Factory) {
+	t.Run("should return car when it exists", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		for _, expected := range stored {
+			got, err := repo.Find(expected.ID, false)
+			if err != nil {
+				t.Fatalf("unexpected error finding %s: %v", expected.ID, err)
+			}
+
+			if !reflect.DeepEqual(expected, got) {
+				t.Errorf("expected %+v, got %+v", expected, got)
+			}
+		}
+	})
+
+	t.Run("should wrap ErrCarNotFound when car does not exist", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.Find("missing-id", false)
+		if !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+}
+
+func testList(t *testing.T, newRepo Factory) {
+	t.Run("should return empty non-nil list when repository is empty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got == nil || len(got) != 0 {
+			t.Fatalf("expected empty list, got %#v", got)
+		}
+	})
+
+	repo := newRepo(t)
+	stored := seed(t, repo, sampleCars())
+	ford, camry, rav4, bronco := stored[0], stored[1], stored[2], stored[3]
+
+	tests := []struct {
+		name     string
+		filters  models.CarFilters
+		expected models.Cars
+	}{
+		{
+			name:     "no filters returns all",
+			filters:  models.CarFilters{},
+			expected: stored,
+		},
+		{
+			name:     "filter by make",
+			filters:  models.CarFilters{Make: []string{"Toyota"}},
+			expected: models.Cars{camry, rav4},
+		},
+		{
+			name:     "filter by model",
+			filters:  models.CarFilters{Model: []string{"Bronco"}},
+			expected: models.Cars{bronco},
+		},
+		{
+			name:     "filter by year",
+			filters:  models.CarFilters{Year: []int{2010}},
+			expected: models.Cars{ford},
+		},
+		{
+			name:     "filter by make and year",
+			filters:  models.CarFilters{Make: []string{"Toyota"}, Year: []int{2019}},
+			expected: models.Cars{camry},
+		},
+		{
+			name:     "filter by make, model and year",
+			filters:  models.CarFilters{Make: []string{"Ford"}, Model: []string{"Bronco"}, Year: []int{2022}},
+			expected: models.Cars{bronco},
+		},
+		{
+			name:     "make and model are case-insensitive",
+			filters:  models.CarFilters{Make: []string{"tOyOtA"}, Model: []string{"rAv4"}},
+			expected: models.Cars{rav4},
+		},
+		{
+			name:     "multiple makes match any of them",
+			filters:  models.CarFilters{Make: []string{"toyota", "FORD"}},
+			expected: stored,
+		},
+		{
+			name:     "categories from several makes",
+			filters:  models.CarFilters{Make: []string{"Ford", "Toyota"}, Category: []string{"SUV", "Truck"}},
+			expected: models.Cars{ford, rav4, bronco},
+		},
+		{
+			name:     "filter by color",
+			filters:  models.CarFilters{Color: []string{"burnt orange", "White"}},
+			expected: models.Cars{camry, bronco},
+		},
+		{
+			name:     "package excludes cars without package",
+			filters:  models.CarFilters{Package: []string{"se", "XSE", "Sport"}},
+			expected: models.Cars{camry, rav4},
+		},
+		{
+			name:     "multiple years",
+			filters:  models.CarFilters{Year: []int{2010, 2022, 1999}},
+			expected: models.Cars{ford, bronco},
+		},
+		{
+			name:     "year range is inclusive",
+			filters:  models.CarFilters{MinYear: u.Ptr(2018), MaxYear: u.Ptr(2019)},
+			expected: models.Cars{camry, rav4},
+		},
+		{
+			name:     "min year only",
+			filters:  models.CarFilters{MinYear: u.Ptr(2019)},
+			expected: models.Cars{camry, bronco},
+		},
+		{
+			name:     "price range excludes cars without price",
+			filters:  models.CarFilters{MinPrice: u.Ptr(int64(0)), MaxPrice: u.Ptr(int64(2500000))},
+			expected: models.Cars{ford, rav4},
+		},
+		{
+			name:     "max mileage excludes cars without mileage",
+			filters:  models.CarFilters{MaxMileage: u.Ptr(int64(24001))},
+			expected: models.Cars{camry, rav4},
+		},
+		{
+			name:     "min mileage combined with make",
+			filters:  models.CarFilters{Make: []string{"Ford"}, MinMileage: u.Ptr(int64(1))},
+			expected: models.Cars{ford},
+		},
+		{
+			name:     "no match returns empty list",
+			filters:  models.CarFilters{Make: []string{"BMW"}},
+			expected: models.Cars{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.List(tt.filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got == nil {
+				t.Fatal("expected non-nil list")
+			}
+
+			if !reflect.DeepEqual(ids(got), ids(tt.expected)) {
+				t.Fatalf("expected IDs %v, got %v", ids(tt.expected), ids(got))
+			}
+
+			byID := make(map[string]models.Car, len(got))
+			for _, car := range got {
+				byID[car.ID] = car
+			}
+			for _, want := range tt.expected {
+				if !reflect.DeepEqual(byID[want.ID], want) {
+					t.Errorf("expected %+v, got %+v", want, byID[want.ID])
+				}
+			}
+		})
+	}
+
+	t.Run("should order by ID", func(t *testing.T) {
+		got, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for i := 1; i < len(got); i++ {
+			if got[i-1].ID >= got[i].ID {
+				t.Fatalf("expected cars ordered by ID, got %v", order(got))
+			}
+		}
+	})
+
+	t.Run("should only ignore ASCII case in text filters", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := sampleCars()[:2]
+		cars[0].Make, cars[1].Make = "Škoda", "škoda"
+		stored := seed(t, repo, cars)
+
+		// NOCASE folds "KODA" but not "Š", so only one spelling matches.
+		got, err := repo.List(models.CarFilters{Make: []string{"ŠKODA"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(ids(got), ids(stored[:1])) {
+			t.Fatalf("expected IDs %v, got %v", ids(stored[:1]), ids(got))
+		}
+	})
+
+	t.Run("should apply limit", func(t *testing.T) {
+		got, err := repo.List(models.CarFilters{Limit: 2})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expected := ids(stored)[:2]; !reflect.DeepEqual(order(got), expected) {
+			t.Fatalf("expected IDs %v, got %v", expected, order(got))
+		}
+	})
+
+	t.Run("should page through all cars with After", func(t *testing.T) {
+		var seen []string
+		filters := models.CarFilters{Limit: 3}
+		for {
+			page, err := repo.List(filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			seen = append(seen, order(page)...)
+			filters.After = &models.CarCursor{ID: page[len(page)-1].ID}
+		}
+
+		if !reflect.DeepEqual(seen, ids(stored)) {
+			t.Fatalf("expected IDs %v, got %v", ids(stored), seen)
+		}
+	})
+
+	for _, expr := range []string{"year", "-year", "price", "-price", "mileage", "-mileage", "make,-price", "-make,model", "-model,year"} {
+		keys, err := models.ParseCarSort(expr)
+		if err != nil {
+			t.Fatalf("parse sort %q: %v", expr, err)
+		}
+
+		expected := append(models.Cars(nil), stored...)
+		models.SortCars(expected, keys)
+
+		t.Run("should sort by "+expr, func(t *testing.T) {
+			got, err := repo.List(models.CarFilters{Sort: keys})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(order(got), order(expected)) {
+				t.Fatalf("expected IDs %v, got %v", order(expected), order(got))
+			}
+		})
+
+		t.Run("should page by "+expr, func(t *testing.T) {
+			var seen []string
+			filters := models.CarFilters{Sort: keys, Limit: 1}
+			for range len(stored) + 1 {
+				page, err := repo.List(filters)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				seen = append(seen, order(page)...)
+				filters.After = models.NewCarCursor(page[len(page)-1], keys)
+			}
+
+			if !reflect.DeepEqual(seen, order(expected)) {
+				t.Fatalf("expected IDs %v, got %v", order(expected), seen)
+			}
+		})
+	}
+
+	t.Run("should combine After with filters", func(t *testing.T) {
+		toyotas := ids(models.Cars{camry, rav4})
+
+		got, err := repo.List(models.CarFilters{
+			Make:  []string{"Toyota"},
+			After: &models.CarCursor{ID: toyotas[0]},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(order(got), toyotas[1:]) {
+			t.Fatalf("expected IDs %v, got %v", toyotas[1:], order(got))
+		}
+	})
+}
+
+func testEach(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+
+	batch := make(models.Cars, 1234)
+	for i := range batch {
+		batch[i] = sampleCars()[i%4]
+		batch[i].Year += i % 7
+	}
+	if err := repo.CreateBatch(batch); err != nil {
+		t.Fatalf("seed cars: %v", err)
+	}
+
+	sortKeys, err := models.ParseCarSort("-year,price")
+	if err != nil {
+		t.Fatalf("parse sort: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		filters models.CarFilters
+	}{
+		{name: "every car", filters: models.CarFilters{}},
+		{name: "sorted", filters: models.CarFilters{Sort: sortKeys}},
+		{name: "filtered", filters: models.CarFilters{Make: []string{"Toyota"}, Sort: sortKeys}},
+		{name: "ranked", filters: models.CarFilters{Query: "s"}},
+		{name: "limited", filters: models.CarFilters{Sort: sortKeys, Limit: 1001}},
+		{name: "after a cursor", filters: models.CarFilters{After: &models.CarCursor{ID: batch[600].ID}}},
+	}
+
+	for _, tt := range tests {
+		t.Run("should visit the cars List returns for "+tt.name, func(t *testing.T) {
+			expected, err := repo.List(tt.filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got models.Cars
+			err = repo.Each(tt.filters, func(car models.Car) bool {
+				got = append(got, car)
+				return true
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(order(got), order(expected)) {
+				t.Fatalf("expected %d cars in List order, got %d cars", len(expected), len(got))
+			}
+		})
+	}
+
+	t.Run("should stop when fn returns false", func(t *testing.T) {
+		calls := 0
+		err := repo.Each(models.CarFilters{}, func(car models.Car) bool {
+			calls++
+			return calls < 3
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if calls != 3 {
+			t.Fatalf("expected 3 calls, got %d", calls)
+		}
+	})
+}
+
+func testSearch(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	stored := seed(t, repo, sampleCars())
+	ford, camry, rav4, bronco := stored[0], stored[1], stored[2], stored[3]
+
+	// ranked returns the cars in the order a ranked search must list them.
+	ranked := func(query string, cars ...models.Car) []string {
+		cars = append(models.Cars(nil), cars...)
+		slices.SortFunc(cars, func(a, b models.Car) int {
+			return models.CompareRanked(a, models.CarRelevance(a, query), b, models.CarRelevance(b, query))
+		})
+		return order(cars)
+	}
+
+	tests := []struct {
+		name     string
+		filters  models.CarFilters
+		expected []string
+	}{
+		{
+			name:     "every token must match",
+			filters:  models.CarFilters{Query: "red toyota rav4 xse"},
+			expected: []string{rav4.ID},
+		},
+		{
+			name:     "tokens are case-insensitive and match prefixes",
+			filters:  models.CarFilters{Query: "TOY"},
+			expected: ranked("toy", camry, rav4),
+		},
+		{
+			name:     "exact matches rank above prefix matches",
+			filters:  models.CarFilters{Query: "s"},
+			expected: ranked("s", ford, camry, rav4, bronco),
+		},
+		{
+			name:     "search combines with filters",
+			filters:  models.CarFilters{Query: "suv", Make: []string{"ford"}},
+			expected: []string{bronco.ID},
+		},
+		{
+			name:     "explicit sort overrides relevance",
+			filters:  models.CarFilters{Query: "suv", Sort: []models.CarSort{{Field: models.SortByYear, Desc: true}}},
+			expected: []string{bronco.ID, rav4.ID},
+		},
+		{
+			name:     "no match returns empty list",
+			filters:  models.CarFilters{Query: "toyota bronco"},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.List(tt.filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got == nil {
+				t.Fatal("expected non-nil list")
+			}
+
+			if !reflect.DeepEqual(order(got), tt.expected) {
+				t.Fatalf("expected IDs %v, got %v", tt.expected, order(got))
+			}
+		})
+	}
+
+	t.Run("should page through ranked results", func(t *testing.T) {
+		const query = "s"
+
+		var seen []string
+		filters := models.CarFilters{Query: query, Limit: 1}
+		for range len(stored) + 1 {
+			page, err := repo.List(filters)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			seen = append(seen, order(page)...)
+
+			last := page[len(page)-1]
+			filters.After = models.NewCarCursor(last, nil)
+			filters.After.Score = models.CarRelevance(last, query)
+		}
+
+		if expected := ranked(query, stored...); !reflect.DeepEqual(seen, expected) {
+			t.Fatalf("expected IDs %v, got %v", expected, seen)
+		}
+	})
+
+	t.Run("should match prefixes ending in non-ASCII letters", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := sampleCars()[:2]
+		cars[0].Make, cars[1].Make = "Škoda", "Skoda"
+		stored := seed(t, repo, cars)
+
+		for query, expected := range map[string][]string{
+			"Š":   {stored[0].ID},
+			"ško": {stored[0].ID},
+			"sk":  {stored[1].ID},
+		} {
+			got, err := repo.List(models.CarFilters{Query: query})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(order(got), expected) {
+				t.Fatalf("expected IDs %v for %q, got %v", expected, query, order(got))
+			}
+		}
+	})
+
+	t.Run("should keep the index in sync with writes", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := seed(t, repo, sampleCars())
+
+		updated := cars[0]
+		updated.Color = "Midnight Blue"
+		if _, err := repo.Update(&updated); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		if _, err := repo.Patch(cars[1].ID, func(car *models.Car) error {
+			car.Package = u.Ptr("Hybrid")
+			return nil
+		}); err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+
+		if _, err := repo.Delete(cars[2].ID, 0); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+
+		for query, expected := range map[string][]string{
+			"midnight": {cars[0].ID},
+			"silver":   {},
+			"hybrid":   {cars[1].ID},
+			"rav4":     {},
+		} {
+			got, err := repo.List(models.CarFilters{Query: query})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(order(got), expected) {
+				t.Errorf("query %q: expected IDs %v, got %v", query, expected, order(got))
+			}
+		}
+	})
+}
+
+func testExpr(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	stored := seed(t, repo, sampleCars())
+	ford, camry, rav4, bronco := stored[0], stored[1], stored[2], stored[3]
+
+	tests := []struct {
+		expr     string
+		expected models.Cars
+	}{
+		{expr: "(make = toyota AND price < 2500000) OR category = Truck", expected: models.Cars{ford, rav4}},
+		{expr: "category IN (suv, 'Truck') AND NOT make = Toyota", expected: models.Cars{ford, bronco}},
+		{expr: "year NOT IN (2010, 2022)", expected: models.Cars{camry, rav4}},
+		{expr: "price = null", expected: models.Cars{bronco}},
+		{expr: "price != null AND mileage <= 24001", expected: models.Cars{camry, rav4}},
+		{expr: "price != 1999900", expected: models.Cars{camry, rav4, bronco}},
+		{expr: "NOT price > 2000000", expected: models.Cars{ford, bronco}},
+		{expr: "package NOT IN (SE, xse)", expected: models.Cars{ford, bronco}},
+		{expr: "NOT (mileage IN (3999, 24001))", expected: models.Cars{ford, bronco}},
+		{expr: "model >= 'c' AND model < 'g'", expected: models.Cars{ford, camry}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := models.ParseCarExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			got, err := repo.List(models.CarFilters{Expr: expr})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(ids(got), ids(tt.expected)) {
+				t.Fatalf("expected IDs %v, got %v", ids(tt.expected), ids(got))
+			}
+		})
+	}
+}
+
+func testCreate(t *testing.T, newRepo Factory) {
+	t.Run("should generate an ID and store the car", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[1]
+
+		if err := repo.Create(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if car.ID == "" {
+			t.Fatal("expected generated car ID")
+		}
+
+		got, err := repo.Find(car.ID, false)
+		if err != nil {
+			t.Fatalf("expected created car to be found: %v", err)
+		}
+
+		if !reflect.DeepEqual(car, got) {
+			t.Fatalf("expected %+v, got %+v", car, got)
+		}
+	})
+
+	t.Run("should generate unique IDs", func(t *testing.T) {
+		repo := newRepo(t)
+
+		const n = 100
+		seen := make(map[string]bool, n)
+		for i := 0; i < n; i++ {
+			car := sampleCars()[0]
+			if err := repo.Create(&car); err != nil {
+				t.Fatalf("create %d: %v", i, err)
+			}
+			if seen[car.ID] {
+				t.Fatalf("duplicate ID generated: %s", car.ID)
+			}
+			seen[car.ID] = true
+		}
+
+		all, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(all) != n {
+			t.Fatalf("expected %d cars, got %d", n, len(all))
+		}
+	})
+
+	t.Run("should store a copy of the car", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[1]
+
+		if err := repo.Create(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		car.Make = "Changed"
+
+		got, err := repo.Find(car.ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.Make != "Toyota" {
+			t.Fatalf("expected stored make to be unaffected, got %q", got.Make)
+		}
+	})
+
+	t.Run("should create every car of a batch", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := sampleCars()
+
+		if err := repo.CreateBatch(cars); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for i, car := range cars {
+			if car.ID == "" || car.Revision != 1 {
+				t.Fatalf("expected car %d to have an ID and revision 1, got %+v", i, car)
+			}
+
+			got, err := repo.Find(car.ID, false)
+			if err != nil {
+				t.Fatalf("expected car %d to be found: %v", i, err)
+			}
+			if !reflect.DeepEqual(car, got) {
+				t.Fatalf("expected %+v, got %+v", car, got)
+			}
+		}
+
+		found, err := repo.List(models.CarFilters{Query: "bronco"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids(found), []string{cars[3].ID}) {
+			t.Fatalf("expected batch to be searchable, got %v", ids(found))
+		}
+	})
+}
+
+func testInsert(t *testing.T, newRepo Factory) {
+	t.Run("should store the car under its own ID", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[1]
+		car.ID = "JHK290XJ"
+
+		if err := repo.Insert(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if car.ID != "JHK290XJ" || car.Revision != 1 {
+			t.Fatalf("expected car JHK290XJ at revision 1, got %s at %d", car.ID, car.Revision)
+		}
+
+		got, err := repo.Find("JHK290XJ", false)
+		if err != nil {
+			t.Fatalf("expected inserted car to be found: %v", err)
+		}
+
+		if !reflect.DeepEqual(car, got) {
+			t.Fatalf("expected %+v, got %+v", car, got)
+		}
+
+		found, err := repo.List(models.CarFilters{Query: "camry"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := ids(found); !reflect.DeepEqual(got, []string{"JHK290XJ"}) {
+			t.Fatalf("expected inserted car to be searchable, got %v", got)
+		}
+	})
+
+	t.Run("should reject the ID of a live car", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])[0]
+
+		car := sampleCars()[1]
+		car.ID = stored.ID
+
+		if err := repo.Insert(&car); !errors.Is(err, e.ErrCarExists) {
+			t.Fatalf("expected ErrCarExists, got %v", err)
+		}
+
+		got, err := repo.Find(stored.ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(stored, got) {
+			t.Fatalf("expected %+v to be kept, got %+v", stored, got)
+		}
+	})
+
+	t.Run("should reject the ID of a deleted car", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])[0]
+
+		if _, err := repo.Delete(stored.ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		car := sampleCars()[1]
+		car.ID = stored.ID
+
+		if err := repo.Insert(&car); !errors.Is(err, e.ErrCarExists) {
+			t.Fatalf("expected ErrCarExists, got %v", err)
+		}
+	})
+
+	t.Run("should store a new car under the ID of a purged car from revision 1", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])[0]
+
+		if _, err := repo.Delete(stored.ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := repo.Purge(time.Now().Add(time.Second)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		car := sampleCars()[1]
+		car.ID = stored.ID
+
+		if err := repo.Insert(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := repo.Find(stored.ID, false)
+		if err != nil {
+			t.Fatalf("expected inserted car to be found: %v", err)
+		}
+
+		if got.Revision != 1 || got.Model != car.Model {
+			t.Fatalf("expected %+v at revision 1, got %+v", car, got)
+		}
+	})
+}
+
+func testUpdate(t *testing.T, newRepo Factory) {
+	t.Run("should replace existing car", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		updated := stored[1]
+		updated.Color = "Black"
+		updated.Package = nil
+		updated.Price = u.Ptr(int64(2500000))
+
+		replaced, err := repo.Update(&updated)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(replaced, stored[1]) {
+			t.Fatalf("expected replaced car %+v, got %+v", stored[1], replaced)
+		}
+
+		got, err := repo.Find(updated.ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(updated, got) {
+			t.Fatalf("expected %+v, got %+v", updated, got)
+		}
+
+		other, err := repo.Find(stored[0].ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(stored[0], other) {
+			t.Fatalf("expected unrelated car to be unchanged, got %+v", other)
+		}
+	})
+
+	t.Run("should wrap ErrCarNotFound and not create when car does not exist", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[0]
+		car.ID = "missing-id"
+
+		_, err := repo.Update(&car)
+		if !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+
+		if _, err := repo.Find(car.ID, false); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected update not to create the car, got %v", err)
+		}
+	})
+}
+
+func testPatch(t *testing.T, newRepo Factory) {
+	t.Run("should apply patch and store the result", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		got, err := repo.Patch(stored[1].ID, func(car *models.Car) error {
+			car.Price = u.Ptr(int64(2799000))
+			car.Package = nil
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := stored[1]
+		expected.Price = u.Ptr(int64(2799000))
+		expected.Package = nil
+		expected.Revision = stored[1].Revision + 1
+
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("expected returned car %+v, got %+v", expected, got)
+		}
+
+		found, err := repo.Find(expected.ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(expected, found) {
+			t.Fatalf("expected stored car %+v, got %+v", expected, found)
+		}
+	})
+
+	t.Run("should keep the ID even if the patch changes it", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+
+		got, err := repo.Patch(stored[0].ID, func(car *models.Car) error {
+			car.ID = "other-id"
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got.ID != stored[0].ID {
+			t.Fatalf("expected ID %q, got %q", stored[0].ID, got.ID)
+		}
+
+		if _, err := repo.Find("other-id", false); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected no car under the patched ID, got %v", err)
+		}
+	})
+
+	t.Run("should return patch error as-is and leave car unchanged", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+		patchErr := errors.New("patch rejected")
+
+		_, err := repo.Patch(stored[0].ID, func(car *models.Car) error {
+			car.Color = "Changed"
+			return patchErr
+		})
+		if !errors.Is(err, patchErr) {
+			t.Fatalf("expected %v, got %v", patchErr, err)
+		}
+
+		got, err := repo.Find(stored[0].ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(stored[0], got) {
+			t.Fatalf("expected car to be unchanged, got %+v", got)
+		}
+	})
+
+	t.Run("should wrap ErrCarNotFound when car does not exist", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.Patch("missing-id", func(car *models.Car) error {
+			t.Error("patch should not be called")
+			return nil
+		})
+		if !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+
+	t.Run("should not lose concurrent updates", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[0]
+		car.Mileage = u.Ptr(int64(0))
+		stored := seed(t, repo, models.Cars{car})
+
+		const n = 50
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.Patch(stored[0].ID, func(car *models.Car) error {
+					car.Mileage = u.Ptr(*car.Mileage + 1)
+					return nil
+				})
+				if err != nil {
+					t.Errorf("patch: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, err := repo.Find(stored[0].ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if *got.Mileage != n {
+			t.Fatalf("expected mileage %d, got %d", n, *got.Mileage)
+		}
+	})
+}
+
+func testDelete(t *testing.T, newRepo Factory) {
+	t.Run("should remove existing car", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		if _, err := repo.Delete(stored[0].ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := repo.Find(stored[0].ID, false); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected deleted car to be gone, got %v", err)
+		}
+
+		all, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(ids(all), ids(stored[1:])) {
+			t.Fatalf("expected remaining IDs %v, got %v", ids(stored[1:]), ids(all))
+		}
+	})
+
+	t.Run("should wrap ErrCarNotFound when car does not exist", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.Delete("missing-id", 0); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+
+	t.Run("should wrap ErrCarNotFound when deleting twice", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+
+		if _, err := repo.Delete(stored[0].ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := repo.Delete(stored[0].ID, 0); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+
+	t.Run("should keep deleted car for reads that include it", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		before := time.Now()
+
+		deleted, err := repo.Delete(stored[0].ID, 0)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got, err := repo.Find(stored[0].ID, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(deleted, got) {
+			t.Fatalf("expected returned %+v to be stored, got %+v", deleted, got)
+		}
+		if got.DeletedAt == nil || got.DeletedAt.Before(before.Add(-time.Second)) || got.DeletedAt.After(time.Now()) {
+			t.Fatalf("expected deletion time around now, got %v", got.DeletedAt)
+		}
+		if got.Revision != stored[0].Revision+1 {
+			t.Fatalf("expected revision %d, got %d", stored[0].Revision+1, got.Revision)
+		}
+
+		got.DeletedAt, got.Revision = nil, stored[0].Revision
+		if !reflect.DeepEqual(got, stored[0]) {
+			t.Fatalf("expected %+v, got %+v", stored[0], got)
+		}
+
+		all, err := repo.List(models.CarFilters{IncludeDeleted: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids(all), ids(stored)) {
+			t.Fatalf("expected IDs %v, got %v", ids(stored), ids(all))
+		}
+
+		for _, includeDeleted := range []bool{false, true} {
+			found, err := repo.List(models.CarFilters{Query: "f10", IncludeDeleted: includeDeleted})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(found) != map[bool]int{false: 0, true: 1}[includeDeleted] {
+				t.Fatalf("expected deleted car to match search only when included, got %d cars (include deleted %v)", len(found), includeDeleted)
+			}
+		}
+	})
+
+	t.Run("should treat deleted car as missing on writes", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		car := stored[1]
+
+		if _, err := repo.Delete(car.ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		car.Color = "Blue"
+		car.Revision = 0
+		if _, err := repo.Update(&car); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("update: expected %v, got %v", e.ErrCarNotFound, err)
+		}
+
+		if _, err := repo.Patch(car.ID, func(c *models.Car) error { return nil }); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("patch: expected %v, got %v", e.ErrCarNotFound, err)
+		}
+
+		updated, err := repo.UpdateBatch(models.CarSelector{IDs: []string{car.ID}}, func(c *models.Car) error { return nil }, false)
+		if err != nil || len(updated) != 0 {
+			t.Fatalf("update batch: expected 0 cars, got %v (%v)", ids(updated), err)
+		}
+
+		deleted, err := repo.DeleteBatch(models.CarSelector{Filters: &models.CarFilters{Make: []string{"Toyota"}, IncludeDeleted: true}}, false)
+		if err != nil || len(deleted) != 1 {
+			t.Fatalf("delete batch: expected 1 live car, got %v (%v)", ids(deleted), err)
+		}
+	})
+}
+
+func testRestore(t *testing.T, newRepo Factory) {
+	t.Run("should make deleted car live again", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		if _, err := repo.Delete(stored[0].ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		restored, err := repo.Restore(stored[0].ID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := stored[0]
+		expected.Revision += 2
+		if !reflect.DeepEqual(restored, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, restored)
+		}
+
+		got, err := repo.Find(stored[0].ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+
+		assertMakes(t, repo, facets{{Value: "Ford", Count: 2}, {Value: "Toyota", Count: 2}})
+	})
+
+	t.Run("should wrap ErrCarNotDeleted when car is live", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+
+		if _, err := repo.Restore(stored[0].ID); !errors.Is(err, e.ErrCarNotDeleted) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotDeleted, err)
+		}
+	})
+
+	t.Run("should wrap ErrCarNotFound when car does not exist", func(t *testing.T) {
+		repo := newRepo(t)
+
+		if _, err := repo.Restore("missing-id"); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+}
+
+func testPurge(t *testing.T, newRepo Factory) {
+	t.Run("should remove cars deleted before the cutoff", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		if _, err := repo.Delete(stored[0].ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := repo.DeleteBatch(models.CarSelector{IDs: []string{stored[1].ID}}, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		n, err := repo.Purge(time.Now().Add(-time.Hour))
+		if err != nil || n != 0 {
+			t.Fatalf("expected recent deletions to be kept, got %d (%v)", n, err)
+		}
+
+		n, err = repo.Purge(time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 2 {
+			t.Fatalf("expected 2 cars purged, got %d", n)
+		}
+
+		for _, car := range stored[:2] {
+			if _, err := repo.Find(car.ID, true); !errors.Is(err, e.ErrCarNotFound) {
+				t.Fatalf("expected purged car to be gone, got %v", err)
+			}
+			if _, err := repo.Restore(car.ID); !errors.Is(err, e.ErrCarNotFound) {
+				t.Fatalf("expected purged car not to be restorable, got %v", err)
+			}
+		}
+
+		all, err := repo.List(models.CarFilters{Query: "camry", IncludeDeleted: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(all) != 0 {
+			t.Fatalf("expected purged car to leave the search index, got %v", ids(all))
+		}
+
+		assertMakes(t, repo, facets{{Value: "Ford", Count: 1}, {Value: "Toyota", Count: 1}})
+	})
+
+	t.Run("should keep live cars", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		n, err := repo.Purge(time.Now().Add(time.Hour))
+		if err != nil || n != 0 {
+			t.Fatalf("expected no car purged, got %d (%v)", n, err)
+		}
+
+		all, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids(all), ids(stored)) {
+			t.Fatalf("expected IDs %v, got %v", ids(stored), ids(all))
+		}
+	})
+}
+
+func testUpdateBatch(t *testing.T, newRepo Factory) {
+	discount := func(car *models.Car) error {
+		car.Price = u.Ptr(int64(1000000))
+		return nil
+	}
+
+	t.Run("should update the cars matching a filter", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		sel := models.CarSelector{Filters: &models.CarFilters{Make: []string{"toyota"}}}
+
+		updated, err := repo.UpdateBatch(sel, discount, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := ids(models.Cars{stored[1], stored[2]}); !reflect.DeepEqual(ids(updated), expected) {
+			t.Fatalf("expected cars %v updated, got %v", expected, ids(updated))
+		}
+
+		for i, car := range stored {
+			got, err := repo.Find(car.ID, false)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if j := slices.Index(order(updated), car.ID); j >= 0 && !reflect.DeepEqual(updated[j], got) {
+				t.Fatalf("car %d: expected returned %+v to be stored, got %+v", i, updated[j], got)
+			}
+
+			updated := car.Make == "Toyota"
+			if discounted := got.Price != nil && *got.Price == 1000000; discounted != updated {
+				t.Fatalf("car %d: expected updated=%v, got %+v", i, updated, got)
+			}
+			if updated && got.Revision != car.Revision+1 {
+				t.Fatalf("car %d: expected revision %d, got %d", i, car.Revision+1, got.Revision)
+			}
+		}
+	})
+
+	t.Run("should update the cars with the given IDs", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		sel := models.CarSelector{IDs: []string{stored[3].ID, "missing-id", stored[0].ID, stored[3].ID}}
+
+		var patched []string
+		updated, err := repo.UpdateBatch(sel, func(car *models.Car) error {
+			patched = append(patched, car.ID)
+			car.Color = "Black"
+			return nil
+		}, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(updated) != 2 {
+			t.Fatalf("expected 2 cars updated, got %v", ids(updated))
+		}
+		if !reflect.DeepEqual(patched, ids(models.Cars{stored[0], stored[3]})) {
+			t.Fatalf("expected cars to be patched once each in ID order, got %v", patched)
+		}
+
+		found, err := repo.List(models.CarFilters{Color: []string{"Black"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids(found), ids(models.Cars{stored[0], stored[3]})) {
+			t.Fatalf("expected updated cars to be listed, got %v", ids(found))
+		}
+	})
+
+	t.Run("should keep the index in sync", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		sel := models.CarSelector{IDs: []string{stored[3].ID}}
+
+		if _, err := repo.UpdateBatch(sel, func(car *models.Car) error {
+			car.Model = "Maverick"
+			return nil
+		}, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		found, err := repo.List(models.CarFilters{Query: "maverick"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids(found), []string{stored[3].ID}) {
+			t.Fatalf("expected updated car to be found by new model, got %v", ids(found))
+		}
+
+		if found, _ := repo.List(models.CarFilters{Query: "bronco"}); len(found) != 0 {
+			t.Fatalf("expected old model to be forgotten, got %v", ids(found))
+		}
+	})
+
+	t.Run("should change nothing when a patch fails", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		patchErr := errors.New("patch failed")
+
+		var calls int
+		_, err := repo.UpdateBatch(models.CarSelector{Filters: &models.CarFilters{Make: []string{"Ford"}}}, func(car *models.Car) error {
+			if calls++; calls == 2 {
+				return patchErr
+			}
+			car.Color = "Black"
+			return nil
+		}, false)
+		if !errors.Is(err, patchErr) {
+			t.Fatalf("expected %v, got %v", patchErr, err)
+		}
+
+		for _, car := range stored {
+			if got, _ := repo.Find(car.ID, false); !reflect.DeepEqual(got, car) {
+				t.Fatalf("expected %+v to be unchanged, got %+v", car, got)
+			}
+		}
+	})
+
+	t.Run("should only return the patched cars in dry-run mode", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		updated, err := repo.UpdateBatch(models.CarSelector{Filters: &models.CarFilters{Category: []string{"SUV"}}}, discount, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := ids(models.Cars{stored[2], stored[3]}); !reflect.DeepEqual(ids(updated), expected) {
+			t.Fatalf("expected cars %v returned, got %v", expected, ids(updated))
+		}
+		for _, car := range updated {
+			if *car.Price != 1000000 || car.Revision != 2 {
+				t.Fatalf("expected patched car at revision 2, got %+v", car)
+			}
+		}
+
+		for _, car := range stored {
+			if got, _ := repo.Find(car.ID, false); !reflect.DeepEqual(got, car) {
+				t.Fatalf("expected %+v to be unchanged, got %+v", car, got)
+			}
+		}
+	})
+}
+
+func testDeleteBatch(t *testing.T, newRepo Factory) {
+	t.Run("should delete the cars matching a filter", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		deleted, err := repo.DeleteBatch(models.CarSelector{Filters: &models.CarFilters{Query: "toyota"}}, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := ids(models.Cars{stored[1], stored[2]}); !reflect.DeepEqual(ids(deleted), expected) {
+			t.Fatalf("expected cars %v deleted, got %v", expected, ids(deleted))
+		}
+		for _, car := range deleted {
+			if got, _ := repo.Find(car.ID, true); !reflect.DeepEqual(got, car) {
+				t.Fatalf("expected returned %+v to be stored, got %+v", car, got)
+			}
+		}
+
+		all, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := ids(models.Cars{stored[0], stored[3]}); !reflect.DeepEqual(ids(all), expected) {
+			t.Fatalf("expected remaining IDs %v, got %v", expected, ids(all))
+		}
+
+		if found, _ := repo.List(models.CarFilters{Query: "toyota"}); len(found) != 0 {
+			t.Fatalf("expected deleted cars to be unindexed, got %v", ids(found))
+		}
+	})
+
+	t.Run("should delete the cars with the given IDs", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		deleted, err := repo.DeleteBatch(models.CarSelector{IDs: []string{stored[1].ID, "missing-id", stored[1].ID}}, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(deleted) != 1 || !deleted[0].Deleted() {
+			t.Fatalf("expected 1 car deleted, got %+v", deleted)
+		}
+
+		if _, err := repo.Find(stored[1].ID, false); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected deleted car to be gone, got %v", err)
+		}
+	})
+
+	t.Run("should only return the cars unchanged in dry-run mode", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		deleted, err := repo.DeleteBatch(models.CarSelector{Filters: &models.CarFilters{MaxYear: u.Ptr(2019)}}, true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := ids(stored[:3]); !reflect.DeepEqual(ids(deleted), expected) {
+			t.Fatalf("expected cars %v returned, got %v", expected, ids(deleted))
+		}
+		for _, car := range deleted {
+			if car.Deleted() || car.Revision != 1 {
+				t.Fatalf("expected unchanged car, got %+v", car)
+			}
+		}
+
+		all, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(ids(all), ids(stored)) {
+			t.Fatalf("expected every car to remain, got %v", ids(all))
+		}
+	})
+}
+
+func testCatalog(t *testing.T, newRepo Factory) {
+	t.Run("should return empty non-nil makes when repository is empty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		got, err := repo.Makes()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got == nil || len(got) != 0 {
+			t.Fatalf("expected no makes, got %#v", got)
+		}
+	})
+
+	t.Run("should count makes and models ignoring case", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := append(sampleCars(), sampleCars()[0])
+		cars[3].Make = "FORD"
+		cars[2].Model = "camry"
+		seed(t, repo, cars)
+
+		assertMakes(t, repo, facets{{Value: "Ford", Count: 3}, {Value: "Toyota", Count: 2}})
+		assertModels(t, repo, "toyota", facets{{Value: "Camry", Count: 2}})
+		assertModels(t, repo, "FoRd", facets{{Value: "Bronco", Count: 1}, {Value: "F10", Count: 2}})
+	})
+
+	t.Run("should name groups after their most frequent spelling whatever the order", func(t *testing.T) {
+		spellings := []string{"ford", "Ford", "FORD", "Ford"}
+		for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}} {
+			repo := newRepo(t)
+			cars := make(models.Cars, len(order))
+			for i, j := range order {
+				cars[i] = sampleCars()[0]
+				cars[i].Make, cars[i].Model = spellings[j], spellings[j]+"-f10"
+			}
+			seed(t, repo, cars)
+
+			assertMakes(t, repo, facets{{Value: "Ford", Count: 4}})
+			assertModels(t, repo, "ford", facets{{Value: "Ford-f10", Count: 4}})
+		}
+	})
+
+	t.Run("should rename a group when its most frequent spelling changes", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := make(models.Cars, 3)
+		for i, name := range []string{"FORD", "FORD", "Ford"} {
+			cars[i] = sampleCars()[0]
+			cars[i].Make = name
+		}
+		stored := seed(t, repo, cars)
+
+		if _, err := repo.Delete(stored[0].ID, 0); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		// FORD and Ford are tied: the smallest in byte order names the group.
+		assertMakes(t, repo, facets{{Value: "FORD", Count: 2}})
+
+		if _, err := repo.Patch(stored[1].ID, func(car *models.Car) error {
+			car.Make = "Ford"
+			return nil
+		}); err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+		assertMakes(t, repo, facets{{Value: "Ford", Count: 2}})
+
+		if _, err := repo.Restore(stored[0].ID); err != nil {
+			t.Fatalf("restore: %v", err)
+		}
+		assertMakes(t, repo, facets{{Value: "Ford", Count: 3}})
+	})
+
+	t.Run("should only ignore ASCII case in names", func(t *testing.T) {
+		repo := newRepo(t)
+		cars := sampleCars()[:2]
+		cars[0].Make, cars[1].Make = "Škoda", "škoda"
+		seed(t, repo, cars)
+
+		assertMakes(t, repo, facets{{Value: "Škoda", Count: 1}, {Value: "škoda", Count: 1}})
+	})
+
+	t.Run("should wrap ErrMakeNotFound for unknown make", func(t *testing.T) {
+		repo := newRepo(t)
+		seed(t, repo, sampleCars())
+
+		if _, err := repo.Models("Honda"); !errors.Is(err, e.ErrMakeNotFound) {
+			t.Fatalf("expected ErrMakeNotFound, got %v", err)
+		}
+	})
+
+	t.Run("should follow updates and deletes", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+		ford, camry, rav4, bronco := stored[0], stored[1], stored[2], stored[3]
+
+		ford.Make, ford.Model = "Honda", "Civic"
+		if _, err := repo.Update(&ford); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+
+		if _, err := repo.Patch(rav4.ID, func(car *models.Car) error {
+			car.Model = "camry"
+			return nil
+		}); err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+
+		if _, err := repo.Delete(bronco.ID, 0); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+
+		assertMakes(t, repo, facets{{Value: "Honda", Count: 1}, {Value: "Toyota", Count: 2}})
+		assertModels(t, repo, "Toyota", facets{{Value: "Camry", Count: 2}})
+		if _, err := repo.Models("Ford"); !errors.Is(err, e.ErrMakeNotFound) {
+			t.Fatalf("expected Ford to be gone, got %v", err)
+		}
+
+		if _, err := repo.UpdateBatch(models.CarSelector{IDs: []string{camry.ID, rav4.ID}}, func(car *models.Car) error {
+			car.Make = "Lexus"
+			return nil
+		}, false); err != nil {
+			t.Fatalf("update batch: %v", err)
+		}
+
+		assertMakes(t, repo, facets{{Value: "Honda", Count: 1}, {Value: "Lexus", Count: 2}})
+		assertModels(t, repo, "lexus", facets{{Value: "Camry", Count: 2}})
+
+		if _, err := repo.DeleteBatch(models.CarSelector{IDs: []string{ford.ID, camry.ID, rav4.ID}}, false); err != nil {
+			t.Fatalf("delete batch: %v", err)
+		}
+
+		assertMakes(t, repo, facets{})
+	})
+
+	t.Run("should not change on dry runs", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars())
+
+		sel := models.CarSelector{IDs: []string{stored[0].ID}}
+		if _, err := repo.UpdateBatch(sel, func(car *models.Car) error {
+			car.Make = "Lexus"
+			return nil
+		}, true); err != nil {
+			t.Fatalf("update batch: %v", err)
+		}
+		if _, err := repo.DeleteBatch(sel, true); err != nil {
+			t.Fatalf("delete batch: %v", err)
+		}
+
+		assertMakes(t, repo, facets{{Value: "Ford", Count: 2}, {Value: "Toyota", Count: 2}})
+	})
+}
+
+func testRevision(t *testing.T, newRepo Factory) {
+	t.Run("should start at 1 and increment on every write", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[0]
+
+		if err := repo.Create(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if car.Revision != 1 {
+			t.Fatalf("expected revision 1 after create, got %d", car.Revision)
+		}
+
+		car.Color = "Black"
+		if _, err := repo.Update(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if car.Revision != 2 {
+			t.Fatalf("expected revision 2 after update, got %d", car.Revision)
+		}
+
+		patched, err := repo.Patch(car.ID, func(c *models.Car) error {
+			c.Revision = 100
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if patched.Revision != 3 {
+			t.Fatalf("expected revision 3 after patch, got %d", patched.Revision)
+		}
+
+		got, err := repo.Find(car.ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Revision != 3 {
+			t.Fatalf("expected stored revision 3, got %d", got.Revision)
+		}
+	})
+
+	t.Run("should skip the check when revision is zero", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+
+		car := stored[0]
+		car.Revision = 0
+		if _, err := repo.Update(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if car.Revision != 2 {
+			t.Fatalf("expected revision 2, got %d", car.Revision)
+		}
+
+		if _, err := repo.Delete(car.ID, 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should reject update with stale revision", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+
+		first := stored[0]
+		first.Color = "Black"
+		if _, err := repo.Update(&first); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		stale := stored[0]
+		stale.Color = "Blue"
+		if _, err := repo.Update(&stale); !errors.Is(err, e.ErrRevisionMismatch) {
+			t.Fatalf("expected %v, got %v", e.ErrRevisionMismatch, err)
+		}
+
+		got, err := repo.Find(first.ID, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(first, got) {
+			t.Fatalf("expected %+v to be kept, got %+v", first, got)
+		}
+	})
+
+	t.Run("should reject delete with stale revision", func(t *testing.T) {
+		repo := newRepo(t)
+		stored := seed(t, repo, sampleCars()[:1])
+
+		if _, err := repo.Delete(stored[0].ID, stored[0].Revision+1); !errors.Is(err, e.ErrRevisionMismatch) {
+			t.Fatalf("expected %v, got %v", e.ErrRevisionMismatch, err)
+		}
+
+		if _, err := repo.Find(stored[0].ID, false); err != nil {
+			t.Fatalf("expected car to still exist, got %v", err)
+		}
+
+		if _, err := repo.Delete(stored[0].ID, stored[0].Revision); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should report not found before revision mismatch", func(t *testing.T) {
+		repo := newRepo(t)
+		car := sampleCars()[0]
+		car.ID = "missing-id"
+		car.Revision = 5
+
+		if _, err := repo.Update(&car); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+		if _, err := repo.Delete(car.ID, 5); !errors.Is(err, e.ErrCarNotFound) {
+			t.Fatalf("expected %v, got %v", e.ErrCarNotFound, err)
+		}
+	})
+}
+
+// testConcurrency exercises every method from many goroutines at once.
+// It is most useful when the suite runs with the -race flag.
+func testConcurrency(t *testing.T, newRepo Factory) {
+	repo := newRepo(t)
+	stored := seed(t, repo, sampleCars())
+
+	const workers = 8
+	const iterations = 25
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*iterations*5)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				car := sampleCars()[i%len(stored)]
+				if err := repo.Create(&car); err != nil {
+					errs <- fmt.Errorf("create: %w", err)
+					continue
+				}
+
+				car.Color = fmt.Sprintf("Color-%d-%d", w, i)
+				if _, err := repo.Update(&car); err != nil {
+					errs <- fmt.Errorf("update: %w", err)
+				}
+
+				if _, err := repo.Find(stored[i%len(stored)].ID, false); err != nil {
+					errs <- fmt.Errorf("find: %w", err)
+				}
+
+				if _, err := repo.List(models.CarFilters{Make: []string{"Toyota"}}); err != nil {
+					errs <- fmt.Errorf("list: %w", err)
+				}
+
+				if _, err := repo.Delete(car.ID, 0); err != nil {
+					errs <- fmt.Errorf("delete: %w", err)
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	all, err := repo.List(models.CarFilters{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(ids(all), ids(stored)) {
+		t.Fatalf("expected only seeded cars to remain, got %v", ids(all))
+	}
+}
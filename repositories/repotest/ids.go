@@ -0,0 +1,105 @@
+package repotest
+
+import (
+	"cars/models"
+	e "cars/pkg/errors"
+	"cars/repositories"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// IDFactory returns a fresh, empty CarRepository for a single test that
+// generates car IDs with ids.
+type IDFactory func(t *testing.T, ids repositories.IDGenerator) repositories.CarRepository
+
+// sequenceIDs is an IDGenerator returning the given IDs in order.
+type sequenceIDs struct {
+	ids []string
+	mu  sync.Mutex
+}
+
+func (s *sequenceIDs) NewID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.ids) == 0 {
+		return "", errors.New("no more IDs")
+	}
+	id := s.ids[0]
+	s.ids = s.ids[1:]
+	return id, nil
+}
+
+// TestIDGeneration checks that repositories produced by newRepo give new
+// cars the IDs of the injected IDGenerator and generate another ID when
+// one is taken.
+func TestIDGeneration(t *testing.T, newRepo IDFactory) {
+	t.Run("should use the generated IDs", func(t *testing.T) {
+		repo := newRepo(t, &sequenceIDs{ids: []string{"A", "B"}})
+		cars := seed(t, repo, sampleCars()[:2])
+
+		if got := order(cars); !reflect.DeepEqual(got, []string{"A", "B"}) {
+			t.Fatalf("expected IDs [A B], got %v", got)
+		}
+	})
+
+	t.Run("should retry an ID taken by a stored car", func(t *testing.T) {
+		repo := newRepo(t, &sequenceIDs{ids: []string{"A", "A", "B"}})
+		cars := seed(t, repo, sampleCars()[:2])
+
+		if got := order(cars); !reflect.DeepEqual(got, []string{"A", "B"}) {
+			t.Fatalf("expected IDs [A B], got %v", got)
+		}
+	})
+
+	t.Run("should retry an ID taken by a deleted car", func(t *testing.T) {
+		repo := newRepo(t, &sequenceIDs{ids: []string{"A", "A", "B"}})
+		seed(t, repo, sampleCars()[:1])
+		if _, err := repo.Delete("A", 0); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		car := sampleCars()[1]
+		if err := repo.Create(&car); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if car.ID != "B" {
+			t.Fatalf("expected ID B, got %s", car.ID)
+		}
+	})
+
+	t.Run("should retry an ID taken within a batch", func(t *testing.T) {
+		repo := newRepo(t, &sequenceIDs{ids: []string{"A", "A", "B"}})
+		cars := sampleCars()[:2]
+
+		if err := repo.CreateBatch(cars); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := order(cars); !reflect.DeepEqual(got, []string{"A", "B"}) {
+			t.Fatalf("expected IDs [A B], got %v", got)
+		}
+	})
+
+	t.Run("should give up when every generated ID is taken", func(t *testing.T) {
+		repo := newRepo(t, &sequenceIDs{ids: []string{"A", "A", "A", "A", "A", "A"}})
+		seed(t, repo, sampleCars()[:1])
+
+		car := sampleCars()[1]
+		if err := repo.Create(&car); !errors.Is(err, e.ErrIDCollision) {
+			t.Fatalf("expected ErrIDCollision, got %v", err)
+		}
+
+		all, err := repo.List(models.CarFilters{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(all) != 1 {
+			t.Fatalf("expected only the first car to be stored, got %d cars", len(all))
+		}
+	})
+}
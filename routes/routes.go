@@ -2,7 +2,8 @@ package routes
 
 import (
 	"cars/controllers"
-	"cars/data"
+	"cars/models"
+	"cars/pkg/config"
 	"cars/pkg/middleware"
 	"cars/repositories"
 	"cars/services"
@@ -14,29 +15,53 @@ import (
 // Register initializes and configures the application's HTTP routes.
 //
 // It sets up the dependency chain (repository → service → controller),
-// applies global middlewares, and registers all endpoints under the "/cars" path.
+// applies global middlewares, and registers the endpoints under the "/cars"
+// and "/makes" paths.
+// The repository backend is selected by cfg.Storage.
 //
 // Routes:
 //
-//	GET    /cars          - List all cars (supports optional filtering via query params)
-//	POST   /cars          - Create a new car
-//	GET    /cars/{id}     - Retrieve a car by ID
-//	PUT    /cars/{id}     - Replace an existing car (full update)
-//	DELETE /cars/{id}     - Delete a car by ID
+//	GET    /cars                - List all cars (supports optional filtering via query params)
+//	GET    /cars/stats          - Count cars per facet and summarise prices and mileages
+//	POST   /cars                - Create a new car
+//	POST   /cars/bulk           - Create several cars at once (atomic or partial)
+//	POST   /cars/bulk/update    - Patch every car selected by ID or filter
+//	POST   /cars/bulk/delete    - Delete every car selected by ID or filter
+//	POST   /cars/import         - Create cars from an uploaded CSV file
+//	GET    /cars/{id}           - Retrieve a car by ID
+//	PUT    /cars/{id}           - Replace an existing car (full update), or create it if upsert is enabled
+//	PATCH  /cars/{id}           - Partially update a car (JSON Merge Patch or JSON Patch)
+//	DELETE /cars/{id}           - Delete a car by ID (soft delete)
+//	POST   /cars/{id}/restore   - Restore a deleted car
+//	GET    /cars/{id}/history   - List the changes made to a car (audit trail)
+//	GET    /cars/{id}/revisions/{rev} - Retrieve a car as it was at a given revision
+//	GET    /makes               - List the distinct makes with their car counts
+//	GET    /makes/{make}/models - List the distinct models of a make with their car counts
 //
 // Middleware applied:
 //
 //   - CleanPath: normalizes URL paths
 //   - Recoverer: recovers from panics and returns HTTP 500
 //   - Logging: custom request logging middleware
+//   - Actor: records the X-Actor header as the actor of audited changes
+//   - Admin: grants admin access, needed for include_deleted=true, to
+//     requests bearing cfg.AdminToken
+//   - Idempotency (POST /cars only): replays the response to a retried
+//     request sent with the same Idempotency-Key header
 //
 // Returns:
 //
-//	A configured *chi.Mux router ready to be used by an HTTP server.
-func Register() *chi.Mux {
-	repo := repositories.NewCarRepository(data.Cars())
-	service := services.NewCarService(repo)
-	cars := controllers.NewCarController(service)
+//	A configured *chi.Mux router ready to be used by an HTTP server,
+//	or an error if the repository backend could not be initialized.
+func Register(cfg config.Config) (*chi.Mux, error) {
+	repo, audit, err := repositories.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	service := services.NewCarService(repo, audit)
+	cars := controllers.NewCarController(service, controllers.WithUpsert(cfg.Upsert))
+	idempotency := middleware.Idempotency(middleware.NewIdempotencyStore(cfg.IdempotencyTTL))
 
 	r := chi.NewRouter()
 
@@ -44,6 +69,8 @@ func Register() *chi.Mux {
 	r.Use(chimw.Recoverer)
 
 	r.Use(middleware.Logging)
+	r.Use(middleware.Actor)
+	r.Use(middleware.Admin(cfg.AdminToken))
 
 	r.Route("/cars", func(r chi.Router) {
 		// GET /cars
@@ -51,25 +78,89 @@ func Register() *chi.Mux {
 		// Supports optional query parameters for filtering.
 		r.Get("/", cars.List)
 
+		// GET /cars/stats
+		// Returns facet counts and price and mileage statistics.
+		// Accepts the same filter query parameters as GET /cars.
+		r.Get("/stats", cars.Stats)
+
 		// POST /cars
 		// Creates a new car.
 		// All required fields must be provided in the request body.
-		r.Post("/", cars.Create)
+		// Retries sent with the same Idempotency-Key header get the
+		// response of the first attempt instead of creating another car.
+		r.With(idempotency).Post("/", cars.Create)
+
+		// POST /cars/bulk
+		// Creates several cars from a JSON array.
+		// ?mode=atomic (default) creates all or none; ?mode=partial
+		// creates the valid cars and reports a result per item.
+		r.Post("/bulk", cars.CreateBatch)
+
+		// POST /cars/bulk/update
+		// Applies a JSON Merge Patch to every car selected by ID list or
+		// filter expression, atomically. Supports dry runs.
+		r.Post("/bulk/update", cars.UpdateBatch)
 
-		r.Route("/{id:[A-Za-z0-9-]+}", func(r chi.Router) {
+		// POST /cars/bulk/delete
+		// Deletes every car selected by ID list or filter expression,
+		// atomically. Supports dry runs.
+		r.Post("/bulk/delete", cars.DeleteBatch)
+
+		// POST /cars/import
+		// Creates cars from a CSV file (text/csv body or multipart "file"
+		// field) and reports rejected rows by row number.
+		// Supports ?mode=atomic (default) and ?mode=partial.
+		r.Post("/import", cars.Import)
+
+		r.Route("/{id:"+models.CarIDPattern+"}", func(r chi.Router) {
 			// GET /cars/{id}
 			// Retrieves a car by its ID.
 			r.Get("/", cars.Get)
 
 			// PUT /cars/{id}
 			// Performs a full replacement of the car resource.
-			// All fields must be provided; partial updates are not supported.
+			// All fields must be provided; use PATCH for partial updates.
+			// With CARS_UPSERT enabled, a missing car is created under the
+			// ID from the path (If-None-Match: * forbids replacing).
 			r.Put("/", cars.Update)
 
+			// PATCH /cars/{id}
+			// Applies a JSON Merge Patch (application/merge-patch+json) or
+			// a JSON Patch (application/json-patch+json) to the car resource
+			// atomically. Fields not touched by the patch are left unchanged.
+			r.Patch("/", cars.Patch)
+
 			// DELETE /cars/{id}
-			// Deletes a car by its ID.
+			// Marks a car as deleted. It is hidden from reads until it is
+			// restored, or removed for good once purged.
 			r.Delete("/", cars.Delete)
+
+			// POST /cars/{id}/restore
+			// Undoes the deletion of a car.
+			r.Post("/restore", cars.Restore)
+
+			// GET /cars/{id}/history
+			// Lists every change made to the car, oldest first, with its
+			// actor, request ID, time and the car before and after.
+			r.Get("/history", cars.History)
+
+			// GET /cars/{id}/revisions/{rev}
+			// Reconstructs the car as it was at the given revision from
+			// its history.
+			r.Get("/revisions/{rev}", cars.Revision)
 		})
 	})
-	return r
+
+	r.Route("/makes", func(r chi.Router) {
+		// GET /makes
+		// Lists the distinct makes, grouped ignoring case, with the number
+		// of cars of each.
+		r.Get("/", cars.Makes)
+
+		// GET /makes/{make}/models
+		// Lists the distinct models of a make, matched ignoring case, with
+		// the number of cars of each.
+		r.Get("/{make}/models", cars.Models)
+	})
+	return r, nil
 }
@@ -2,35 +2,72 @@ package services
 
 import (
 	"cars/models"
+	"cars/pkg/contextkeys"
 	e "cars/pkg/errors"
+	"cars/pkg/jsonpatch"
+	"cars/pkg/logger"
 	"cars/repositories"
+	"context"
 	"errors"
+	"fmt"
+	"time"
 )
 
+// MaxCarBatchSize is the maximum number of cars CreateBatch accepts at once,
+// and of IDs a bulk update or delete may select. Imports are not limited.
+const MaxCarBatchSize = 1000
+
+// AnonymousActor is the actor recorded in the audit trail for changes
+// whose context names none.
+const AnonymousActor = "anonymous"
+
 // CarService defines available operations for managing cars.
+//
+// Every write takes the context of the request making it: the actor
+// (contextkeys.ActorKey) and request ID (contextkeys.RequestIDKey) it
+// carries are recorded in the audit trail of the changed cars.
 type CarService interface {
-	Find(id string) (models.Car, error)
-	List(filters models.CarFilters) (models.Cars, error)
-	Create(car *models.Car) error
-	Update(car *models.Car) error
-	Delete(id string) error
+	Find(id string, includeDeleted bool) (models.Car, error)
+	FindAsOf(id string, at time.Time, includeDeleted bool) (models.Car, error)
+	FindRevision(id string, revision int64) (models.Car, error)
+	List(filters models.CarFilters) (models.CarPage, error)
+	Each(filters models.CarFilters, fn func(models.Car) bool) error
+	Stats(filters models.CarFilters) (models.CarStats, error)
+	Makes() ([]models.Facet[string], error)
+	Models(make string) ([]models.Facet[string], error)
+	Create(ctx context.Context, car *models.Car) error
+	CreateBatch(ctx context.Context, cars models.Cars, partial bool) ([]error, error)
+	Import(ctx context.Context, cars models.Cars, partial bool) ([]error, error)
+	Update(ctx context.Context, car *models.Car) error
+	Upsert(ctx context.Context, car *models.Car, createOnly bool) (bool, error)
+	Patch(ctx context.Context, id string, revision int64, patch models.CarPatchFunc) (models.Car, error)
+	Delete(ctx context.Context, id string, revision int64) error
+	UpdateBatch(ctx context.Context, sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (int, error)
+	DeleteBatch(ctx context.Context, sel models.CarSelector, dryRun bool) (int, error)
+	Restore(ctx context.Context, id string) (models.Car, error)
+	Purge(retention time.Duration) (int, error)
+	History(id string) ([]models.CarAuditEntry, error)
 }
 
 // DefaultCarService is the default implementation of CarService.
 type DefaultCarService struct {
-	repo repositories.CarRepository
+	repo  repositories.CarRepository
+	audit repositories.AuditStore
 }
 
-// NewCarService creates a new instance of DefaultCarService.
-func NewCarService(repo repositories.CarRepository) CarService {
+// NewCarService creates a new instance of DefaultCarService recording the
+// changes it makes in audit. A nil audit store disables the audit trail.
+func NewCarService(repo repositories.CarRepository, audit repositories.AuditStore) CarService {
 	return &DefaultCarService{
-		repo: repo,
+		repo:  repo,
+		audit: audit,
 	}
 }
 
-// Find retrieves a car by its ID if it exists.
-func (s *DefaultCarService) Find(id string) (models.Car, error) {
-	car, err := s.repo.Find(id)
+// Find retrieves a car by its ID if it exists. Deleted cars are reported
+// as not found unless includeDeleted is set.
+func (s *DefaultCarService) Find(id string, includeDeleted bool) (models.Car, error) {
+	car, err := s.repo.Find(id, includeDeleted)
 	if err != nil {
 		if errors.Is(err, e.ErrCarNotFound) {
 			return models.Car{}, e.NewCarNotFoundError(err)
@@ -41,18 +78,137 @@ func (s *DefaultCarService) Find(id string) (models.Car, error) {
 	return car, nil
 }
 
-// List retrieves all available cars.
-func (s *DefaultCarService) List(f models.CarFilters) (models.Cars, error) {
+// FindAsOf reconstructs the car identified by the given ID, as it was at
+// the given time, from its audit trail and the car as stored. A not found
+// error is returned if the car had not been created by then, or had been
+// deleted and includeDeleted is not set. A car with no change recorded
+// after the given time, such as one never changed through the service, is
+// returned as stored.
+func (s *DefaultCarService) FindAsOf(id string, at time.Time, includeDeleted bool) (models.Car, error) {
+	history, current, err := s.versions(id)
+	if err != nil {
+		return models.Car{}, err
+	}
+
+	car, ok := models.CarAsOf(history, current, at)
+	if !ok || car.Deleted() && !includeDeleted {
+		return models.Car{}, e.NewCarNotFoundError(models.ErrCarDidNotExist)
+	}
+	return car, nil
+}
+
+// FindRevision retrieves the car identified by the given ID as it was at
+// the given revision, from its audit trail and the car as stored. A not
+// found error is returned if neither has that revision.
+func (s *DefaultCarService) FindRevision(id string, revision int64) (models.Car, error) {
+	history, current, err := s.versions(id)
+	if err != nil {
+		return models.Car{}, err
+	}
+
+	car, ok := models.CarAtRevision(history, current, revision)
+	if !ok {
+		return models.Car{}, e.NewCarNotFoundError(models.ErrCarRevisionNotFound)
+	}
+	return car, nil
+}
+
+// List retrieves the cars matching the given filters.
+//
+// Range filters must have min <= max and a search query must contain
+// searchable terms, otherwise a validation error is returned.
+//
+// When f.Limit is positive, at most f.Limit cars are returned and the
+// page's Next cursor is set if more cars follow. One extra car is requested
+// from the repository to detect whether another page exists.
+func (s *DefaultCarService) List(f models.CarFilters) (models.CarPage, error) {
+	if err := f.Validate(); err != nil {
+		return models.CarPage{}, e.NewValidationError(err)
+	}
+
+	limit := f.Limit
+	if limit > 0 {
+		f.Limit = limit + 1
+	}
+
 	cars, err := s.repo.List(f)
 	if err != nil {
-		return models.Cars{}, e.NewInternalError(err)
+		return models.CarPage{}, e.NewInternalError(err)
+	}
+
+	page := models.CarPage{Cars: cars}
+	if limit > 0 && len(cars) > limit {
+		page.Cars = cars[:limit]
+		last := page.Cars[limit-1]
+		page.Next = f.CursorAfter(last)
+	}
+	return page, nil
+}
+
+// Each calls fn for every car matching f, in the order List returns them,
+// until fn returns false. Unlike List, cars are not collected into a page,
+// so there is no limit on how many are visited unless f.Limit is set.
+//
+// Filters are validated as for List.
+func (s *DefaultCarService) Each(f models.CarFilters, fn func(models.Car) bool) error {
+	if err := f.Validate(); err != nil {
+		return e.NewValidationError(err)
+	}
+
+	if err := s.repo.Each(f, fn); err != nil {
+		return e.NewInternalError(err)
+	}
+	return nil
+}
+
+// Stats summarises every car matching f (see models.CarStats). Ordering and
+// pagination do not apply. Filters are validated as for List.
+func (s *DefaultCarService) Stats(f models.CarFilters) (models.CarStats, error) {
+	if err := f.Validate(); err != nil {
+		return models.CarStats{}, e.NewValidationError(err)
+	}
+
+	f.Sort, f.Limit, f.After = nil, 0, nil
+
+	var collector models.CarStatsCollector
+	err := s.repo.Each(f, func(car models.Car) bool {
+		collector.Add(car)
+		return true
+	})
+	if err != nil {
+		return models.CarStats{}, e.NewInternalError(err)
+	}
+	return collector.Stats(), nil
+}
+
+// Makes retrieves the distinct makes of the stored cars with their car
+// counts.
+func (s *DefaultCarService) Makes() ([]models.Facet[string], error) {
+	makes, err := s.repo.Makes()
+	if err != nil {
+		return nil, e.NewInternalError(err)
 	}
-	return cars, nil
+	return makes, nil
+}
+
+// Models retrieves the distinct models of the cars of a make, matched
+// ignoring case, with their car counts. A not found error is returned if
+// no car has that make.
+func (s *DefaultCarService) Models(make string) ([]models.Facet[string], error) {
+	list, err := s.repo.Models(make)
+	if err != nil {
+		if errors.Is(err, e.ErrMakeNotFound) {
+			return nil, e.NewMakeNotFoundError(err)
+		}
+
+		return nil, e.NewInternalError(err)
+	}
+	return list, nil
 }
 
 // Create adds a new car to the repository.
 // The car must contain all required fields.
-func (s *DefaultCarService) Create(car *models.Car) error {
+func (s *DefaultCarService) Create(ctx context.Context, car *models.Car) error {
 	if err := car.ValidateForCreate(); err != nil {
 		return e.NewValidationError(err)
 	}
@@ -60,32 +216,431 @@ func (s *DefaultCarService) Create(car *models.Car) error {
 	if err := s.repo.Create(car); err != nil {
 		return e.NewInternalError(err)
 	}
+
+	s.record(ctx, models.CarCreated, nil, models.Cars{*car})
 	return nil
 }
 
+// CreateBatch adds several cars to the repository at once, setting their
+// IDs and revisions in place. Every car must contain all required fields.
+//
+// When partial is false the batch is all-or-nothing: if any car is invalid,
+// none is created and a validation error naming the first invalid item is
+// returned. When partial is true, the valid cars are created and the
+// returned slice holds, for each car, nil or the ServiceError that kept it
+// from being created.
+func (s *DefaultCarService) CreateBatch(ctx context.Context, cars models.Cars, partial bool) ([]error, error) {
+	if len(cars) == 0 || len(cars) > MaxCarBatchSize {
+		return nil, e.NewValidationError(
+			fmt.Errorf("batch must contain between 1 and %d cars, got %d", MaxCarBatchSize, len(cars)),
+		)
+	}
+	return s.createBatch(ctx, cars, partial)
+}
+
+// Import adds the cars of an import file to the repository like
+// CreateBatch, but without the MaxCarBatchSize limit: a file is stored in
+// a single batch whatever its size, so that an atomic import stays
+// all-or-nothing.
+func (s *DefaultCarService) Import(ctx context.Context, cars models.Cars, partial bool) ([]error, error) {
+	if len(cars) == 0 {
+		return nil, e.NewValidationError(errors.New("import must contain at least 1 car"))
+	}
+	return s.createBatch(ctx, cars, partial)
+}
+
+// createBatch implements CreateBatch and Import for a non-empty batch.
+func (s *DefaultCarService) createBatch(ctx context.Context, cars models.Cars, partial bool) ([]error, error) {
+	errs := make([]error, len(cars))
+	valid := make(models.Cars, 0, len(cars))
+	for i, car := range cars {
+		if err := car.ValidateForCreate(); err != nil {
+			if !partial {
+				return nil, e.NewValidationError(fmt.Errorf("item %d: %w", i, err))
+			}
+			errs[i] = e.NewValidationError(err)
+			continue
+		}
+		valid = append(valid, car)
+	}
+
+	if len(valid) > 0 {
+		if err := s.repo.CreateBatch(valid); err != nil {
+			return nil, e.NewInternalError(err)
+		}
+		s.record(ctx, models.CarCreated, nil, valid)
+	}
+
+	for i := range cars {
+		if errs[i] == nil {
+			cars[i], valid = valid[0], valid[1:]
+		}
+	}
+	return errs, nil
+}
+
 // Update replaces an existing car with the provided data.
 // The car must contain all required fields and a valid ID.
-func (s *DefaultCarService) Update(car *models.Car) error {
+//
+// If car.Revision is non-zero, the update only succeeds when it matches
+// the stored revision; otherwise a precondition failed error is returned.
+func (s *DefaultCarService) Update(ctx context.Context, car *models.Car) error {
 	if err := car.ValidateForUpdate(); err != nil {
 		return e.NewValidationError(err)
 	}
 
-	if err := s.repo.Update(car); err != nil {
-		if errors.Is(err, e.ErrCarNotFound) {
-			return e.NewCarNotFoundError(err)
+	before, err := s.repo.Update(car)
+	if err != nil {
+		return toWriteError(err)
+	}
+
+	s.record(ctx, models.CarUpdated, models.Cars{before}, models.Cars{*car})
+	return nil
+}
+
+// Upsert replaces the car identified by car.ID, or creates it under that
+// ID if no car has it, and reports whether it was created. The ID must
+// match models.CarIDPattern and the car must contain all required fields.
+// A car ID reserved error is returned for an ID that names another route.
+//
+// If car.Revision is non-zero, only an existing car with that revision is
+// replaced, as with Update. If createOnly is set, the car is only created
+// and a precondition failed error is returned if its ID is taken.
+//
+// The ID of a deleted car stays taken until the car is purged: a conflict
+// error is returned instead of creating the car, as it is when another
+// writer creates a car with the same ID first.
+func (s *DefaultCarService) Upsert(ctx context.Context, car *models.Car, createOnly bool) (bool, error) {
+	if err := car.ValidateForInsert(); err != nil {
+		if errors.Is(err, models.ErrCarIDReserved) {
+			return false, e.NewCarIDReservedError(err)
 		}
-		return e.NewInternalError(err)
+		return false, e.NewValidationError(err)
+	}
+
+	if !createOnly {
+		before, err := s.repo.Update(car)
+		if err == nil {
+			s.record(ctx, models.CarUpdated, models.Cars{before}, models.Cars{*car})
+			return false, nil
+		}
+		if !errors.Is(err, e.ErrCarNotFound) || car.Revision != 0 {
+			return false, toWriteError(err)
+		}
+	}
+
+	if err := s.repo.Insert(car); err != nil {
+		switch {
+		case !errors.Is(err, e.ErrCarExists):
+			return false, e.NewInternalError(err)
+		case createOnly:
+			return false, e.NewPreconditionFailedError(err)
+		default:
+			return false, e.NewCarExistsError(err)
+		}
+	}
+
+	s.record(ctx, models.CarCreated, nil, models.Cars{*car})
+	return true, nil
+}
+
+// Patch applies a partial update to the car identified by the given ID.
+//
+// The patch is applied to the stored car and the result must still pass
+// update validation before it replaces the stored version. The whole
+// read-modify-write runs atomically in the repository, so concurrent
+// writers cannot interleave with it.
+//
+// If revision is non-zero, the patch is only applied when it matches the
+// stored revision; otherwise a precondition failed error is returned.
+//
+// Errors returned by the patch are reported as an invalid request body,
+// except failed JSON Patch "test" operations, which are reported as a
+// conflict.
+func (s *DefaultCarService) Patch(ctx context.Context, id string, revision int64, patch models.CarPatchFunc) (models.Car, error) {
+	var before models.Car
+	car, err := s.repo.Patch(id, func(car *models.Car) error {
+		if revision != 0 && revision != car.Revision {
+			return e.ErrRevisionMismatch
+		}
+		before = *car
+
+		if err := patch(car); err != nil {
+			return toPatchError(err)
+		}
+		car.ID = id
+
+		if err := car.ValidateForUpdate(); err != nil {
+			return e.NewValidationError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		var serviceError *e.ServiceError
+		if errors.As(err, &serviceError) {
+			return models.Car{}, err
+		}
+		return models.Car{}, toWriteError(err)
 	}
+
+	s.record(ctx, models.CarUpdated, models.Cars{before}, models.Cars{car})
+	return car, nil
+}
+
+// Delete marks the car identified by the given ID as deleted. It is hidden
+// from reads until restored, and removed for good once purged.
+//
+// If revision is non-zero, the car is only deleted when it matches the
+// stored revision; otherwise a precondition failed error is returned.
+func (s *DefaultCarService) Delete(ctx context.Context, id string, revision int64) error {
+	car, err := s.repo.Delete(id, revision)
+	if err != nil {
+		return toWriteError(err)
+	}
+
+	s.record(ctx, models.CarDeleted, models.Cars{undeleted(car)}, models.Cars{car})
 	return nil
 }
 
-// Delete removes a car identified by the given ID.
-func (s *DefaultCarService) Delete(id string) error {
-	if err := s.repo.Delete(id); err != nil {
+// UpdateBatch applies patch to every car selected by sel and returns how
+// many cars were selected.
+//
+// Each patched car must still pass update validation. The operation is
+// atomic: if the patch fails or leaves any car invalid, no car is changed
+// and the error names the offending car. In dry-run mode the patch is
+// still applied and validated, but nothing is stored.
+func (s *DefaultCarService) UpdateBatch(ctx context.Context, sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (int, error) {
+	if err := validateSelector(sel); err != nil {
+		return 0, err
+	}
+
+	var before models.Cars
+	cars, err := s.repo.UpdateBatch(sel, func(car *models.Car) error {
+		id := car.ID
+		before = append(before, *car)
+
+		if err := patch(car); err != nil {
+			return toPatchError(err)
+		}
+		car.ID = id
+
+		if err := car.ValidateForUpdate(); err != nil {
+			return e.NewValidationError(fmt.Errorf("car %s: %w", id, err))
+		}
+		return nil
+	}, dryRun)
+	if err != nil {
+		var serviceError *e.ServiceError
+		if errors.As(err, &serviceError) {
+			return 0, err
+		}
+		return 0, e.NewInternalError(err)
+	}
+
+	if !dryRun {
+		s.record(ctx, models.CarUpdated, before, cars)
+	}
+	return len(cars), nil
+}
+
+// DeleteBatch atomically marks every car selected by sel as deleted and
+// returns how many cars were deleted. In dry-run mode the cars are only
+// counted.
+func (s *DefaultCarService) DeleteBatch(ctx context.Context, sel models.CarSelector, dryRun bool) (int, error) {
+	if err := validateSelector(sel); err != nil {
+		return 0, err
+	}
+
+	cars, err := s.repo.DeleteBatch(sel, dryRun)
+	if err != nil {
+		return 0, e.NewInternalError(err)
+	}
+
+	if !dryRun {
+		before := make(models.Cars, len(cars))
+		for i, car := range cars {
+			before[i] = undeleted(car)
+		}
+		s.record(ctx, models.CarDeleted, before, cars)
+	}
+	return len(cars), nil
+}
+
+// Restore undoes the deletion of the car identified by the given ID and
+// returns the restored car. A conflict error is returned if the car has not
+// been deleted.
+//
+// The deleted car is read before it is restored, to be recorded in the
+// audit trail. Once deleted, a car can only be changed by restoring or
+// purging it, and either would make this restore fail, so the car read is
+// the one restored.
+func (s *DefaultCarService) Restore(ctx context.Context, id string) (models.Car, error) {
+	before, err := s.repo.Find(id, true)
+	if err != nil {
+		return models.Car{}, toWriteError(err)
+	}
+
+	car, err := s.repo.Restore(id)
+	if err != nil {
+		if errors.Is(err, e.ErrCarNotDeleted) {
+			return models.Car{}, e.NewCarNotDeletedError(err)
+		}
+		return models.Car{}, toWriteError(err)
+	}
+
+	s.record(ctx, models.CarRestored, models.Cars{before}, models.Cars{car})
+	return car, nil
+}
+
+// Purge permanently removes the cars deleted more than retention ago and
+// returns how many were removed. Purged cars can no longer be restored.
+func (s *DefaultCarService) Purge(retention time.Duration) (int, error) {
+	if retention < 0 {
+		return 0, e.NewValidationError(fmt.Errorf("retention cannot be negative, got %s", retention))
+	}
+
+	n, err := s.repo.Purge(time.Now().Add(-retention))
+	if err != nil {
+		return 0, e.NewInternalError(err)
+	}
+	return n, nil
+}
+
+// History retrieves the audit trail of the car identified by the given ID,
+// oldest change first. The history of a purged car is still returned; a
+// not found error is returned if the car has no history and does not exist.
+func (s *DefaultCarService) History(id string) ([]models.CarAuditEntry, error) {
+	history, err := s.history(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history) == 0 {
+		if _, err := s.Find(id, true); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// history reads the audit trail of the car identified by the given ID. It
+// is empty when auditing is disabled.
+func (s *DefaultCarService) history(id string) ([]models.CarAuditEntry, error) {
+	if s.audit == nil {
+		return []models.CarAuditEntry{}, nil
+	}
+
+	history, err := s.audit.History(id)
+	if err != nil {
+		return nil, e.NewInternalError(err)
+	}
+	return history, nil
+}
+
+// versions reads the audit trail of the car identified by the given ID
+// and the car as stored, which is nil if it does not exist, deleted or not.
+func (s *DefaultCarService) versions(id string) ([]models.CarAuditEntry, *models.Car, error) {
+	history, err := s.history(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	car, err := s.repo.Find(id, true)
+	if err != nil {
 		if errors.Is(err, e.ErrCarNotFound) {
-			return e.NewCarNotFoundError(err)
+			return history, nil, nil
 		}
-		return e.NewInternalError(err)
+		return nil, nil, e.NewInternalError(err)
+	}
+	return history, &car, nil
+}
+
+// record appends an audit entry of the given action for each car in
+// after, made on behalf of ctx. When before is not nil it holds the cars
+// as they were before the change, in the same order.
+//
+// The entries are appended once the change is stored, not atomically with
+// it: a change whose entries cannot be appended has still been made, so
+// the failure is logged rather than returned. Reporting it would make
+// clients retry a write that succeeded, creating duplicate cars.
+func (s *DefaultCarService) record(ctx context.Context, action models.CarAuditAction, before, after models.Cars) {
+	if s.audit == nil || len(after) == 0 {
+		return
+	}
+
+	actor, _ := ctx.Value(contextkeys.ActorKey).(string)
+	if actor == "" {
+		actor = AnonymousActor
+	}
+	requestID, _ := ctx.Value(contextkeys.RequestIDKey).(string)
+	now := time.Now().UTC().Round(0)
+
+	entries := make([]models.CarAuditEntry, len(after))
+	for i, car := range after {
+		entries[i] = models.CarAuditEntry{
+			CarID:     car.ID,
+			Revision:  car.Revision,
+			Action:    action,
+			Actor:     actor,
+			RequestID: requestID,
+			Time:      now,
+			After:     car,
+		}
+		if before != nil {
+			entries[i].Before = &before[i]
+		}
+	}
+
+	if err := s.audit.Append(entries...); err != nil {
+		logger.FromContext(ctx).Printf("failed to record audit entries: %v", err)
+	}
+}
+
+// undeleted returns the car as it was before the deletion that produced
+// it.
+func undeleted(car models.Car) models.Car {
+	car.Revision--
+	car.DeletedAt = nil
+	return car
+}
+
+// validateSelector checks the selector of a bulk operation.
+func validateSelector(sel models.CarSelector) error {
+	if err := sel.Validate(); err != nil {
+		return e.NewValidationError(err)
+	}
+
+	if len(sel.IDs) > MaxCarBatchSize {
+		return e.NewValidationError(
+			fmt.Errorf("at most %d ids can be selected, got %d", MaxCarBatchSize, len(sel.IDs)),
+		)
 	}
 	return nil
 }
+
+// toWriteError converts an error returned by a repository write into
+// a ServiceError.
+func toWriteError(err error) error {
+	switch {
+	case errors.Is(err, e.ErrCarNotFound):
+		return e.NewCarNotFoundError(err)
+	case errors.Is(err, e.ErrRevisionMismatch):
+		return e.NewPreconditionFailedError(err)
+	default:
+		return e.NewInternalError(err)
+	}
+}
+
+// toPatchError converts an error returned while applying a patch
+// document into a ServiceError.
+func toPatchError(err error) error {
+	var serviceError *e.ServiceError
+	if errors.As(err, &serviceError) {
+		return err
+	}
+
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return e.NewPatchTestFailedError(err)
+	}
+	return e.NewInvalidRequestBodyError(err)
+}
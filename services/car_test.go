@@ -2,44 +2,135 @@ package services
 
 import (
 	"cars/models"
+	"cars/pkg/contextkeys"
 	e "cars/pkg/errors"
+	"cars/pkg/jsonpatch"
+	u "cars/pkg/utils"
+	"context"
 	"errors"
+	"fmt"
 	"reflect"
+	"slices"
 	"testing"
+	"time"
 )
 
-type FindFunc func(id string) (models.Car, error)
+type FindFunc func(id string, includeDeleted bool) (models.Car, error)
 type ListFunc func(filters models.CarFilters) (models.Cars, error)
+type EachFunc func(filters models.CarFilters, fn func(models.Car) bool) error
+type MakesFunc func() ([]models.Facet[string], error)
+type ModelsFunc func(make string) ([]models.Facet[string], error)
 type CreateFunc func(car *models.Car) error
-type UpdateFunc func(car *models.Car) error
-type DeleteFunc func(id string) error
+type InsertFunc func(car *models.Car) error
+type CreateBatchFunc func(cars models.Cars) error
+type UpdateFunc func(car *models.Car) (models.Car, error)
+type PatchFunc func(id string, patch models.CarPatchFunc) (models.Car, error)
+type DeleteFunc func(id string, revision int64) (models.Car, error)
+type UpdateBatchFunc func(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error)
+type DeleteBatchFunc func(sel models.CarSelector, dryRun bool) (models.Cars, error)
+type RestoreFunc func(id string) (models.Car, error)
+type PurgeFunc func(before time.Time) (int, error)
 
 type MockCarRepository struct {
-	FindFn   FindFunc
-	ListFn   ListFunc
-	CreateFn CreateFunc
-	UpdateFn UpdateFunc
-	DeleteFn DeleteFunc
+	FindFn        FindFunc
+	ListFn        ListFunc
+	EachFn        EachFunc
+	MakesFn       MakesFunc
+	ModelsFn      ModelsFunc
+	CreateFn      CreateFunc
+	InsertFn      InsertFunc
+	CreateBatchFn CreateBatchFunc
+	UpdateFn      UpdateFunc
+	PatchFn       PatchFunc
+	DeleteFn      DeleteFunc
+	UpdateBatchFn UpdateBatchFunc
+	DeleteBatchFn DeleteBatchFunc
+	RestoreFn     RestoreFunc
+	PurgeFn       PurgeFunc
 }
 
-func (m *MockCarRepository) Find(id string) (models.Car, error) {
-	return m.FindFn(id)
+func (m *MockCarRepository) Find(id string, includeDeleted bool) (models.Car, error) {
+	return m.FindFn(id, includeDeleted)
 }
 
 func (m *MockCarRepository) List(filters models.CarFilters) (models.Cars, error) {
 	return m.ListFn(filters)
 }
 
+func (m *MockCarRepository) Each(filters models.CarFilters, fn func(models.Car) bool) error {
+	return m.EachFn(filters, fn)
+}
+
+func (m *MockCarRepository) Makes() ([]models.Facet[string], error) {
+	return m.MakesFn()
+}
+
+func (m *MockCarRepository) Models(make string) ([]models.Facet[string], error) {
+	return m.ModelsFn(make)
+}
+
 func (m *MockCarRepository) Create(car *models.Car) error {
 	return m.CreateFn(car)
 }
 
-func (m *MockCarRepository) Update(car *models.Car) error {
+func (m *MockCarRepository) Insert(car *models.Car) error {
+	return m.InsertFn(car)
+}
+
+func (m *MockCarRepository) CreateBatch(cars models.Cars) error {
+	return m.CreateBatchFn(cars)
+}
+
+func (m *MockCarRepository) Update(car *models.Car) (models.Car, error) {
 	return m.UpdateFn(car)
 }
 
-func (m *MockCarRepository) Delete(id string) error {
-	return m.DeleteFn(id)
+func (m *MockCarRepository) Patch(id string, patch models.CarPatchFunc) (models.Car, error) {
+	return m.PatchFn(id, patch)
+}
+
+func (m *MockCarRepository) Delete(id string, revision int64) (models.Car, error) {
+	return m.DeleteFn(id, revision)
+}
+
+func (m *MockCarRepository) UpdateBatch(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+	return m.UpdateBatchFn(sel, patch, dryRun)
+}
+
+func (m *MockCarRepository) DeleteBatch(sel models.CarSelector, dryRun bool) (models.Cars, error) {
+	return m.DeleteBatchFn(sel, dryRun)
+}
+
+func (m *MockCarRepository) Restore(id string) (models.Car, error) {
+	return m.RestoreFn(id)
+}
+
+func (m *MockCarRepository) Purge(before time.Time) (int, error) {
+	return m.PurgeFn(before)
+}
+
+type MockAuditStore struct {
+	AppendFn  func(entries ...models.CarAuditEntry) error
+	HistoryFn func(carID string) ([]models.CarAuditEntry, error)
+}
+
+func (m *MockAuditStore) Append(entries ...models.CarAuditEntry) error {
+	return m.AppendFn(entries...)
+}
+
+func (m *MockAuditStore) History(carID string) ([]models.CarAuditEntry, error) {
+	return m.HistoryFn(carID)
+}
+
+// recordingAudit returns a MockAuditStore that collects the entries
+// appended to it in entries.
+func recordingAudit(entries *[]models.CarAuditEntry) *MockAuditStore {
+	return &MockAuditStore{
+		AppendFn: func(appended ...models.CarAuditEntry) error {
+			*entries = append(*entries, appended...)
+			return nil
+		},
+	}
 }
 
 func TestDefaultCarService_Find(t *testing.T) {
@@ -55,7 +146,38 @@ func TestDefaultCarService_Find(t *testing.T) {
 		}
 
 		repo := &MockCarRepository{
-			FindFn: func(id string) (models.Car, error) {
+			FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+				return expected, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		got, err := service.Find(expected.ID, false)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got != expected {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+	})
+
+	t.Run("should find deleted car when asked to include it", func(t *testing.T) {
+		// Arrange
+		deletedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		expected := models.Car{ID: "1", Make: "Toyota", DeletedAt: &deletedAt}
+
+		repo := &MockCarRepository{
+			FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+				if !includeDeleted {
+					t.Fatal("expected deleted cars to be included")
+				}
 				return expected, nil
 			},
 		}
@@ -65,7 +187,7 @@ func TestDefaultCarService_Find(t *testing.T) {
 		}
 
 		// Act
-		got, err := service.Find(expected.ID)
+		got, err := service.Find(expected.ID, true)
 
 		// Assert
 		if err != nil {
@@ -80,7 +202,7 @@ func TestDefaultCarService_Find(t *testing.T) {
 	t.Run("should return car not found error when repository returns ErrCarNotFound", func(t *testing.T) {
 		// Arrange
 		repo := &MockCarRepository{
-			FindFn: func(id string) (models.Car, error) {
+			FindFn: func(id string, includeDeleted bool) (models.Car, error) {
 				return models.Car{}, e.ErrCarNotFound
 			},
 		}
@@ -90,7 +212,7 @@ func TestDefaultCarService_Find(t *testing.T) {
 		}
 
 		// Act
-		_, err := service.Find("missing-id")
+		_, err := service.Find("missing-id", false)
 
 		// Assert
 		if err == nil {
@@ -112,7 +234,7 @@ func TestDefaultCarService_Find(t *testing.T) {
 		expectedErr := errors.New("database unavailable")
 
 		repo := &MockCarRepository{
-			FindFn: func(id string) (models.Car, error) {
+			FindFn: func(id string, includeDeleted bool) (models.Car, error) {
 				return models.Car{}, expectedErr
 			},
 		}
@@ -122,7 +244,7 @@ func TestDefaultCarService_Find(t *testing.T) {
 		}
 
 		// Act
-		_, err := service.Find("1")
+		_, err := service.Find("1", false)
 
 		// Assert
 		if err == nil {
@@ -157,12 +279,12 @@ func TestDefaultCarService_List(t *testing.T) {
 		}
 
 		filters := models.CarFilters{
-			Make: "Toyota",
+			Make: []string{"Toyota"},
 		}
 
 		repo := &MockCarRepository{
 			ListFn: func(f models.CarFilters) (models.Cars, error) {
-				if f != filters {
+				if !reflect.DeepEqual(f, filters) {
 					t.Fatalf("expected filters %+v, got %+v", filters, f)
 				}
 
@@ -182,18 +304,21 @@ func TestDefaultCarService_List(t *testing.T) {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
-		if !reflect.DeepEqual(got, expected) {
-			t.Fatalf("expected %+v, got %+v", expected, got)
+		if !reflect.DeepEqual(got.Cars, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, got.Cars)
+		}
+
+		if got.Next != nil {
+			t.Fatalf("expected no next cursor, got %+v", got.Next)
 		}
 	})
 
-	t.Run("should return internal error when repository fails", func(t *testing.T) {
+	t.Run("should return validation error for inverted range", func(t *testing.T) {
 		// Arrange
-		expectedErr := errors.New("database unavailable")
-
 		repo := &MockCarRepository{
 			ListFn: func(f models.CarFilters) (models.Cars, error) {
-				return models.Cars{}, expectedErr
+				t.Fatal("repository must not be called")
+				return nil, nil
 			},
 		}
 
@@ -202,41 +327,31 @@ func TestDefaultCarService_List(t *testing.T) {
 		}
 
 		// Act
-		_, err := service.List(models.CarFilters{})
+		_, err := service.List(models.CarFilters{MinPrice: u.Ptr(int64(2)), MaxPrice: u.Ptr(int64(1))})
 
 		// Assert
-		if err == nil {
-			t.Fatal("expected error but got nil")
-		}
-
 		var serviceError *e.ServiceError
 		if !errors.As(err, &serviceError) {
 			t.Fatalf("expected ServiceError, got %T", err)
 		}
 
-		if serviceError.Code != e.CodeInternalError {
-			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		if serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
 		}
-	})
-}
 
-func TestDefaultCarService_Create(t *testing.T) {
-	t.Run("should create car when validation succeeds", func(t *testing.T) {
-		// Arrange
-		car := &models.Car{
-			Make:     "Toyota",
-			Model:    "Corolla",
-			Color:    "Gray",
-			Category: "Sedan",
-			Year:     2026,
+		if !errors.Is(serviceError.Err, models.ErrInvalidRange) {
+			t.Fatalf("expected %v, got %v", models.ErrInvalidRange, serviceError.Err)
 		}
+	})
 
+	t.Run("should request one extra car and return next cursor when more cars exist", func(t *testing.T) {
+		// Arrange
 		repo := &MockCarRepository{
-			CreateFn: func(c *models.Car) error {
-				if c != car {
-					t.Fatal("expected same car pointer to be passed to repository")
+			ListFn: func(f models.CarFilters) (models.Cars, error) {
+				if f.Limit != 3 {
+					t.Fatalf("expected repository limit %d, got %d", 3, f.Limit)
 				}
-				return nil
+				return models.Cars{{ID: "a"}, {ID: "b"}, {ID: "c"}}, nil
 			},
 		}
 
@@ -245,22 +360,31 @@ func TestDefaultCarService_Create(t *testing.T) {
 		}
 
 		// Act
-		err := service.Create(car)
+		got, err := service.List(models.CarFilters{Limit: 2})
 
 		// Assert
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
+
+		if !reflect.DeepEqual(got.Cars, models.Cars{{ID: "a"}, {ID: "b"}}) {
+			t.Fatalf("expected first two cars, got %+v", got.Cars)
+		}
+
+		if got.Next == nil || got.Next.ID != "b" {
+			t.Fatalf("expected next cursor after %q, got %+v", "b", got.Next)
+		}
 	})
 
-	t.Run("should return validation error when car is invalid", func(t *testing.T) {
+	t.Run("should record sort key values in next cursor", func(t *testing.T) {
 		// Arrange
-		car := &models.Car{}
-
+		sort := []models.CarSort{{Field: models.SortByPrice, Desc: true}, {Field: models.SortByYear}}
 		repo := &MockCarRepository{
-			CreateFn: func(c *models.Car) error {
-				t.Fatal("repository Create should not be called")
-				return nil
+			ListFn: func(f models.CarFilters) (models.Cars, error) {
+				return models.Cars{
+					{ID: "a", Make: "Ford", Year: 2020, Price: u.Ptr(int64(300))},
+					{ID: "b", Make: "Kia", Year: 2019},
+				}, nil
 			},
 		}
 
@@ -269,38 +393,27 @@ func TestDefaultCarService_Create(t *testing.T) {
 		}
 
 		// Act
-		err := service.Create(car)
+		got, err := service.List(models.CarFilters{Sort: sort, Limit: 1})
 
 		// Assert
-		if err == nil {
-			t.Fatal("expected error but got nil")
-		}
-
-		var serviceError *e.ServiceError
-		if !errors.As(err, &serviceError) {
-			t.Fatalf("expected ServiceError, got %T", err)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
 
-		if serviceError.Code != e.CodeValidationFailed {
-			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
+		expected := &models.CarCursor{ID: "a", Sort: sort, Year: 2020, Price: u.Ptr(int64(300))}
+		if !reflect.DeepEqual(got.Next, expected) {
+			t.Fatalf("expected next cursor %+v, got %+v", expected, got.Next)
 		}
 	})
 
-	t.Run("should return internal error when repository fails", func(t *testing.T) {
+	t.Run("should record relevance in next cursor of ranked search", func(t *testing.T) {
 		// Arrange
-		car := &models.Car{
-			Make:     "Toyota",
-			Model:    "Corolla",
-			Color:    "Gray",
-			Category: "Sedan",
-			Year:     2026,
-		}
-
-		expectedErr := errors.New("database unavailable")
-
 		repo := &MockCarRepository{
-			CreateFn: func(c *models.Car) error {
-				return expectedErr
+			ListFn: func(f models.CarFilters) (models.Cars, error) {
+				return models.Cars{
+					{ID: "a", Make: "Toyota", Model: "Rav4", Color: "Red", Category: "SUV"},
+					{ID: "b", Make: "Toyota", Model: "Camry", Color: "Red", Category: "Sedan"},
+				}, nil
 			},
 		}
 
@@ -309,42 +422,23 @@ func TestDefaultCarService_Create(t *testing.T) {
 		}
 
 		// Act
-		err := service.Create(car)
+		got, err := service.List(models.CarFilters{Query: "red toyota", Limit: 1})
 
 		// Assert
-		if err == nil {
-			t.Fatal("expected error but got nil")
-		}
-
-		var serviceError *e.ServiceError
-		if !errors.As(err, &serviceError) {
-			t.Fatalf("expected ServiceError, got %T", err)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
 
-		if serviceError.Code != e.CodeInternalError {
-			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		if got.Next == nil || got.Next.ID != "a" || got.Next.Score != 4 {
+			t.Fatalf("expected next cursor after %q with score 4, got %+v", "a", got.Next)
 		}
 	})
-}
 
-func TestDefaultCarService_Update(t *testing.T) {
-	t.Run("should update car when validation succeeds", func(t *testing.T) {
+	t.Run("should not return next cursor on the last page", func(t *testing.T) {
 		// Arrange
-		car := &models.Car{
-			ID:       "1",
-			Make:     "Toyota",
-			Model:    "Corolla",
-			Color:    "Gray",
-			Category: "Sedan",
-			Year:     2026,
-		}
-
 		repo := &MockCarRepository{
-			UpdateFn: func(c *models.Car) error {
-				if c != car {
-					t.Fatal("expected same car pointer to be passed to repository")
-				}
-				return nil
+			ListFn: func(f models.CarFilters) (models.Cars, error) {
+				return models.Cars{{ID: "a"}, {ID: "b"}}, nil
 			},
 		}
 
@@ -353,22 +447,29 @@ func TestDefaultCarService_Update(t *testing.T) {
 		}
 
 		// Act
-		err := service.Update(car)
+		got, err := service.List(models.CarFilters{Limit: 2})
 
 		// Assert
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
+
+		if len(got.Cars) != 2 {
+			t.Fatalf("expected 2 cars, got %d", len(got.Cars))
+		}
+
+		if got.Next != nil {
+			t.Fatalf("expected no next cursor, got %+v", got.Next)
+		}
 	})
 
-	t.Run("should return validation error when car is invalid", func(t *testing.T) {
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
 		// Arrange
-		car := &models.Car{}
+		expectedErr := errors.New("database unavailable")
 
 		repo := &MockCarRepository{
-			UpdateFn: func(c *models.Car) error {
-				t.Fatal("repository Update should not be called")
-				return nil
+			ListFn: func(f models.CarFilters) (models.Cars, error) {
+				return models.Cars{}, expectedErr
 			},
 		}
 
@@ -377,7 +478,7 @@ func TestDefaultCarService_Update(t *testing.T) {
 		}
 
 		// Act
-		err := service.Update(car)
+		_, err := service.List(models.CarFilters{})
 
 		// Assert
 		if err == nil {
@@ -389,25 +490,29 @@ func TestDefaultCarService_Update(t *testing.T) {
 			t.Fatalf("expected ServiceError, got %T", err)
 		}
 
-		if serviceError.Code != e.CodeValidationFailed {
-			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
 		}
 	})
+}
 
-	t.Run("should return car not found error when repository returns ErrCarNotFound", func(t *testing.T) {
+func TestDefaultCarService_Each(t *testing.T) {
+	t.Run("should pass every car visited by the repository to fn", func(t *testing.T) {
 		// Arrange
-		car := &models.Car{
-			ID:       "1",
-			Make:     "Toyota",
-			Model:    "Corolla",
-			Color:    "Gray",
-			Category: "Sedan",
-			Year:     2026,
-		}
+		filters := models.CarFilters{Make: []string{"Toyota"}}
 
 		repo := &MockCarRepository{
-			UpdateFn: func(c *models.Car) error {
-				return e.ErrCarNotFound
+			EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+				if !reflect.DeepEqual(f, filters) {
+					t.Fatalf("expected filters %+v, got %+v", filters, f)
+				}
+
+				for _, id := range []string{"a", "b", "c"} {
+					if !fn(models.Car{ID: id}) {
+						break
+					}
+				}
+				return nil
 			},
 		}
 
@@ -416,39 +521,55 @@ func TestDefaultCarService_Update(t *testing.T) {
 		}
 
 		// Act
-		err := service.Update(car)
+		var got []string
+		err := service.Each(filters, func(car models.Car) bool {
+			got = append(got, car.ID)
+			return car.ID != "b"
+		})
 
 		// Assert
-		if err == nil {
-			t.Fatal("expected error but got nil")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expected := []string{"a", "b"}; !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("should return validation error for inverted range", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+				t.Fatal("repository must not be called")
+				return nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
 		}
 
+		// Act
+		err := service.Each(models.CarFilters{MinYear: u.Ptr(2020), MaxYear: u.Ptr(2010)}, func(models.Car) bool { return true })
+
+		// Assert
 		var serviceError *e.ServiceError
 		if !errors.As(err, &serviceError) {
 			t.Fatalf("expected ServiceError, got %T", err)
 		}
 
-		if serviceError.Code != e.CodeCarNotFound {
-			t.Fatalf("expected CAR_NOT_FOUND, got %v", serviceError.Code)
+		if serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
 		}
 	})
 
-	t.Run("should return internal error when repository fails unexpectedly", func(t *testing.T) {
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
 		// Arrange
-		car := &models.Car{
-			ID:       "1",
-			Make:     "Toyota",
-			Model:    "Corolla",
-			Color:    "Gray",
-			Category: "Sedan",
-			Year:     2026,
-		}
-
-		expectedErr := errors.New("database unavailable")
-
+		repoErr := errors.New("db down")
 		repo := &MockCarRepository{
-			UpdateFn: func(c *models.Car) error {
-				return expectedErr
+			EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+				return repoErr
 			},
 		}
 
@@ -457,13 +578,9 @@ func TestDefaultCarService_Update(t *testing.T) {
 		}
 
 		// Act
-		err := service.Update(car)
+		err := service.Each(models.CarFilters{}, func(models.Car) bool { return true })
 
 		// Assert
-		if err == nil {
-			t.Fatal("expected error but got nil")
-		}
-
 		var serviceError *e.ServiceError
 		if !errors.As(err, &serviceError) {
 			t.Fatalf("expected ServiceError, got %T", err)
@@ -472,19 +589,28 @@ func TestDefaultCarService_Update(t *testing.T) {
 		if serviceError.Code != e.CodeInternalError {
 			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
 		}
+
+		if !errors.Is(serviceError.Err, repoErr) {
+			t.Fatalf("expected %v, got %v", repoErr, serviceError.Err)
+		}
 	})
 }
 
-func TestDefaultCarService_Delete(t *testing.T) {
-	t.Run("should delete car when repository succeeds", func(t *testing.T) {
+func TestDefaultCarService_Stats(t *testing.T) {
+	t.Run("should summarise every car matching the filters", func(t *testing.T) {
 		// Arrange
-		expectedID := "1"
+		keys, _ := models.ParseCarSort("-price")
+		filters := models.CarFilters{Make: []string{"Toyota"}, Sort: keys, Limit: 10}
 
 		repo := &MockCarRepository{
-			DeleteFn: func(id string) error {
-				if id != expectedID {
-					t.Fatalf("expected id %q, got %q", expectedID, id)
+			EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+				expected := models.CarFilters{Make: []string{"Toyota"}}
+				if !reflect.DeepEqual(f, expected) {
+					t.Fatalf("expected filters %+v, got %+v", expected, f)
 				}
+
+				fn(models.Car{Make: "Toyota", Model: "Camry", Year: 2019, Price: u.Ptr(int64(100))})
+				fn(models.Car{Make: "Toyota", Model: "Rav4", Year: 2019, Price: u.Ptr(int64(201))})
 				return nil
 			},
 		}
@@ -494,19 +620,32 @@ func TestDefaultCarService_Delete(t *testing.T) {
 		}
 
 		// Act
-		err := service.Delete(expectedID)
+		got, err := service.Stats(filters)
 
 		// Assert
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
+
+		if got.Count != 2 {
+			t.Fatalf("expected count 2, got %d", got.Count)
+		}
+
+		if expected := []models.Facet[int]{{Value: 2019, Count: 2}}; !reflect.DeepEqual(got.Year, expected) {
+			t.Fatalf("expected year facets %+v, got %+v", expected, got.Year)
+		}
+
+		if expected := (&models.CarValueStats{Count: 2, Min: 100, Max: 201, Avg: 151}); !reflect.DeepEqual(got.Price, expected) {
+			t.Fatalf("expected price stats %+v, got %+v", expected, got.Price)
+		}
 	})
 
-	t.Run("should return car not found error when repository returns ErrCarNotFound", func(t *testing.T) {
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
 		// Arrange
+		repoErr := errors.New("db down")
 		repo := &MockCarRepository{
-			DeleteFn: func(id string) error {
-				return e.ErrCarNotFound
+			EachFn: func(f models.CarFilters, fn func(models.Car) bool) error {
+				return repoErr
 			},
 		}
 
@@ -515,30 +654,1106 @@ func TestDefaultCarService_Delete(t *testing.T) {
 		}
 
 		// Act
-		err := service.Delete("missing-id")
+		_, err := service.Stats(models.CarFilters{})
 
 		// Assert
-		if err == nil {
-			t.Fatal("expected error but got nil")
-		}
-
 		var serviceError *e.ServiceError
 		if !errors.As(err, &serviceError) {
 			t.Fatalf("expected ServiceError, got %T", err)
 		}
 
-		if serviceError.Code != e.CodeCarNotFound {
-			t.Fatalf("expected CAR_NOT_FOUND, got %v", serviceError.Code)
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		}
+
+		if !errors.Is(serviceError.Err, repoErr) {
+			t.Fatalf("expected %v, got %v", repoErr, serviceError.Err)
 		}
 	})
+}
 
-	t.Run("should return internal error when repository fails unexpectedly", func(t *testing.T) {
+func TestDefaultCarService_Makes(t *testing.T) {
+	t.Run("should return the makes of the repository", func(t *testing.T) {
 		// Arrange
-		expectedErr := errors.New("database unavailable")
-
+		expected := []models.Facet[string]{{Value: "Ford", Count: 1}, {Value: "Toyota", Count: 2}}
 		repo := &MockCarRepository{
-			DeleteFn: func(id string) error {
-				return expectedErr
+			MakesFn: func() ([]models.Facet[string], error) {
+				return expected, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		got, err := service.Makes()
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+	})
+
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
+		// Arrange
+		repoErr := errors.New("db down")
+		repo := &MockCarRepository{
+			MakesFn: func() ([]models.Facet[string], error) {
+				return nil, repoErr
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Makes()
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		}
+
+		if !errors.Is(serviceError.Err, repoErr) {
+			t.Fatalf("expected %v, got %v", repoErr, serviceError.Err)
+		}
+	})
+}
+
+func TestDefaultCarService_Models(t *testing.T) {
+	repoErr := errors.New("db down")
+
+	tCases := []struct {
+		name         string
+		repoErr      error
+		expectedCode string
+		expectedErr  error
+	}{
+		{
+			name: "should return the models of the make",
+		},
+		{
+			name:         "should return not found when make has no cars",
+			repoErr:      e.ErrMakeNotFound,
+			expectedCode: e.CodeMakeNotFound,
+			expectedErr:  e.ErrMakeNotFound,
+		},
+		{
+			name:         "should return internal error when repository fails",
+			repoErr:      repoErr,
+			expectedCode: e.CodeInternalError,
+			expectedErr:  repoErr,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			expected := []models.Facet[string]{{Value: "Camry", Count: 2}}
+			repo := &MockCarRepository{
+				ModelsFn: func(make string) ([]models.Facet[string], error) {
+					if make != "Toyota" {
+						t.Fatalf("expected make Toyota, got %q", make)
+					}
+					if tc.repoErr != nil {
+						return nil, tc.repoErr
+					}
+					return expected, nil
+				},
+			}
+
+			service := &DefaultCarService{
+				repo: repo,
+			}
+
+			// Act
+			got, err := service.Models("Toyota")
+
+			// Assert
+			if tc.expectedErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("expected %+v, got %+v", expected, got)
+				}
+				return
+			}
+
+			var serviceError *e.ServiceError
+			if !errors.As(err, &serviceError) {
+				t.Fatalf("expected ServiceError, got %T", err)
+			}
+
+			if serviceError.Code != tc.expectedCode {
+				t.Fatalf("expected %s, got %v", tc.expectedCode, serviceError.Code)
+			}
+
+			if !errors.Is(serviceError.Err, tc.expectedErr) {
+				t.Fatalf("expected %v, got %v", tc.expectedErr, serviceError.Err)
+			}
+		})
+	}
+}
+
+func TestDefaultCarService_Create(t *testing.T) {
+	t.Run("should create car when validation succeeds", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2026,
+		}
+
+		repo := &MockCarRepository{
+			CreateFn: func(c *models.Car) error {
+				if c != car {
+					t.Fatal("expected same car pointer to be passed to repository")
+				}
+				return nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Create(t.Context(), car)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should return validation error when car is invalid", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{}
+
+		repo := &MockCarRepository{
+			CreateFn: func(c *models.Car) error {
+				t.Fatal("repository Create should not be called")
+				return nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Create(t.Context(), car)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2026,
+		}
+
+		expectedErr := errors.New("database unavailable")
+
+		repo := &MockCarRepository{
+			CreateFn: func(c *models.Car) error {
+				return expectedErr
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Create(t.Context(), car)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		}
+	})
+}
+
+func TestDefaultCarService_CreateBatch(t *testing.T) {
+	valid := func(model string) models.Car {
+		return models.Car{Make: "Toyota", Model: model, Color: "Gray", Category: "Sedan", Year: 2026}
+	}
+
+	// fakeRepo assigns sequential IDs to the cars it is asked to create.
+	fakeRepo := func(calls *int) *MockCarRepository {
+		return &MockCarRepository{
+			CreateBatchFn: func(cars models.Cars) error {
+				*calls++
+				for i := range cars {
+					cars[i].ID = cars[i].Model + "-id"
+					cars[i].Revision = 1
+				}
+				return nil
+			},
+		}
+	}
+
+	t.Run("should create every car when all are valid", func(t *testing.T) {
+		// Arrange
+		var calls int
+		service := &DefaultCarService{repo: fakeRepo(&calls)}
+		cars := models.Cars{valid("Corolla"), valid("Camry")}
+
+		// Act
+		errs, err := service.CreateBatch(t.Context(), cars, false)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if calls != 1 {
+			t.Fatalf("expected one repository call, got %d", calls)
+		}
+
+		if !reflect.DeepEqual(errs, []error{nil, nil}) {
+			t.Fatalf("expected no item errors, got %v", errs)
+		}
+
+		if cars[0].ID != "Corolla-id" || cars[1].ID != "Camry-id" {
+			t.Fatalf("expected generated IDs to be set, got %+v", cars)
+		}
+	})
+
+	t.Run("should create nothing when an item is invalid and batch is atomic", func(t *testing.T) {
+		// Arrange
+		var calls int
+		service := &DefaultCarService{repo: fakeRepo(&calls)}
+		cars := models.Cars{valid("Corolla"), {Make: "Toyota"}}
+
+		// Act
+		_, err := service.CreateBatch(t.Context(), cars, false)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", err)
+		}
+
+		if !errors.Is(serviceError.Err, models.ErrCarModelRequired) {
+			t.Fatalf("expected %v, got %v", models.ErrCarModelRequired, serviceError.Err)
+		}
+
+		if serviceError.Details() != "item 1: model is required" {
+			t.Fatalf("expected details to name the item, got %q", serviceError.Details())
+		}
+
+		if calls != 0 {
+			t.Fatal("repository CreateBatch should not be called")
+		}
+	})
+
+	t.Run("should create valid cars and report invalid ones when batch is partial", func(t *testing.T) {
+		// Arrange
+		var calls int
+		service := &DefaultCarService{repo: fakeRepo(&calls)}
+		cars := models.Cars{valid("Corolla"), {Make: "Toyota"}, valid("Camry")}
+
+		// Act
+		errs, err := service.CreateBatch(t.Context(), cars, true)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if errs[0] != nil || errs[2] != nil {
+			t.Fatalf("expected valid items to succeed, got %v", errs)
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(errs[1], &serviceError) || serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED for item 1, got %v", errs[1])
+		}
+
+		if cars[0].ID != "Corolla-id" || cars[1].ID != "" || cars[2].ID != "Camry-id" {
+			t.Fatalf("expected IDs only on created cars, got %+v", cars)
+		}
+	})
+
+	t.Run("should not call repository when no item is valid", func(t *testing.T) {
+		// Arrange
+		var calls int
+		service := &DefaultCarService{repo: fakeRepo(&calls)}
+
+		// Act
+		errs, err := service.CreateBatch(t.Context(), models.Cars{{}}, true)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if errs[0] == nil {
+			t.Fatal("expected item error")
+		}
+
+		if calls != 0 {
+			t.Fatal("repository CreateBatch should not be called")
+		}
+	})
+
+	t.Run("should reject empty and oversized batches", func(t *testing.T) {
+		for _, n := range []int{0, MaxCarBatchSize + 1} {
+			// Arrange
+			var calls int
+			service := &DefaultCarService{repo: fakeRepo(&calls)}
+			cars := make(models.Cars, n)
+			for i := range cars {
+				cars[i] = valid("Corolla")
+			}
+
+			// Act
+			_, err := service.CreateBatch(t.Context(), cars, true)
+
+			// Assert
+			var serviceError *e.ServiceError
+			if !errors.As(err, &serviceError) || serviceError.Code != e.CodeValidationFailed {
+				t.Fatalf("expected VALIDATION_FAILED for %d cars, got %v", n, err)
+			}
+		}
+	})
+
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
+		// Arrange
+		service := &DefaultCarService{
+			repo: &MockCarRepository{
+				CreateBatchFn: func(cars models.Cars) error {
+					return errors.New("database unavailable")
+				},
+			},
+		}
+
+		// Act
+		_, err := service.CreateBatch(t.Context(), models.Cars{valid("Corolla")}, true)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", err)
+		}
+	})
+}
+
+func TestDefaultCarService_Import(t *testing.T) {
+	valid := models.Car{Make: "Toyota", Model: "Corolla", Color: "Gray", Category: "Sedan", Year: 2026}
+
+	t.Run("should store more cars than a batch holds at once", func(t *testing.T) {
+		// Arrange
+		var calls, stored int
+		service := &DefaultCarService{
+			repo: &MockCarRepository{
+				CreateBatchFn: func(cars models.Cars) error {
+					calls++
+					stored += len(cars)
+					return nil
+				},
+			},
+		}
+
+		cars := make(models.Cars, MaxCarBatchSize+500)
+		for i := range cars {
+			cars[i] = valid
+		}
+		cars[3].Model = ""
+
+		// Act
+		errs, err := service.Import(t.Context(), cars, true)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if calls != 1 || stored != len(cars)-1 {
+			t.Fatalf("expected %d cars stored in one call, got %d in %d", len(cars)-1, stored, calls)
+		}
+
+		if errs[3] == nil || slices.ContainsFunc(errs[4:], func(err error) bool { return err != nil }) {
+			t.Fatalf("expected only car 3 to be rejected, got %v", errs[:5])
+		}
+	})
+
+	t.Run("should reject an empty import", func(t *testing.T) {
+		// Arrange
+		service := &DefaultCarService{repo: &MockCarRepository{}}
+
+		// Act
+		_, err := service.Import(t.Context(), nil, false)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", err)
+		}
+	})
+}
+
+func TestDefaultCarService_Update(t *testing.T) {
+	t.Run("should update car when validation succeeds", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{
+			ID:       "1",
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2026,
+		}
+
+		repo := &MockCarRepository{
+			UpdateFn: func(c *models.Car) (models.Car, error) {
+				if c != car {
+					t.Fatal("expected same car pointer to be passed to repository")
+				}
+				return models.Car{}, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Update(t.Context(), car)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should return validation error when car is invalid", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{}
+
+		repo := &MockCarRepository{
+			UpdateFn: func(c *models.Car) (models.Car, error) {
+				t.Fatal("repository Update should not be called")
+				return models.Car{}, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Update(t.Context(), car)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return car not found error when repository returns ErrCarNotFound", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{
+			ID:       "1",
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2026,
+		}
+
+		repo := &MockCarRepository{
+			UpdateFn: func(c *models.Car) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Update(t.Context(), car)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeCarNotFound {
+			t.Fatalf("expected CAR_NOT_FOUND, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return precondition failed error when revision does not match", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{
+			ID:       "1",
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2026,
+			Revision: 3,
+		}
+
+		repo := &MockCarRepository{
+			UpdateFn: func(c *models.Car) (models.Car, error) {
+				return models.Car{}, e.ErrRevisionMismatch
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Update(t.Context(), car)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodePreconditionFailed {
+			t.Fatalf("expected PRECONDITION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return internal error when repository fails unexpectedly", func(t *testing.T) {
+		// Arrange
+		car := &models.Car{
+			ID:       "1",
+			Make:     "Toyota",
+			Model:    "Corolla",
+			Color:    "Gray",
+			Category: "Sedan",
+			Year:     2026,
+		}
+
+		expectedErr := errors.New("database unavailable")
+
+		repo := &MockCarRepository{
+			UpdateFn: func(c *models.Car) (models.Car, error) {
+				return models.Car{}, expectedErr
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Update(t.Context(), car)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		}
+	})
+}
+
+func TestDefaultCarService_Upsert(t *testing.T) {
+	newCar := func(id string, revision int64) *models.Car {
+		return &models.Car{ID: id, Make: "Toyota", Model: "Corolla", Color: "Gray", Category: "Sedan", Year: 2024, Revision: revision}
+	}
+
+	tCases := []struct {
+		name            string
+		car             *models.Car
+		createOnly      bool
+		updateErr       error
+		insertErr       error
+		expectedCreated bool
+		expectedAction  models.CarAuditAction
+		expectedCode    string
+	}{
+		{
+			name:           "should replace an existing car",
+			car:            newCar("JHK290XJ", 0),
+			expectedAction: models.CarUpdated,
+		},
+		{
+			name:            "should create a missing car",
+			car:             newCar("JHK290XJ", 0),
+			updateErr:       e.ErrCarNotFound,
+			expectedCreated: true,
+			expectedAction:  models.CarCreated,
+		},
+		{
+			name:            "should only create when createOnly is set",
+			car:             newCar("JHK290XJ", 0),
+			createOnly:      true,
+			expectedCreated: true,
+			expectedAction:  models.CarCreated,
+		},
+		{
+			name:         "should return precondition failed when createOnly is set and the ID is taken",
+			car:          newCar("JHK290XJ", 0),
+			createOnly:   true,
+			insertErr:    e.ErrCarExists,
+			expectedCode: e.CodePreconditionFailed,
+		},
+		{
+			name:         "should return conflict when the ID is taken by a deleted car",
+			car:          newCar("JHK290XJ", 0),
+			updateErr:    e.ErrCarNotFound,
+			insertErr:    e.ErrCarExists,
+			expectedCode: e.CodeCarExists,
+		},
+		{
+			name:         "should not create a car when a revision is required",
+			car:          newCar("JHK290XJ", 3),
+			updateErr:    e.ErrCarNotFound,
+			expectedCode: e.CodeCarNotFound,
+		},
+		{
+			name:         "should return validation error for an invalid ID",
+			car:          newCar("JHK 290", 0),
+			expectedCode: e.CodeValidationFailed,
+		},
+		{
+			name:         "should return internal error when the repository fails",
+			car:          newCar("JHK290XJ", 0),
+			updateErr:    e.ErrCarNotFound,
+			insertErr:    errors.New("db down"),
+			expectedCode: e.CodeInternalError,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := &MockCarRepository{
+				UpdateFn: func(c *models.Car) (models.Car, error) {
+					if tc.createOnly {
+						t.Fatal("repository Update should not be called")
+					}
+					if tc.updateErr != nil {
+						return models.Car{}, tc.updateErr
+					}
+					c.Revision = 2
+					return *newCar(c.ID, 1), nil
+				},
+				InsertFn: func(c *models.Car) error {
+					if tc.car.Revision != 0 {
+						t.Fatal("repository Insert should not be called")
+					}
+					if tc.insertErr != nil {
+						return tc.insertErr
+					}
+					c.Revision = 1
+					return nil
+				},
+			}
+
+			var entries []models.CarAuditEntry
+			service := &DefaultCarService{repo: repo, audit: recordingAudit(&entries)}
+
+			// Act
+			created, err := service.Upsert(t.Context(), tc.car, tc.createOnly)
+
+			// Assert
+			if tc.expectedCode != "" {
+				var serviceError *e.ServiceError
+				if !errors.As(err, &serviceError) {
+					t.Fatalf("expected ServiceError, got %T", err)
+				}
+
+				if serviceError.Code != tc.expectedCode {
+					t.Fatalf("expected %s, got %v", tc.expectedCode, serviceError.Code)
+				}
+
+				if len(entries) != 0 {
+					t.Fatalf("expected no audit entry, got %+v", entries)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if created != tc.expectedCreated {
+				t.Fatalf("expected created %v, got %v", tc.expectedCreated, created)
+			}
+
+			if len(entries) != 1 || entries[0].Action != tc.expectedAction || entries[0].CarID != tc.car.ID {
+				t.Fatalf("expected one %s entry for %s, got %+v", tc.expectedAction, tc.car.ID, entries)
+			}
+		})
+	}
+}
+
+func TestDefaultCarService_Patch(t *testing.T) {
+	stored := models.Car{
+		ID:       "1",
+		Make:     "Toyota",
+		Model:    "Corolla",
+		Color:    "Gray",
+		Category: "Sedan",
+		Year:     2024,
+		Revision: 2,
+	}
+
+	// patchStored emulates the repository by applying the patch to a copy
+	// of the stored car.
+	patchStored := func(id string, patch models.CarPatchFunc) (models.Car, error) {
+		car := stored
+		if err := patch(&car); err != nil {
+			return models.Car{}, err
+		}
+		return car, nil
+	}
+
+	t.Run("should apply patch atomically through the repository", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			PatchFn: func(id string, patch models.CarPatchFunc) (models.Car, error) {
+				if id != "1" {
+					t.Fatalf("expected id %q, got %q", "1", id)
+				}
+				return patchStored(id, patch)
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		got, err := service.Patch(t.Context(), "1", 0, func(c *models.Car) error {
+			c.Color = "Red"
+			c.ID = "tampered"
+			return nil
+		})
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := stored
+		expected.Color = "Red"
+
+		if got != expected {
+			t.Fatalf("expected %+v, got %+v", expected, got)
+		}
+	})
+
+	t.Run("should return car not found error when car does not exist", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			PatchFn: func(id string, patch models.CarPatchFunc) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Patch(t.Context(), "missing-id", 0, func(c *models.Car) error {
+			return nil
+		})
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeCarNotFound {
+			t.Fatalf("expected CAR_NOT_FOUND, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return invalid request body error when patch fails", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{PatchFn: patchStored}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Patch(t.Context(), "1", 0, func(c *models.Car) error {
+			return errors.New("bad patch")
+		})
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeInvalidRequestBody {
+			t.Fatalf("expected INVALID_REQUEST_BODY, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return conflict error when a test operation fails", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{PatchFn: patchStored}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Patch(t.Context(), "1", 0, func(c *models.Car) error {
+			return fmt.Errorf("operation 0: %w", jsonpatch.ErrTestFailed)
+		})
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodePatchTestFailed {
+			t.Fatalf("expected PATCH_TEST_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return validation error when patched car is invalid", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{PatchFn: patchStored}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Patch(t.Context(), "1", 0, func(c *models.Car) error {
+			c.Make = ""
+			return nil
+		})
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return precondition failed error when revision does not match", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{PatchFn: patchStored}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Patch(t.Context(), "1", stored.Revision+1, func(c *models.Car) error {
+			t.Fatal("patch should not be applied")
+			return nil
+		})
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodePreconditionFailed {
+			t.Fatalf("expected PRECONDITION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return internal error when repository fails unexpectedly", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			PatchFn: func(id string, patch models.CarPatchFunc) (models.Car, error) {
+				return models.Car{}, errors.New("database unavailable")
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Patch(t.Context(), "1", 0, func(c *models.Car) error {
+			return nil
+		})
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		}
+	})
+}
+
+func TestDefaultCarService_Delete(t *testing.T) {
+	t.Run("should delete car when repository succeeds", func(t *testing.T) {
+		// Arrange
+		expectedID := "1"
+
+		repo := &MockCarRepository{
+			DeleteFn: func(id string, revision int64) (models.Car, error) {
+				if id != expectedID {
+					t.Fatalf("expected id %q, got %q", expectedID, id)
+				}
+				return models.Car{}, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Delete(t.Context(), expectedID, 0)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should return car not found error when repository returns ErrCarNotFound", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			DeleteFn: func(id string, revision int64) (models.Car, error) {
+				return models.Car{}, e.ErrCarNotFound
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Delete(t.Context(), "missing-id", 0)
+
+		// Assert
+		if err == nil {
+			t.Fatal("expected error but got nil")
+		}
+
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeCarNotFound {
+			t.Fatalf("expected CAR_NOT_FOUND, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should pass revision and return precondition failed error on mismatch", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			DeleteFn: func(id string, revision int64) (models.Car, error) {
+				if revision != 4 {
+					t.Fatalf("expected revision %d, got %d", 4, revision)
+				}
+				return models.Car{}, e.ErrRevisionMismatch
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		err := service.Delete(t.Context(), "1", 4)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodePreconditionFailed {
+			t.Fatalf("expected PRECONDITION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return internal error when repository fails unexpectedly", func(t *testing.T) {
+		// Arrange
+		expectedErr := errors.New("database unavailable")
+
+		repo := &MockCarRepository{
+			DeleteFn: func(id string, revision int64) (models.Car, error) {
+				return models.Car{}, expectedErr
 			},
 		}
 
@@ -547,7 +1762,7 @@ func TestDefaultCarService_Delete(t *testing.T) {
 		}
 
 		// Act
-		err := service.Delete("1")
+		err := service.Delete(t.Context(), "1", 0)
 
 		// Assert
 		if err == nil {
@@ -564,3 +1779,934 @@ func TestDefaultCarService_Delete(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultCarService_UpdateBatch(t *testing.T) {
+	stored := models.Cars{
+		{ID: "1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010, Revision: 1},
+		{ID: "2", Make: "Ford", Model: "F150", Color: "Blue", Category: "Truck", Year: 2010, Revision: 4},
+	}
+
+	// patchAll applies the patch to a copy of every stored car, like a
+	// repository would.
+	patchAll := func(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+		patched := slices.Clone(stored)
+		for i := range patched {
+			if err := patch(&patched[i]); err != nil {
+				return nil, err
+			}
+			patched[i].Revision++
+		}
+		return patched, nil
+	}
+
+	byFilter := models.CarSelector{Filters: &models.CarFilters{Year: []int{2010}}}
+
+	t.Run("should return the number of updated cars", func(t *testing.T) {
+		// Arrange
+		var gotDryRun bool
+		repo := &MockCarRepository{
+			UpdateBatchFn: func(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+				gotDryRun = dryRun
+				return patchAll(sel, patch, dryRun)
+			},
+		}
+		service := &DefaultCarService{repo: repo}
+
+		// Act
+		n, err := service.UpdateBatch(t.Context(), byFilter, func(car *models.Car) error {
+			car.Price = u.Ptr(int64(1500000))
+			return nil
+		}, true)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if n != 2 {
+			t.Fatalf("expected 2 cars, got %d", n)
+		}
+
+		if !gotDryRun {
+			t.Fatal("expected dry run to be passed to repository")
+		}
+	})
+
+	t.Run("should return validation error naming the car left invalid", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{UpdateBatchFn: patchAll}
+		service := &DefaultCarService{repo: repo}
+
+		// Act
+		_, err := service.UpdateBatch(t.Context(), byFilter, func(car *models.Car) error {
+			if car.ID == "2" {
+				car.Year = 0
+			}
+			return nil
+		}, false)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", err)
+		}
+
+		if !errors.Is(serviceError.Err, models.ErrInvalidYear) || serviceError.Details() != "car 2: year is not valid" {
+			t.Fatalf("expected invalid year of car 2, got %v", serviceError.Err)
+		}
+	})
+
+	t.Run("should return invalid request body when patch fails", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{UpdateBatchFn: patchAll}
+		service := &DefaultCarService{repo: repo}
+
+		// Act
+		_, err := service.UpdateBatch(t.Context(), byFilter, func(car *models.Car) error {
+			return errors.New("unknown field")
+		}, false)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeInvalidRequestBody {
+			t.Fatalf("expected INVALID_REQUEST_BODY, got %v", err)
+		}
+	})
+
+	t.Run("should reject invalid selectors", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			sel      models.CarSelector
+			expected error
+		}{
+			{name: "no selector", sel: models.CarSelector{}, expected: models.ErrSelectorRequired},
+			{name: "empty filter", sel: models.CarSelector{Filters: &models.CarFilters{}}, expected: models.ErrSelectorRequired},
+			{
+				name:     "ids and filter",
+				sel:      models.CarSelector{IDs: []string{"1"}, Filters: byFilter.Filters},
+				expected: models.ErrSelectorConflict,
+			},
+			{
+				name:     "inverted range",
+				sel:      models.CarSelector{Filters: &models.CarFilters{MinYear: u.Ptr(2020), MaxYear: u.Ptr(2010)}},
+				expected: models.ErrInvalidRange,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Arrange
+				repo := &MockCarRepository{
+					UpdateBatchFn: func(models.CarSelector, models.CarPatchFunc, bool) (models.Cars, error) {
+						t.Fatal("repository UpdateBatch should not be called")
+						return nil, nil
+					},
+				}
+				service := &DefaultCarService{repo: repo}
+
+				// Act
+				_, err := service.UpdateBatch(t.Context(), tt.sel, func(*models.Car) error { return nil }, false)
+
+				// Assert
+				var serviceError *e.ServiceError
+				if !errors.As(err, &serviceError) || !errors.Is(serviceError.Err, tt.expected) {
+					t.Fatalf("expected %v, got %v", tt.expected, err)
+				}
+			})
+		}
+	})
+
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			UpdateBatchFn: func(models.CarSelector, models.CarPatchFunc, bool) (models.Cars, error) {
+				return nil, errors.New("database unavailable")
+			},
+		}
+		service := &DefaultCarService{repo: repo}
+
+		// Act
+		_, err := service.UpdateBatch(t.Context(), byFilter, func(*models.Car) error { return nil }, false)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", err)
+		}
+	})
+}
+
+func TestDefaultCarService_DeleteBatch(t *testing.T) {
+	t.Run("should return the number of deleted cars", func(t *testing.T) {
+		// Arrange
+		sel := models.CarSelector{IDs: []string{"1", "2"}}
+
+		repo := &MockCarRepository{
+			DeleteBatchFn: func(got models.CarSelector, dryRun bool) (models.Cars, error) {
+				if !reflect.DeepEqual(got, sel) || dryRun {
+					t.Fatalf("unexpected arguments %+v, %v", got, dryRun)
+				}
+				return models.Cars{{ID: "1", Revision: 2, DeletedAt: u.Ptr(time.Now())}}, nil
+			},
+		}
+		service := &DefaultCarService{repo: repo}
+
+		// Act
+		n, err := service.DeleteBatch(t.Context(), sel, false)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if n != 1 {
+			t.Fatalf("expected 1 car, got %d", n)
+		}
+	})
+
+	t.Run("should reject too many ids", func(t *testing.T) {
+		// Arrange
+		service := &DefaultCarService{repo: &MockCarRepository{}}
+
+		// Act
+		_, err := service.DeleteBatch(t.Context(), models.CarSelector{IDs: make([]string, MaxCarBatchSize+1)}, false)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", err)
+		}
+	})
+
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			DeleteBatchFn: func(models.CarSelector, bool) (models.Cars, error) {
+				return nil, errors.New("database unavailable")
+			},
+		}
+		service := &DefaultCarService{repo: repo}
+
+		// Act
+		_, err := service.DeleteBatch(t.Context(), models.CarSelector{IDs: []string{"1"}}, true)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) || serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", err)
+		}
+	})
+}
+
+func TestDefaultCarService_Restore(t *testing.T) {
+	tCases := []struct {
+		name         string
+		repoErr      error
+		expectedCode string
+	}{
+		{
+			name: "should return the restored car",
+		},
+		{
+			name:         "should return not found when car does not exist",
+			repoErr:      e.ErrCarNotFound,
+			expectedCode: e.CodeCarNotFound,
+		},
+		{
+			name:         "should return conflict when car is not deleted",
+			repoErr:      e.ErrCarNotDeleted,
+			expectedCode: e.CodeCarNotDeleted,
+		},
+		{
+			name:         "should return internal error when repository fails",
+			repoErr:      errors.New("db down"),
+			expectedCode: e.CodeInternalError,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			expected := models.Car{ID: "1", Make: "Toyota", Revision: 3}
+			repo := &MockCarRepository{
+				FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+					if !includeDeleted {
+						t.Fatal("expected deleted car to be read")
+					}
+					return models.Car{ID: id, Make: "Toyota", Revision: 2, DeletedAt: u.Ptr(time.Now())}, nil
+				},
+				RestoreFn: func(id string) (models.Car, error) {
+					if id != "1" {
+						t.Fatalf("expected id 1, got %q", id)
+					}
+					if tc.repoErr != nil {
+						return models.Car{}, tc.repoErr
+					}
+					return expected, nil
+				},
+			}
+
+			service := &DefaultCarService{
+				repo: repo,
+			}
+
+			// Act
+			got, err := service.Restore(t.Context(), "1")
+
+			// Assert
+			if tc.repoErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got != expected {
+					t.Fatalf("expected %+v, got %+v", expected, got)
+				}
+				return
+			}
+
+			var serviceError *e.ServiceError
+			if !errors.As(err, &serviceError) {
+				t.Fatalf("expected ServiceError, got %T", err)
+			}
+
+			if serviceError.Code != tc.expectedCode {
+				t.Fatalf("expected %s, got %v", tc.expectedCode, serviceError.Code)
+			}
+
+			if !errors.Is(serviceError.Err, tc.repoErr) {
+				t.Fatalf("expected %v, got %v", tc.repoErr, serviceError.Err)
+			}
+		})
+	}
+}
+
+func TestDefaultCarService_Purge(t *testing.T) {
+	t.Run("should purge cars deleted before the retention period", func(t *testing.T) {
+		// Arrange
+		retention := 30 * 24 * time.Hour
+
+		var cutoff time.Time
+		repo := &MockCarRepository{
+			PurgeFn: func(before time.Time) (int, error) {
+				cutoff = before
+				return 2, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		start := time.Now()
+		n, err := service.Purge(retention)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if n != 2 {
+			t.Fatalf("expected 2 cars purged, got %d", n)
+		}
+
+		if cutoff.Before(start.Add(-retention)) || cutoff.After(time.Now().Add(-retention)) {
+			t.Fatalf("expected cutoff %v ago, got %v", retention, cutoff)
+		}
+	})
+
+	t.Run("should return validation error when retention is negative", func(t *testing.T) {
+		// Arrange
+		repo := &MockCarRepository{
+			PurgeFn: func(before time.Time) (int, error) {
+				t.Fatal("repository Purge should not be called")
+				return 0, nil
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Purge(-time.Hour)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeValidationFailed {
+			t.Fatalf("expected VALIDATION_FAILED, got %v", serviceError.Code)
+		}
+	})
+
+	t.Run("should return internal error when repository fails", func(t *testing.T) {
+		// Arrange
+		repoErr := errors.New("db down")
+		repo := &MockCarRepository{
+			PurgeFn: func(before time.Time) (int, error) {
+				return 0, repoErr
+			},
+		}
+
+		service := &DefaultCarService{
+			repo: repo,
+		}
+
+		// Act
+		_, err := service.Purge(time.Hour)
+
+		// Assert
+		var serviceError *e.ServiceError
+		if !errors.As(err, &serviceError) {
+			t.Fatalf("expected ServiceError, got %T", err)
+		}
+
+		if serviceError.Code != e.CodeInternalError {
+			t.Fatalf("expected INTERNAL_ERROR, got %v", serviceError.Code)
+		}
+
+		if !errors.Is(serviceError.Err, repoErr) {
+			t.Fatalf("expected %v, got %v", repoErr, serviceError.Err)
+		}
+	})
+}
+
+func TestDefaultCarService_Audit(t *testing.T) {
+	stored := models.Car{ID: "1", Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010, Revision: 3}
+	deletedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	repo := &MockCarRepository{
+		CreateFn: func(car *models.Car) error {
+			car.ID, car.Revision = "1", 1
+			return nil
+		},
+		UpdateFn: func(car *models.Car) (models.Car, error) {
+			car.Revision = stored.Revision + 1
+			return stored, nil
+		},
+		PatchFn: func(id string, patch models.CarPatchFunc) (models.Car, error) {
+			car := stored
+			if err := patch(&car); err != nil {
+				return models.Car{}, err
+			}
+			car.Revision++
+			return car, nil
+		},
+		DeleteFn: func(id string, revision int64) (models.Car, error) {
+			car := stored
+			car.Revision++
+			car.DeletedAt = &deletedAt
+			return car, nil
+		},
+		FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+			car := stored
+			car.DeletedAt = &deletedAt
+			return car, nil
+		},
+		RestoreFn: func(id string) (models.Car, error) {
+			car := stored
+			car.Revision++
+			return car, nil
+		},
+	}
+
+	updated := stored
+	updated.Color = "Black"
+	deleted := stored
+	deleted.DeletedAt = &deletedAt
+
+	tCases := []struct {
+		name           string
+		write          func(ctx context.Context, s CarService) error
+		expectedAction models.CarAuditAction
+		expectedBefore *models.Car
+		expectedColor  string
+	}{
+		{
+			name: "should record the created car",
+			write: func(ctx context.Context, s CarService) error {
+				return s.Create(ctx, &models.Car{Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010})
+			},
+			expectedAction: models.CarCreated,
+			expectedColor:  "Silver",
+		},
+		{
+			name: "should record the car replaced by an update",
+			write: func(ctx context.Context, s CarService) error {
+				car := updated
+				return s.Update(ctx, &car)
+			},
+			expectedAction: models.CarUpdated,
+			expectedBefore: &stored,
+			expectedColor:  "Black",
+		},
+		{
+			name: "should record the car before a patch",
+			write: func(ctx context.Context, s CarService) error {
+				_, err := s.Patch(ctx, "1", 0, func(car *models.Car) error {
+					car.Color = "Black"
+					return nil
+				})
+				return err
+			},
+			expectedAction: models.CarUpdated,
+			expectedBefore: &stored,
+			expectedColor:  "Black",
+		},
+		{
+			name: "should record the live car before a delete",
+			write: func(ctx context.Context, s CarService) error {
+				return s.Delete(ctx, "1", 0)
+			},
+			expectedAction: models.CarDeleted,
+			expectedBefore: &stored,
+			expectedColor:  "Silver",
+		},
+		{
+			name: "should record the deleted car before a restore",
+			write: func(ctx context.Context, s CarService) error {
+				_, err := s.Restore(ctx, "1")
+				return err
+			},
+			expectedAction: models.CarRestored,
+			expectedBefore: &deleted,
+			expectedColor:  "Silver",
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			var entries []models.CarAuditEntry
+			service := &DefaultCarService{repo: repo, audit: recordingAudit(&entries)}
+
+			ctx := context.WithValue(t.Context(), contextkeys.ActorKey, "alice")
+			ctx = context.WithValue(ctx, contextkeys.RequestIDKey, "req-1")
+
+			// Act
+			start := time.Now()
+			err := tc.write(ctx, service)
+
+			// Assert
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 entry, got %+v", entries)
+			}
+			entry := entries[0]
+
+			if entry.Action != tc.expectedAction || entry.Actor != "alice" || entry.RequestID != "req-1" || entry.CarID != "1" {
+				t.Fatalf("unexpected entry %+v", entry)
+			}
+
+			if entry.Time.Before(start.Add(-time.Second)) || entry.Time.After(time.Now()) {
+				t.Fatalf("expected entry time around now, got %v", entry.Time)
+			}
+
+			if !reflect.DeepEqual(entry.Before, tc.expectedBefore) {
+				t.Fatalf("expected before %+v, got %+v", tc.expectedBefore, entry.Before)
+			}
+
+			if entry.After.Color != tc.expectedColor || entry.Revision != entry.After.Revision {
+				t.Fatalf("unexpected after %+v at revision %d", entry.After, entry.Revision)
+			}
+
+			if entry.Before != nil && entry.After.Revision != entry.Before.Revision+1 {
+				t.Fatalf("expected revision %d, got %d", entry.Before.Revision+1, entry.After.Revision)
+			}
+		})
+	}
+
+	t.Run("should record the anonymous actor when the context names none", func(t *testing.T) {
+		// Arrange
+		var entries []models.CarAuditEntry
+		service := &DefaultCarService{repo: repo, audit: recordingAudit(&entries)}
+
+		// Act
+		err := service.Delete(t.Context(), "1", 0)
+
+		// Assert
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if len(entries) != 1 || entries[0].Actor != AnonymousActor || entries[0].RequestID != "" {
+			t.Fatalf("expected an anonymous entry, got %+v", entries)
+		}
+	})
+
+	t.Run("should record one entry per car of a bulk update but none in dry-run mode", func(t *testing.T) {
+		// Arrange
+		var entries []models.CarAuditEntry
+		cars := models.Cars{stored, {ID: "2", Make: "Ford", Model: "F150", Color: "Blue", Category: "Truck", Year: 2010, Revision: 1}}
+		repo := &MockCarRepository{
+			UpdateBatchFn: func(sel models.CarSelector, patch models.CarPatchFunc, dryRun bool) (models.Cars, error) {
+				patched := slices.Clone(cars)
+				for i := range patched {
+					if err := patch(&patched[i]); err != nil {
+						return nil, err
+					}
+					patched[i].Revision++
+				}
+				return patched, nil
+			},
+		}
+		service := &DefaultCarService{repo: repo, audit: recordingAudit(&entries)}
+		sel := models.CarSelector{IDs: []string{"1", "2"}}
+		paint := func(car *models.Car) error {
+			car.Color = "Black"
+			return nil
+		}
+
+		// Act
+		_, dryRunErr := service.UpdateBatch(t.Context(), sel, paint, true)
+		_, err := service.UpdateBatch(t.Context(), sel, paint, false)
+
+		// Assert
+		if dryRunErr != nil || err != nil {
+			t.Fatalf("unexpected errors: %v, %v", dryRunErr, err)
+		}
+
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 entries, got %+v", entries)
+		}
+
+		for i, entry := range entries {
+			if !reflect.DeepEqual(*entry.Before, cars[i]) || entry.After.Color != "Black" || entry.Revision != cars[i].Revision+1 {
+				t.Fatalf("unexpected entry %d: %+v", i, entry)
+			}
+		}
+	})
+
+	t.Run("should succeed when the audit store fails", func(t *testing.T) {
+		// Arrange
+		audit := &MockAuditStore{
+			AppendFn: func(...models.CarAuditEntry) error {
+				return errors.New("audit unavailable")
+			},
+		}
+		service := &DefaultCarService{repo: repo, audit: audit}
+		car := models.Car{Make: "Ford", Model: "F10", Color: "Silver", Category: "Truck", Year: 2010}
+
+		// Act
+		createErr := service.Create(t.Context(), &car)
+		deleteErr := service.Delete(t.Context(), "1", 0)
+
+		// Assert
+		if createErr != nil || deleteErr != nil {
+			t.Fatalf("expected writes to succeed, got %v and %v", createErr, deleteErr)
+		}
+
+		if car.ID != "1" {
+			t.Fatalf("expected the created car to be returned, got %+v", car)
+		}
+	})
+}
+
+func TestDefaultCarService_History(t *testing.T) {
+	history := []models.CarAuditEntry{
+		{CarID: "1", Revision: 1, Action: models.CarCreated, Actor: "alice", After: models.Car{ID: "1", Revision: 1}},
+	}
+
+	tCases := []struct {
+		name         string
+		history      []models.CarAuditEntry
+		auditErr     error
+		findErr      error
+		expected     []models.CarAuditEntry
+		expectedCode string
+	}{
+		{
+			name:     "should return the history of the car",
+			history:  history,
+			expected: history,
+		},
+		{
+			name:     "should return an empty history when the car exists",
+			history:  []models.CarAuditEntry{},
+			expected: []models.CarAuditEntry{},
+		},
+		{
+			name:         "should return not found when the car has no history and does not exist",
+			history:      []models.CarAuditEntry{},
+			findErr:      e.ErrCarNotFound,
+			expectedCode: e.CodeCarNotFound,
+		},
+		{
+			name:         "should return internal error when the audit store fails",
+			auditErr:     errors.New("db down"),
+			expectedCode: e.CodeInternalError,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			repo := &MockCarRepository{
+				FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+					if !includeDeleted {
+						t.Fatal("expected deleted cars to be included")
+					}
+					return models.Car{ID: id}, tc.findErr
+				},
+			}
+			audit := &MockAuditStore{
+				HistoryFn: func(carID string) ([]models.CarAuditEntry, error) {
+					if carID != "1" {
+						t.Fatalf("expected car 1, got %q", carID)
+					}
+					return tc.history, tc.auditErr
+				},
+			}
+
+			service := &DefaultCarService{repo: repo, audit: audit}
+
+			// Act
+			got, err := service.History("1")
+
+			// Assert
+			if tc.expectedCode == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tc.expected) {
+					t.Fatalf("expected %+v, got %+v", tc.expected, got)
+				}
+				return
+			}
+
+			var serviceError *e.ServiceError
+			if !errors.As(err, &serviceError) {
+				t.Fatalf("expected ServiceError, got %T", err)
+			}
+
+			if serviceError.Code != tc.expectedCode {
+				t.Fatalf("expected %s, got %v", tc.expectedCode, serviceError.Code)
+			}
+		})
+	}
+}
+
+func TestDefaultCarService_FindAsOf(t *testing.T) {
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	created := models.Car{ID: "1", Make: "Ford", Revision: 1}
+	deleted := models.Car{ID: "1", Make: "Ford", Revision: 2, DeletedAt: u.Ptr(at.Add(time.Hour))}
+	history := []models.CarAuditEntry{
+		{CarID: "1", Revision: 1, Action: models.CarCreated, Time: at, After: created},
+		{CarID: "1", Revision: 2, Action: models.CarDeleted, Time: at.Add(time.Hour), Before: &created, After: deleted},
+	}
+
+	tCases := []struct {
+		name           string
+		history        []models.CarAuditEntry
+		stored         *models.Car
+		at             time.Time
+		includeDeleted bool
+		auditErr       error
+		findErr        error
+		expected       models.Car
+		expectedCode   string
+	}{
+		{
+			name:     "should return the car as it was at the given time",
+			history:  history,
+			stored:   &deleted,
+			at:       at.Add(time.Minute),
+			expected: created,
+		},
+		{
+			name:         "should return not found before the car was created",
+			history:      history,
+			stored:       &deleted,
+			at:           at.Add(-time.Minute),
+			expectedCode: e.CodeCarNotFound,
+		},
+		{
+			name:         "should return not found after the car was deleted",
+			history:      history,
+			stored:       &deleted,
+			at:           at.Add(2 * time.Hour),
+			expectedCode: e.CodeCarNotFound,
+		},
+		{
+			name:           "should return the deleted car when deleted cars are included",
+			history:        history,
+			stored:         &deleted,
+			at:             at.Add(2 * time.Hour),
+			includeDeleted: true,
+			expected:       deleted,
+		},
+		{
+			name:           "should return the purged car from its history",
+			history:        history,
+			at:             at.Add(2 * time.Hour),
+			includeDeleted: true,
+			expected:       deleted,
+		},
+		{
+			name:     "should return the stored car when it has no history",
+			stored:   &created,
+			at:       at,
+			expected: created,
+		},
+		{
+			name:         "should return not found when the car has no history and does not exist",
+			at:           at,
+			expectedCode: e.CodeCarNotFound,
+		},
+		{
+			name:         "should return internal error when the audit store fails",
+			history:      history,
+			at:           at,
+			auditErr:     errors.New("db down"),
+			expectedCode: e.CodeInternalError,
+		},
+		{
+			name:         "should return internal error when the repository fails",
+			history:      history,
+			at:           at,
+			findErr:      errors.New("db down"),
+			expectedCode: e.CodeInternalError,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			audit := &MockAuditStore{
+				HistoryFn: func(carID string) ([]models.CarAuditEntry, error) {
+					return tc.history, tc.auditErr
+				},
+			}
+			repo := &MockCarRepository{
+				FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+					switch {
+					case tc.findErr != nil:
+						return models.Car{}, tc.findErr
+					case tc.stored == nil:
+						return models.Car{}, e.ErrCarNotFound
+					}
+					return *tc.stored, nil
+				},
+			}
+
+			service := &DefaultCarService{repo: repo, audit: audit}
+
+			// Act
+			got, err := service.FindAsOf("1", tc.at, tc.includeDeleted)
+
+			// Assert
+			if tc.expectedCode == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tc.expected) {
+					t.Fatalf("expected %+v, got %+v", tc.expected, got)
+				}
+				return
+			}
+
+			var serviceError *e.ServiceError
+			if !errors.As(err, &serviceError) {
+				t.Fatalf("expected ServiceError, got %T", err)
+			}
+
+			if serviceError.Code != tc.expectedCode {
+				t.Fatalf("expected %s, got %v", tc.expectedCode, serviceError.Code)
+			}
+		})
+	}
+}
+
+func TestDefaultCarService_FindRevision(t *testing.T) {
+	created := models.Car{ID: "1", Make: "Ford", Color: "Silver", Revision: 1}
+	updated := models.Car{ID: "1", Make: "Ford", Color: "Red", Revision: 2}
+	history := []models.CarAuditEntry{
+		{CarID: "1", Revision: 2, Action: models.CarUpdated, Before: &created, After: updated},
+	}
+
+	tCases := []struct {
+		name         string
+		history      []models.CarAuditEntry
+		stored       *models.Car
+		revision     int64
+		expected     models.Car
+		expectedCode string
+	}{
+		{
+			name:     "should return the car as it was before a recorded change",
+			history:  history,
+			stored:   &updated,
+			revision: 1,
+			expected: created,
+		},
+		{
+			name:     "should return the stored car at its revision",
+			stored:   &created,
+			revision: 1,
+			expected: created,
+		},
+		{
+			name:     "should return the car from its history once purged",
+			history:  history,
+			revision: 2,
+			expected: updated,
+		},
+		{
+			name:         "should return not found for an unrecorded revision",
+			history:      history,
+			stored:       &updated,
+			revision:     3,
+			expectedCode: e.CodeCarNotFound,
+		},
+	}
+
+	for _, tc := range tCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Arrange
+			audit := &MockAuditStore{
+				HistoryFn: func(carID string) ([]models.CarAuditEntry, error) {
+					return tc.history, nil
+				},
+			}
+			repo := &MockCarRepository{
+				FindFn: func(id string, includeDeleted bool) (models.Car, error) {
+					if tc.stored == nil {
+						return models.Car{}, e.ErrCarNotFound
+					}
+					return *tc.stored, nil
+				},
+			}
+
+			service := &DefaultCarService{repo: repo, audit: audit}
+
+			// Act
+			got, err := service.FindRevision("1", tc.revision)
+
+			// Assert
+			if tc.expectedCode == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tc.expected) {
+					t.Fatalf("expected %+v, got %+v", tc.expected, got)
+				}
+				return
+			}
+
+			var serviceError *e.ServiceError
+			if !errors.As(err, &serviceError) {
+				t.Fatalf("expected ServiceError, got %T", err)
+			}
+
+			if serviceError.Code != tc.expectedCode {
+				t.Fatalf("expected %s, got %v", tc.expectedCode, serviceError.Code)
+			}
+		})
+	}
+}